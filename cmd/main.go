@@ -1,20 +1,71 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"nawa-functions/internal"
+	"os"
 )
 
+// main encrypts and decrypts data with the keyring of the keystore file in the keystore environment
+// variable, which is created when missing and protected by keystore_passphrase when set, or else
+// with the key in the key environment variable, or with a new key that it prints when none is set.
 func main() {
-	key := []byte("")
+	ring, err := loadKeyring()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid key: %v\n", err)
+		os.Exit(1)
+	}
+
 	data := []byte("")
 
 	// Encrypt
-	encrypted, _ := internal.Encrypt(data, key)
+	encrypted, err := ring.Encrypt(data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to encrypt: %v\n", err)
+		os.Exit(1)
+	}
 	fmt.Printf("Encrypted: %s\n", encrypted)
 
 	//Decrypt
-	decrypted, _ := internal.Decrypt(encrypted, key)
+	decrypted, err := ring.Decrypt(encrypted)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to decrypt: %v\n", err)
+		os.Exit(1)
+	}
 
 	fmt.Printf("Decrypted: %s\n", string(decrypted))
 }
+
+func loadKeyring() (*internal.Keyring, error) {
+	path, passphrase := os.Getenv("keystore"), []byte(os.Getenv("keystore_passphrase"))
+	if path != "" {
+		ring, err := internal.LoadKeystore(path, passphrase)
+		if !errors.Is(err, fs.ErrNotExist) {
+			return ring, err
+		}
+	}
+
+	key, err := internal.KeyFromEnv("key")
+	if os.Getenv("key") == "" {
+		if key, err = internal.GenerateKey(256); err == nil && path == "" {
+			fmt.Printf("Generated key: %s\n", internal.EncodeKey(key))
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ring, err := internal.NewKeyring("k1", map[string][]byte{"k1": key})
+	if err != nil || path == "" {
+		return ring, err
+	}
+
+	if err := internal.SaveKeystore(path, ring, passphrase); err != nil {
+		return nil, err
+	}
+	fmt.Printf("Saved key to %s\n", path)
+
+	return ring, nil
+}
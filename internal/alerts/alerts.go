@@ -0,0 +1,150 @@
+// Package alerts fetches and normalizes the active alerts of the National Weather Service, for the
+// alerts function and the push notifications sent for them.
+package alerts
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/geo"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	// URL is the National Weather Service active alerts API.
+	URL = api.EnvOr("alerts_api_url", "https://api.weather.gov/alerts/active")
+	// ZonePattern matches NWS public forecast zones and counties, such as NYZ072 or NYC061.
+	ZonePattern = regexp.MustCompile(`^[A-Z]{2}[CZ][0-9]{3}$`)
+)
+
+// CoordinatePrecision rounds points to roughly 1km, far below the size of the forecast zones that
+// alerts are issued for.
+const CoordinatePrecision = 2
+
+// Point formats lat/lon as the point query parameter of the alerts API, rounded to
+// CoordinatePrecision.
+func Point(lat, lon float64) string {
+	return geo.FormatCoordinate(lat, CoordinatePrecision) + "," + geo.FormatCoordinate(lon, CoordinatePrecision)
+}
+
+// severityRank orders CAP severities from the most to the least severe.
+var severityRank = map[string]int{"extreme": 4, "severe": 3, "moderate": 2, "minor": 1}
+
+// KnownSeverity reports whether severity is one of the CAP severities, in lowercase.
+func KnownSeverity(severity string) bool {
+	_, ok := severityRank[severity]
+	return ok
+}
+
+// AtLeast reports whether severity is at least as severe as minimum.
+func AtLeast(severity, minimum string) bool {
+	return severityRank[severity] >= severityRank[minimum]
+}
+
+// Alert is the normalized form of an active NWS alert. Expires is when the hazard is expected to
+// end, which the NWS reports separately from when the message itself expires.
+type Alert struct {
+	ID          string `json:"id"`
+	Event       string `json:"event"`
+	Headline    string `json:"headline"`
+	Description string `json:"description"`
+	Instruction string `json:"instruction,omitempty"`
+	Area        string `json:"area"`
+	Sender      string `json:"sender"`
+	Severity    string `json:"severity"`
+	Urgency     string `json:"urgency"`
+	Certainty   string `json:"certainty"`
+	Onset       string `json:"onset,omitempty"`
+	Expires     string `json:"expires,omitempty"`
+}
+
+// Result is the response body of the alerts function.
+type Result struct {
+	Provider    string  `json:"provider"`
+	Attribution string  `json:"attribution"`
+	Alerts      []Alert `json:"alerts"`
+}
+
+type nwsAlerts struct {
+	Features []struct {
+		Properties struct {
+			ID          string `json:"id"`
+			AreaDesc    string `json:"areaDesc"`
+			Onset       string `json:"onset"`
+			Expires     string `json:"expires"`
+			Ends        string `json:"ends"`
+			Severity    string `json:"severity"`
+			Certainty   string `json:"certainty"`
+			Urgency     string `json:"urgency"`
+			Event       string `json:"event"`
+			SenderName  string `json:"senderName"`
+			Headline    string `json:"headline"`
+			Description string `json:"description"`
+			Instruction string `json:"instruction"`
+		} `json:"properties"`
+	} `json:"features"`
+}
+
+// normalizeTime converts an NWS timestamp, which carries the local offset of the issuing office, to
+// UTC. Empty and unparsable values are returned unchanged.
+func normalizeTime(value string) string {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return value
+	}
+
+	return t.UTC().Format(time.RFC3339)
+}
+
+// Parse normalizes an NWS alerts response, most severe first.
+func Parse(body []byte) (*Result, error) {
+	var upstream nwsAlerts
+	if err := json.Unmarshal(body, &upstream); err != nil {
+		return nil, err
+	}
+
+	result := &Result{Provider: "nws", Attribution: "National Weather Service", Alerts: []Alert{}}
+	for _, feature := range upstream.Features {
+		p := feature.Properties
+		expires := p.Ends
+		if expires == "" {
+			expires = p.Expires
+		}
+
+		result.Alerts = append(result.Alerts, Alert{
+			ID:          p.ID,
+			Event:       p.Event,
+			Headline:    p.Headline,
+			Description: p.Description,
+			Instruction: p.Instruction,
+			Area:        p.AreaDesc,
+			Sender:      p.SenderName,
+			Severity:    strings.ToLower(p.Severity),
+			Urgency:     strings.ToLower(p.Urgency),
+			Certainty:   strings.ToLower(p.Certainty),
+			Onset:       normalizeTime(p.Onset),
+			Expires:     normalizeTime(expires),
+		})
+	}
+
+	slices.SortStableFunc(result.Alerts, func(a, b Alert) int {
+		return cmp.Compare(severityRank[b.Severity], severityRank[a.Severity])
+	})
+
+	return result, nil
+}
+
+// Fetch returns the active alerts matching params, a point or zone query of the NWS alerts API.
+func Fetch(ctx context.Context, params url.Values) (*Result, error) {
+	body, err := api.Get(ctx, URL+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse([]byte(body))
+}
@@ -0,0 +1,130 @@
+// Package api contains the HTTP plumbing shared by the Netlify functions: CORS, response encoding,
+// error bodies, request ids, routing and client token checks.
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"log/slog"
+	"nawa-functions/internal/cache"
+	"nawa-functions/internal/metrics"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Logger adds the request id of the context to every record.
+var Logger = slog.New(contextHandler{slog.NewTextHandler(os.Stdout, nil)})
+
+// EnvOr returns the value of the environment variable key, or fallback when it is unset or empty.
+func EnvOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+
+	return fallback
+}
+
+// cacheStatus counts the cache lookups of an invocation so that it can be reported in X-Cache.
+type cacheStatus struct {
+	mu     sync.Mutex
+	hits   int
+	misses int
+}
+
+type cacheStatusKey struct{}
+
+// RecordCacheLookup counts a cache lookup in the key namespace, such as "geo:fwd", towards the
+// metrics and the X-Cache header of the response. Failures of the cache count as misses in X-Cache.
+func RecordCacheLookup(ctx context.Context, namespace, result string) {
+	metrics.CacheLookup(ctx, namespace, result)
+
+	status, ok := ctx.Value(cacheStatusKey{}).(*cacheStatus)
+	if !ok {
+		return
+	}
+
+	status.mu.Lock()
+	defer status.mu.Unlock()
+	if result == cache.LookupHit {
+		status.hits++
+	} else {
+		status.misses++
+	}
+}
+
+// String returns HIT or MISS when every lookup had the same outcome, PARTIAL for a mix of both and
+// an empty string when the cache was not used at all.
+func (s *cacheStatus) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case s.hits > 0 && s.misses > 0:
+		return "PARTIAL"
+	case s.hits > 0:
+		return "HIT"
+	case s.misses > 0:
+		return "MISS"
+	}
+
+	return ""
+}
+
+// headResponse turns the response to the equivalent GET request into the response to a HEAD request,
+// keeping its headers and the length of the body it would have sent.
+func headResponse(res *events.APIGatewayProxyResponse) *events.APIGatewayProxyResponse {
+	length := len(res.Body)
+	if res.IsBase64Encoded {
+		if decoded, err := base64.StdEncoding.DecodeString(res.Body); err == nil {
+			length = len(decoded)
+		}
+	}
+
+	if res.Body != "" {
+		res.Headers["Content-Length"] = strconv.Itoa(length)
+	}
+	res.Body = ""
+	res.IsBase64Encoded = false
+
+	return res
+}
+
+// Handler returns the Lambda handler of a function serving routes. It assigns the request id,
+// answers CORS preflights for any path, treats HEAD as GET without a body, reports cache usage in
+// X-Cache and waits for the Background tasks of the request before returning.
+func Handler(routes *Mux) func(ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		ctx = WithRequestID(ctx, &request)
+		Logger.InfoContext(ctx, "received request", slog.String("method", request.HTTPMethod), slog.String("path", request.Path))
+
+		if request.HTTPMethod == http.MethodOptions {
+			Logger.InfoContext(ctx, "received OPTIONS request", slog.String("origin", request.Headers["origin"]))
+			return preflightResponse(&request), nil
+		}
+
+		status := &cacheStatus{}
+		ctx = context.WithValue(withRefresh(ctx, &request), cacheStatusKey{}, status)
+		ctx, wait := withBackground(ctx)
+		defer wait()
+
+		head := request.HTTPMethod == http.MethodHead
+		if head {
+			request.HTTPMethod = http.MethodGet
+		}
+
+		res := routes.Dispatch(ctx, &request)
+		if cache := status.String(); cache != "" {
+			res.Headers["X-Cache"] = cache
+		}
+
+		if head {
+			res = headResponse(res)
+		}
+
+		return res, nil
+	}
+}
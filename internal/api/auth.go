@@ -0,0 +1,177 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"nawa-functions/internal"
+	"nawa-functions/internal/token"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+var (
+	nawaToken       = os.Getenv("nawa_token")
+	requireToken, _ = strconv.ParseBool(os.Getenv("require_token"))
+	adminToken      = os.Getenv("admin_token")
+	tokens          = newTokens(os.Getenv("client_token_secret"))
+	// nawaCipher decrypts the client tokens of every request with nawa_key.
+	nawaCipher, nawaCipherErr = newNawaCipher()
+)
+
+// The audiences of the JWTs the functions accept: client tokens in X-Nawa-Token, user tokens as
+// bearer tokens of WithUser routes, and tickets in the query of clients that cannot send headers.
+const (
+	ClientAudience = "nawa-client"
+	UserAudience   = "nawa-user"
+	TicketAudience = "nawa-ticket"
+)
+
+// ErrNoTickets is returned by MintTicket when no client_token_secret is configured to sign tickets.
+var ErrNoTickets = errors.New("tickets are not configured")
+
+// newNawaCipher returns the cipher of the key in nawa_key, which is validated by
+// internal.KeyFromEnv.
+func newNawaCipher() (*internal.Cipher, error) {
+	key, err := internal.SecretFromEnv("nawa_key")
+	if err != nil {
+		return nil, err
+	}
+
+	return internal.NewCipher(key)
+}
+
+// newTokens returns the issuer of the JWT client and user tokens, or nil when they are not
+// configured.
+func newTokens(secret string) *token.Issuer {
+	if secret == "" {
+		return nil
+	}
+
+	ring, err := internal.NewKeyring("client", map[string][]byte{"client": []byte(secret)})
+	if err != nil {
+		Logger.Error("invalid client_token_secret", slog.Any("error", err))
+		return nil
+	}
+
+	return token.NewHS256(ring)
+}
+
+// Authorize validates the client token when one is required and returns the response to send
+// back when it is missing or invalid. Client tokens are either nawa_token encrypted with nawa_key,
+// or JWTs for ClientAudience signed with client_token_secret, which can expire. Handlers use
+// Authorized instead unless they need to check the token before producing a proxy response.
+func Authorize(ctx context.Context, request *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	if !requireToken {
+		return nil
+	}
+	Logger.InfoContext(ctx, "client token is required")
+
+	token := request.Headers["x-nawa-token"]
+	if token == "" {
+		return Error(request, http.StatusUnauthorized, "invalid_token", "invalid token")
+	}
+	Logger.InfoContext(ctx, "token header is present")
+
+	// JWTs are the only tokens made of three dot separated parts.
+	if strings.Count(token, ".") == 2 {
+		if tokens == nil {
+			return Error(request, http.StatusUnauthorized, "invalid_token", "invalid token")
+		}
+
+		if _, err := tokens.Verify(token, ClientAudience); err != nil {
+			Logger.WarnContext(ctx, "rejected invalid client JWT", slog.Any("error", err))
+			return Error(request, http.StatusUnauthorized, "invalid_token", "invalid token")
+		}
+
+		Logger.InfoContext(ctx, "client token validated successfully")
+		return nil
+	}
+
+	if nawaCipherErr != nil {
+		Logger.ErrorContext(ctx, "invalid nawa_key", slog.Any("error", nawaCipherErr))
+		return Error(request, http.StatusInternalServerError, "invalid_token", "failed to decrypt token")
+	}
+
+	decrypted, err := nawaCipher.Decrypt(token)
+	if err != nil {
+		Logger.ErrorContext(ctx, "failed to decrypt token", slog.Any("error", err))
+		return Error(request, http.StatusInternalServerError, "invalid_token", "failed to decrypt token")
+	}
+
+	if !internal.SecureCompare(string(decrypted), nawaToken) {
+		Logger.ErrorContext(ctx, "decrypted client token does not match server token")
+		return Error(request, http.StatusUnauthorized, "invalid_token", "invalid token")
+	}
+
+	Logger.InfoContext(ctx, "client token validated successfully")
+	return nil
+}
+
+// MintTicket returns a ticket standing in for the client token of a request for ttl. Tickets go
+// into URLs, which end up in access logs, so they are short lived rather than client tokens.
+func MintTicket(ttl time.Duration) (string, error) {
+	if tokens == nil {
+		return "", ErrNoTickets
+	}
+
+	return tokens.Mint("ticket", TicketAudience, ttl, nil)
+}
+
+// AuthorizeTicket validates a ticket from MintTicket when a client token is required and returns
+// the response to send back when it is missing, invalid or expired.
+func AuthorizeTicket(ctx context.Context, request *events.APIGatewayProxyRequest, ticket string) *events.APIGatewayProxyResponse {
+	if !requireToken {
+		return nil
+	}
+
+	if ticket == "" || tokens == nil {
+		return Error(request, http.StatusUnauthorized, "invalid_ticket", "invalid ticket")
+	}
+
+	if _, err := tokens.Verify(ticket, TicketAudience); err != nil {
+		Logger.WarnContext(ctx, "rejected invalid ticket", slog.Any("error", err))
+		return Error(request, http.StatusUnauthorized, "invalid_ticket", "invalid ticket")
+	}
+
+	return nil
+}
+
+// Authorized wraps handler so that it only runs for requests with a valid client token.
+func Authorized(handler HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+		if res := Authorize(ctx, req); res != nil {
+			return res
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// AdminOnly wraps handler so that it only runs for requests presenting admin_token in
+// X-Nawa-Admin, or as a bearer token for scrapers that cannot set custom headers. Admin routes do
+// not exist while no admin token is configured.
+func AdminOnly(handler HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+		if adminToken == "" {
+			return Error(req, http.StatusNotFound, "not_found", "route not found")
+		}
+
+		token := req.Headers["x-nawa-admin"]
+		if bearer, ok := strings.CutPrefix(req.Headers["authorization"], "Bearer "); ok && token == "" {
+			token = bearer
+		}
+
+		if !internal.SecureCompare(token, adminToken) {
+			Logger.WarnContext(ctx, "rejected invalid admin token")
+			return Error(req, http.StatusUnauthorized, "invalid_token", "invalid admin token")
+		}
+
+		return handler(ctx, req)
+	}
+}
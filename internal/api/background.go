@@ -0,0 +1,63 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// backgroundTimeout bounds how long Handler holds the response for the background tasks of a
+// request, such as the refreshes of the stale cache entries it was served.
+const backgroundTimeout = 5 * time.Second
+
+// background tracks the background tasks of an invocation. Lambda freezes the container as soon as
+// the handler returns, so tasks still running then would stall until the next invocation, if any.
+type background struct {
+	ctx context.Context
+	wg  sync.WaitGroup
+}
+
+type backgroundKey struct{}
+
+// withBackground returns a context in which Background tasks are tracked, and a function waiting for
+// them for at most backgroundTimeout before cancelling those still running.
+func withBackground(ctx context.Context) (context.Context, func()) {
+	tasksCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	bg := &background{ctx: tasksCtx}
+
+	wait := func() {
+		defer cancel()
+
+		done := make(chan struct{})
+		go func() {
+			bg.wg.Wait()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(backgroundTimeout):
+			Logger.WarnContext(ctx, "cancelled unfinished background tasks", slog.Duration("timeout", backgroundTimeout))
+		}
+	}
+
+	return context.WithValue(ctx, backgroundKey{}, bg), wait
+}
+
+// Background runs task outside of the request of ctx, with a context that is not cancelled when the
+// request is. Handler waits for the tasks of its requests before returning the response. Outside of
+// Handler, task runs in a goroutine of its own, which is not waited for.
+func Background(ctx context.Context, task func(ctx context.Context)) {
+	bg, ok := ctx.Value(backgroundKey{}).(*background)
+	if !ok {
+		go task(context.WithoutCancel(ctx))
+		return
+	}
+
+	bg.wg.Add(1)
+	go func() {
+		defer bg.wg.Done()
+		task(bg.ctx)
+	}()
+}
@@ -0,0 +1,29 @@
+package api
+
+import (
+	"errors"
+	"nawa-functions/internal/cache"
+)
+
+// The caches of the functions log with Logger, count their lookups with RecordCacheLookup and
+// refresh their stale entries with Background, as part of handling the request they serve.
+func init() {
+	cache.SetHooks(cache.Hooks{
+		Logger:        Logger,
+		Refreshing:    Refreshing,
+		RefreshWindow: RefreshWindow,
+		RecordLookup:  RecordCacheLookup,
+		Background:    Background,
+		StatusCode: func(err error) (int, bool) {
+			var status *StatusError
+			if !errors.As(err, &status) {
+				return 0, false
+			}
+
+			return status.StatusCode, true
+		},
+		StatusError: func(statusCode int) error {
+			return &StatusError{StatusCode: statusCode}
+		},
+	})
+}
@@ -0,0 +1,22 @@
+package api
+
+import (
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// ClientIP returns the address of the caller as reported by Netlify, falling back to the first hop
+// of X-Forwarded-For and finally the source IP of the request context.
+func ClientIP(req *events.APIGatewayProxyRequest) string {
+	if ip := req.Headers["x-nf-client-connection-ip"]; ip != "" {
+		return ip
+	}
+
+	if forwarded := req.Headers["x-forwarded-for"]; forwarded != "" {
+		first, _, _ := strings.Cut(forwarded, ",")
+		return strings.TrimSpace(first)
+	}
+
+	return req.RequestContext.Identity.SourceIP
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// minCompressSize is the smallest body worth compressing, below it the encoding overhead outweighs
+// the savings.
+const minCompressSize = 1024
+
+// supportedEncodings lists the content codings in order of preference.
+var supportedEncodings = []string{"br", "gzip"}
+
+// negotiateEncoding returns the preferred supported content coding that acceptEncoding allows, or ""
+// when the body should be sent as is.
+func negotiateEncoding(acceptEncoding string) string {
+	accepted := map[string]bool{}
+	for entry := range strings.SplitSeq(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(entry), ";")
+		quality := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if q, err := strconv.ParseFloat(value, 64); err == nil {
+				quality = q
+			}
+		}
+		accepted[strings.ToLower(strings.TrimSpace(coding))] = quality > 0
+	}
+
+	for _, encoding := range supportedEncodings {
+		if accepted[encoding] {
+			return encoding
+		}
+	}
+
+	return ""
+}
+
+// compressBody compresses body with the coding negotiated from acceptEncoding and returns it base64
+// encoded, as the Lambda proxy integration requires for binary bodies. The returned encoding is empty
+// when the body was left uncompressed.
+func compressBody(body, acceptEncoding string) (string, string) {
+	if len(body) < minCompressSize {
+		return "", ""
+	}
+
+	encoding := negotiateEncoding(acceptEncoding)
+	if encoding == "" {
+		return "", ""
+	}
+
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	if encoding == "br" {
+		w = brotli.NewWriterLevel(&buf, brotli.DefaultCompression)
+	} else {
+		w = gzip.NewWriter(&buf)
+	}
+
+	if _, err := io.WriteString(w, body); err != nil {
+		return "", ""
+	}
+
+	if err := w.Close(); err != nil {
+		return "", ""
+	}
+
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), encoding
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"nawa-functions/internal/metrics"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// HTTPClient is shared by the upstream requests of a container so that connections are reused
+// across invocations.
+var HTTPClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		MaxIdleConns:        100,              // Max total idle connections
+		MaxIdleConnsPerHost: 20,               // Max idle connections per host
+		IdleConnTimeout:     15 * time.Minute, // How long an idle connection stays open
+	},
+}
+
+// StatusError is returned by Get when the upstream answered with a non 200 status code.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("received unexpected status code %d", e.StatusCode)
+}
+
+// credentialParams are the query parameters upstream APIs read their keys from.
+var credentialParams = []string{"access_token", "api_key", "apikey", "appid", "key", "token"}
+
+// redactURL masks the credentials in the query of reqURL so that it can be logged.
+func redactURL(reqURL string) string {
+	u, err := url.Parse(reqURL)
+	if err != nil {
+		return "<unparseable>"
+	}
+
+	query := u.Query()
+	for _, param := range credentialParams {
+		if query.Has(param) {
+			query.Set(param, "REDACTED")
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// Get requests reqURL on behalf of the nawa app and returns the body of a 200 response. The
+// credentials in the query of reqURL are redacted from the logs and from the returned error.
+func Get(ctx context.Context, reqURL string) (string, error) {
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	req.Header.Set("Origin", "https://tshrestha.github.io")
+	req.Header.Set("Referer", "https://tshrestha.github.io/nawa")
+	req.Header.Set("User-Agent", "nawa-functions (https://tshrestha.github.io/nawa)")
+
+	start := time.Now()
+	res, err := HTTPClient.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = redactURL(urlErr.URL)
+		}
+		metrics.ObserveUpstream(ctx, req.URL.Host, time.Since(start), true)
+		Logger.ErrorContext(ctx, "request failed", slog.String("reqURL", redactURL(reqURL)), slog.Any("error", err))
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusOK {
+		body, err := io.ReadAll(res.Body)
+		metrics.ObserveUpstream(ctx, req.URL.Host, time.Since(start), err != nil)
+		if err != nil {
+			Logger.ErrorContext(ctx, "failed to read response body", slog.String("reqURL", redactURL(reqURL)), slog.Any("error", err))
+			return "", err
+		}
+
+		return string(body), nil
+	}
+
+	metrics.ObserveUpstream(ctx, req.URL.Host, time.Since(start), true)
+	Logger.ErrorContext(ctx, "received unexpected status code", slog.String("reqURL", redactURL(reqURL)), slog.Int("statusCode", res.StatusCode))
+	return "", &StatusError{StatusCode: res.StatusCode}
+}
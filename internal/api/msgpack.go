@@ -0,0 +1,55 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const msgpackContentType = "application/x-msgpack"
+
+// acceptsMsgpack reports whether accept prefers MessagePack over JSON. JSON stays the default, so
+// MessagePack is only chosen when it is listed with a quality at least as high as any JSON entry.
+func acceptsMsgpack(accept string) bool {
+	msgpackQuality, jsonQuality := 0.0, 0.0
+	for entry := range strings.SplitSeq(accept, ",") {
+		mediaType, params, _ := strings.Cut(strings.TrimSpace(entry), ";")
+		quality := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if q, err := strconv.ParseFloat(value, 64); err == nil {
+				quality = q
+			}
+		}
+
+		switch strings.ToLower(strings.TrimSpace(mediaType)) {
+		case msgpackContentType, "application/msgpack", "application/vnd.msgpack":
+			msgpackQuality = max(msgpackQuality, quality)
+		case "application/json":
+			jsonQuality = max(jsonQuality, quality)
+		}
+	}
+
+	return msgpackQuality > 0 && msgpackQuality >= jsonQuality
+}
+
+// toMsgpack re-encodes a JSON body as MessagePack, keeping the field names of the JSON schema.
+// Numbers are packed in the smallest representation that holds them exactly.
+func toMsgpack(body string) ([]byte, error) {
+	var value any
+	if err := json.Unmarshal([]byte(body), &value); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.UseCompactInts(true)
+	enc.UseCompactFloats(true)
+	if err := enc.Encode(value); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
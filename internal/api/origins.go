@@ -0,0 +1,61 @@
+package api
+
+import "strings"
+
+const defaultAllowedOrigins = "http://localhost:3000,https://tshrestha.github.io"
+
+// allowedOrigins is parsed once per container from the comma separated ALLOWED_ORIGINS list. Entries
+// are exact origins, or origins whose host starts with "*." to allow any subdomain, such as
+// https://*.netlify.app for deploy previews.
+var allowedOrigins = parseAllowedOrigins(EnvOr("ALLOWED_ORIGINS", defaultAllowedOrigins))
+
+type originPattern struct {
+	scheme string
+	// host is the exact host, or the required suffix including the leading dot for wildcards.
+	host     string
+	wildcard bool
+}
+
+func parseAllowedOrigins(value string) []originPattern {
+	var patterns []originPattern
+	for entry := range strings.SplitSeq(value, ",") {
+		scheme, host, ok := strings.Cut(strings.TrimSuffix(strings.TrimSpace(entry), "/"), "://")
+		if !ok || host == "" {
+			continue
+		}
+
+		pattern := originPattern{scheme: strings.ToLower(scheme), host: strings.ToLower(host)}
+		if suffix, ok := strings.CutPrefix(pattern.host, "*"); ok {
+			pattern.host = suffix
+			pattern.wildcard = true
+		}
+		patterns = append(patterns, pattern)
+	}
+
+	return patterns
+}
+
+// originAllowed reports whether a browser origin matches the allowlist. A wildcard matches one or
+// more subdomain labels in front of its suffix, but never the bare suffix itself.
+func originAllowed(origin string) bool {
+	scheme, host, ok := strings.Cut(strings.ToLower(origin), "://")
+	if !ok || host == "" {
+		return false
+	}
+
+	for _, pattern := range allowedOrigins {
+		if pattern.scheme != scheme {
+			continue
+		}
+
+		if pattern.wildcard {
+			if len(host) > len(pattern.host) && strings.HasSuffix(host, pattern.host) {
+				return true
+			}
+		} else if host == pattern.host {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"context"
+	"nawa-functions/internal"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// RefreshWindow is how close to expiry a cache entry must be for a refresh request to fetch it
+// again. It is longer than the interval of the prewarm function, so popular entries never expire.
+const RefreshWindow = time.Hour
+
+// refreshSecret authenticates the refresh requests of the prewarm function. Refreshing is disabled
+// when it is not set.
+var refreshSecret = os.Getenv("refresh_secret")
+
+type refreshKey struct{}
+
+// withRefresh marks ctx when the request carries the refresh secret in X-Nawa-Refresh.
+func withRefresh(ctx context.Context, req *events.APIGatewayProxyRequest) context.Context {
+	secret := req.Headers["x-nawa-refresh"]
+	if refreshSecret == "" || !internal.SecureCompare(secret, refreshSecret) {
+		return ctx
+	}
+
+	return context.WithValue(ctx, refreshKey{}, true)
+}
+
+// Refreshing reports whether the request was sent by the prewarm function, which asks for cache
+// entries expiring within RefreshWindow to be treated as misses.
+func Refreshing(ctx context.Context) bool {
+	refreshing, _ := ctx.Value(refreshKey{}).(bool)
+	return refreshing
+}
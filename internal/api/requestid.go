@@ -0,0 +1,59 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"nawa-functions/internal"
+	"regexp"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+)
+
+// requestIDPattern limits client supplied request ids to something safe to log and echo back.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+type requestIDKey struct{}
+
+// requestID returns the id of the request: the client's X-Request-Id when it is well formed,
+// otherwise the Lambda request id, and a random id as a last resort.
+func requestID(ctx context.Context, req *events.APIGatewayProxyRequest) string {
+	if id := req.Headers["x-request-id"]; requestIDPattern.MatchString(id) {
+		return id
+	}
+
+	if lc, ok := lambdacontext.FromContext(ctx); ok && lc.AwsRequestID != "" {
+		return lc.AwsRequestID
+	}
+
+	return internal.NewToken(16)
+}
+
+// WithRequestID stores the request id in ctx for the logger, and in the request context so that
+// responses built from the request echo it.
+func WithRequestID(ctx context.Context, req *events.APIGatewayProxyRequest) context.Context {
+	id := requestID(ctx, req)
+	req.RequestContext.RequestID = id
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// contextHandler adds the request id found in the context to every record logged with it.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+		r.AddAttrs(slog.String("requestId", id))
+	}
+
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
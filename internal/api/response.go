@@ -0,0 +1,212 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+var preflightHeaders = map[string]string{
+	"Access-Control-Allow-Headers": "Authorization, X-Nawa-Admin, X-Nawa-Token, X-Nawa-User, X-Request-Id, Content-Type, If-None-Match",
+	"Access-Control-Allow-Methods": "GET, HEAD, POST, PUT, DELETE, OPTIONS",
+	"Access-Control-Max-Age":       EnvOr("cors_max_age", "7200"),
+}
+
+// RequestBody returns the raw request body, decoding it first when the gateway base64 encoded it,
+// and opening it when the client sealed it with SealedContentType.
+func RequestBody(req *events.APIGatewayProxyRequest) ([]byte, error) {
+	body := []byte(req.Body)
+	if req.IsBase64Encoded {
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(req.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	if mediaType, _, _ := strings.Cut(req.Headers["content-type"], ";"); strings.TrimSpace(mediaType) == SealedContentType {
+		return openSealedBody(body)
+	}
+
+	return body, nil
+}
+
+// responseHeaders returns the headers shared by every response, allowing the request origin when it
+// is in the allowlist.
+func responseHeaders(req *events.APIGatewayProxyRequest) map[string]string {
+	headers := map[string]string{"Vary": "Origin"}
+	origin := req.Headers["origin"]
+	if originAllowed(origin) {
+		headers["Access-Control-Allow-Origin"] = origin
+		headers["Access-Control-Expose-Headers"] = "ETag, Retry-After, X-Cache, X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset, X-Request-Id"
+	}
+
+	if id := req.RequestContext.RequestID; id != "" {
+		headers["X-Request-Id"] = id
+	}
+
+	return headers
+}
+
+// Respond returns a JSON response. Successful responses carry an ETag, are compressed and
+// re-encoded as MessagePack as negotiated with the client, and become 304 Not Modified when the
+// client already has them.
+func Respond(req *events.APIGatewayProxyRequest, statusCode int, body string) *events.APIGatewayProxyResponse {
+	headers := responseHeaders(req)
+	if body != "" {
+		headers["Content-Type"] = "application/json"
+	}
+
+	binary := false
+	if statusCode == http.StatusOK && body != "" {
+		if acceptsMsgpack(req.Headers["accept"]) {
+			if packed, err := toMsgpack(body); err == nil {
+				body, binary = string(packed), true
+				headers["Content-Type"] = msgpackContentType
+			}
+		}
+
+		compressed, encoding := compressBody(body, req.Headers["accept-encoding"])
+		etag := computeETag(body, encoding)
+		headers["ETag"] = etag
+		headers["Vary"] = "Origin, Accept, Accept-Encoding"
+
+		if etagMatches(req.Headers["if-none-match"], etag) {
+			return &events.APIGatewayProxyResponse{
+				StatusCode: http.StatusNotModified,
+				Headers:    headers,
+			}
+		}
+
+		if encoding != "" {
+			headers["Content-Encoding"] = encoding
+			return &events.APIGatewayProxyResponse{
+				StatusCode:      statusCode,
+				Body:            compressed,
+				Headers:         headers,
+				IsBase64Encoded: true,
+			}
+		}
+	}
+
+	if binary {
+		return &events.APIGatewayProxyResponse{
+			StatusCode:      statusCode,
+			Body:            base64.StdEncoding.EncodeToString([]byte(body)),
+			Headers:         headers,
+			IsBase64Encoded: true,
+		}
+	}
+
+	return &events.APIGatewayProxyResponse{
+		StatusCode: statusCode,
+		Body:       body,
+		Headers:    headers,
+	}
+}
+
+// Binary returns data with the given content type, base64 encoded as the Lambda proxy integration
+// requires for non text bodies.
+func Binary(req *events.APIGatewayProxyRequest, contentType string, data []byte) *events.APIGatewayProxyResponse {
+	headers := responseHeaders(req)
+	headers["Content-Type"] = contentType
+
+	etag := computeETag(string(data), "")
+	headers["ETag"] = etag
+	if etagMatches(req.Headers["if-none-match"], etag) {
+		return &events.APIGatewayProxyResponse{
+			StatusCode: http.StatusNotModified,
+			Headers:    headers,
+		}
+	}
+
+	return &events.APIGatewayProxyResponse{
+		StatusCode:      http.StatusOK,
+		Body:            base64.StdEncoding.EncodeToString(data),
+		Headers:         headers,
+		IsBase64Encoded: true,
+	}
+}
+
+// EventStreamHeaders returns the headers of a server-sent events response to req.
+func EventStreamHeaders(req *events.APIGatewayProxyRequest) map[string]string {
+	headers := responseHeaders(req)
+	headers["Content-Type"] = "text/event-stream"
+	headers["Cache-Control"] = "no-cache"
+	return headers
+}
+
+// Redirect returns a 302 Found response sending the client to location.
+func Redirect(req *events.APIGatewayProxyRequest, location string) *events.APIGatewayProxyResponse {
+	headers := responseHeaders(req)
+	headers["Location"] = location
+	headers["Cache-Control"] = "no-cache"
+
+	return &events.APIGatewayProxyResponse{
+		StatusCode: http.StatusFound,
+		Headers:    headers,
+	}
+}
+
+// preflightResponse answers a CORS preflight request. Access-Control-Max-Age lets browsers reuse the
+// answer instead of sending a preflight before every request.
+func preflightResponse(req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	res := Respond(req, http.StatusNoContent, "")
+	if _, ok := res.Headers["Access-Control-Allow-Origin"]; ok {
+		maps.Copy(res.Headers, preflightHeaders)
+	}
+
+	return res
+}
+
+// computeETag returns a strong entity tag derived from the response body. Compressed representations
+// are different entities, so the content coding is appended to their tag.
+func computeETag(body, encoding string) string {
+	sum := sha256.Sum256([]byte(body))
+	if encoding != "" {
+		return `"` + hex.EncodeToString(sum[:16]) + "-" + encoding + `"`
+	}
+
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches reports whether the If-None-Match header value lists etag, using the weak comparison
+// that RFC 9110 prescribes for If-None-Match.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	for candidate := range strings.SplitSeq(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ErrorBody is the JSON envelope returned for every failed request. Code is a stable machine readable
+// identifier, Message is meant for humans and may change.
+type ErrorBody struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"requestId,omitempty"`
+}
+
+// Error returns an error response with the given status code and ErrorBody fields.
+func Error(req *events.APIGatewayProxyRequest, statusCode int, code, message string) *events.APIGatewayProxyResponse {
+	body, _ := json.Marshal(ErrorBody{Code: code, Message: message, RequestID: req.RequestContext.RequestID})
+	return Respond(req, statusCode, string(body))
+}
+
+// UpstreamError reports a failed upstream request without exposing its details to the client.
+func UpstreamError(req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	return Error(req, http.StatusInternalServerError, "upstream_error", "upstream request failed")
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"context"
+	"nawa-functions/internal/metrics"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// HandlerFunc handles a routed request and returns the response to send back.
+type HandlerFunc func(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse
+
+type routeEntry struct {
+	method   string
+	pattern  string
+	segments []string
+	handler  HandlerFunc
+}
+
+// Mux matches requests against a table of method and path patterns. Pattern segments of the form
+// {name} match any single path segment, which is then available in the request's PathParameters.
+type Mux struct {
+	prefixes []string
+	routes   []routeEntry
+}
+
+// NewMux returns a Mux that strips the given function base paths before matching.
+func NewMux(prefixes ...string) *Mux {
+	return &Mux{prefixes: prefixes}
+}
+
+// Handle registers handler for requests with the given method and path pattern.
+func (m *Mux) Handle(method, pattern string, handler HandlerFunc) *Mux {
+	m.routes = append(m.routes, routeEntry{method: method, pattern: pattern, segments: splitPath(pattern), handler: handler})
+	return m
+}
+
+// splitPath returns the non empty segments of path, so that duplicate and trailing slashes do not
+// affect matching.
+func splitPath(path string) []string {
+	return slices.DeleteFunc(strings.Split(path, "/"), func(segment string) bool { return segment == "" })
+}
+
+// trimPrefix removes the longest matching function base path from path.
+func (m *Mux) trimPrefix(path string) string {
+	longest := ""
+	for _, prefix := range m.prefixes {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if len(prefix) > len(longest) && (path == prefix || strings.HasPrefix(path, prefix+"/")) {
+			longest = prefix
+		}
+	}
+
+	return strings.TrimPrefix(path, longest)
+}
+
+func match(pattern, segments []string) (map[string]string, bool) {
+	if len(pattern) != len(segments) {
+		return nil, false
+	}
+
+	params := map[string]string{}
+	for i, segment := range pattern {
+		if name, ok := strings.CutPrefix(segment, "{"); ok {
+			params[strings.TrimSuffix(name, "}")] = segments[i]
+		} else if segment != segments[i] {
+			return nil, false
+		}
+	}
+
+	return params, true
+}
+
+// Dispatch calls the handler of the route matching the request, answering 404 when no route matches
+// the path and 405 with an Allow header when routes match the path but not the method.
+func (m *Mux) Dispatch(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	segments := splitPath(m.trimPrefix(req.Path))
+
+	var allowed []string
+	for _, route := range m.routes {
+		params, ok := match(route.segments, segments)
+		if !ok {
+			continue
+		}
+
+		if route.method != req.HTTPMethod {
+			allowed = append(allowed, route.method)
+			continue
+		}
+
+		req.PathParameters = params
+		metrics.SetRoute(ctx, route.method+" "+route.pattern)
+		return route.handler(ctx, req)
+	}
+
+	if len(allowed) > 0 {
+		if slices.Contains(allowed, http.MethodGet) {
+			allowed = append(allowed, http.MethodHead)
+		}
+
+		res := Error(req, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		res.Headers["Allow"] = strings.Join(append(allowed, http.MethodOptions), ", ")
+		return res
+	}
+
+	return Error(req, http.StatusNotFound, "not_found", "route not found")
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"context"
+	"crypto/ecdh"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"nawa-functions/internal"
+	"net/http"
+	"os"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// SealedContentType is the content type of request bodies sealed by clients with internal.SealBox
+// to the public key returned by SealingKey. RequestBody opens them, so handlers read them like any
+// other body.
+const SealedContentType = "application/vnd.nawa.sealed"
+
+// sealingKey opens sealed request bodies. Sealed bodies are rejected when sealing_key is not set.
+var sealingKey = newSealingKey()
+
+func newSealingKey() *ecdh.PrivateKey {
+	if os.Getenv("sealing_key") == "" {
+		return nil
+	}
+
+	key, err := internal.BoxKeyFromEnv("sealing_key")
+	if err != nil {
+		Logger.Error("invalid sealing key", slog.Any("error", err))
+		return nil
+	}
+
+	return key
+}
+
+// SealingPublicKey returns the encoded public key that clients seal request bodies to, or "" when
+// sealed bodies are not accepted.
+func SealingPublicKey() string {
+	if sealingKey == nil {
+		return ""
+	}
+
+	return internal.EncodeBoxPublicKey(sealingKey.PublicKey())
+}
+
+// SealingKey responds with the public key that clients seal request bodies to.
+func SealingKey(_ context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	key := SealingPublicKey()
+	if key == "" {
+		return Error(req, http.StatusNotFound, "not_found", "sealed request bodies are not supported")
+	}
+
+	body, _ := json.Marshal(map[string]string{"publicKey": key})
+	return Respond(req, http.StatusOK, string(body))
+}
+
+// openSealedBody opens a request body sealed to the sealing key.
+func openSealedBody(body []byte) ([]byte, error) {
+	if sealingKey == nil {
+		return nil, errors.New("sealed request bodies are not supported")
+	}
+
+	return internal.OpenBox(string(body), sealingKey, nil)
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// userPattern is the shape of the ids that clients without an account generate for themselves and
+// send in X-Nawa-User, long enough to be unguessable.
+var userPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{22,128}$`)
+
+// UserHandlerFunc handles a request on behalf of an authenticated user.
+type UserHandlerFunc func(ctx context.Context, req *events.APIGatewayProxyRequest, user string) *events.APIGatewayProxyResponse
+
+// verifyJWT returns the subject of a user token, a JWT for UserAudience verified like the client
+// tokens.
+func verifyJWT(token string) (string, error) {
+	if tokens == nil {
+		return "", errors.New("client_token_secret is not configured")
+	}
+
+	claims, err := tokens.Verify(token, UserAudience)
+	if err != nil {
+		return "", err
+	}
+
+	if claims.Subject == "" {
+		return "", errors.New("token has no subject")
+	}
+
+	return "jwt:" + claims.Subject, nil
+}
+
+// WithUser wraps handler so that it only runs for an authenticated user. Users are identified by
+// the subject of a bearer user token or, for requests with a valid client token, by the id in
+// X-Nawa-User. The id passed to handler is hashed, so it can be used in Redis keys as is.
+func WithUser(handler UserHandlerFunc) HandlerFunc {
+	return func(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+		var user string
+		if token, ok := strings.CutPrefix(req.Headers["authorization"], "Bearer "); ok {
+			var err error
+			if user, err = verifyJWT(token); err != nil {
+				Logger.WarnContext(ctx, "rejected invalid JWT", slog.Any("error", err))
+				return Error(req, http.StatusUnauthorized, "invalid_token", "invalid token")
+			}
+		} else {
+			if res := Authorize(ctx, req); res != nil {
+				return res
+			}
+
+			id := req.Headers["x-nawa-user"]
+			if !userPattern.MatchString(id) {
+				return Error(req, http.StatusUnauthorized, "invalid_user", "X-Nawa-User must be 22 to 128 URL safe characters")
+			}
+			user = "id:" + id
+		}
+
+		sum := sha256.Sum256([]byte(user))
+		return handler(ctx, req, hex.EncodeToString(sum[:]))
+	}
+}
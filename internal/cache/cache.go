@@ -0,0 +1,115 @@
+// Package cache caches upstream responses for the functions, in Redis in production and in memory
+// where there is no Redis server, such as local runs.
+package cache
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrMiss is returned for keys that are not cached.
+var ErrMiss = errors.New("cache miss")
+
+// Cache stores string values for a time.
+type Cache interface {
+	// Get returns the value of key, or ErrMiss when it is not cached.
+	Get(ctx context.Context, key string) (string, error)
+	// GetMany returns the values of the keys that are cached, in one round trip.
+	GetMany(ctx context.Context, keys []string) (map[string]string, error)
+	// Set caches value under key for ttl. A ttl of zero keeps it until it is deleted.
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	// Delete removes key. Deleting a key that is not cached is not an error.
+	Delete(ctx context.Context, key string) error
+	// TTL returns how long key stays cached, a negative duration when it never expires, or ErrMiss
+	// when it is not cached.
+	TTL(ctx context.Context, key string) (time.Duration, error)
+}
+
+// Lookup returns the value of key in c, or "" when it is not cached or the cache fails, and counts
+// the lookup with Hooks.RecordLookup towards the namespace of key. Entries expiring within
+// Hooks.RefreshWindow are misses for refresh requests, so that the prewarm function renews them
+// before they expire.
+func Lookup(ctx context.Context, c Cache, key string) string {
+	value, _ := unstamp(lookup(ctx, c, key))
+	return value
+}
+
+// lookup is Lookup returning the entry as it is cached.
+func lookup(ctx context.Context, c Cache, key string) string {
+	if hooks.Refreshing(ctx) {
+		if ttl, err := c.TTL(ctx, key); err == nil && ttl >= 0 && ttl < hooks.RefreshWindow {
+			hooks.Logger.InfoContext(ctx, "refreshing cache entry", slog.String("key", key))
+			hooks.RecordLookup(ctx, namespaceOf(key), LookupMiss)
+			return ""
+		}
+	}
+
+	cached, err := getPrefetched(ctx, c, key)
+	switch {
+	case errors.Is(err, ErrMiss):
+		hooks.RecordLookup(ctx, namespaceOf(key), LookupMiss)
+		return ""
+	case err != nil:
+		hooks.Logger.WarnContext(ctx, "failed to read cache", slog.String("key", key), slog.Any("error", err))
+		hooks.RecordLookup(ctx, namespaceOf(key), LookupError)
+		return ""
+	}
+
+	hooks.RecordLookup(ctx, namespaceOf(key), LookupHit)
+	return cached
+}
+
+// Store caches value under key in c for ttl. Failures are logged, since the value can always be
+// fetched again.
+func Store(ctx context.Context, c Cache, key, value string, ttl time.Duration) {
+	if err := c.Set(ctx, key, value, ttl); err != nil {
+		hooks.Logger.ErrorContext(ctx, "failed to write cache", slog.String("key", key), slog.Any("error", err))
+	}
+}
+
+// memoryBytes bounds the in-memory caches of each container, 32 MiB by default.
+var memoryBytes = parseMemoryBytes(os.Getenv("cache_memory_bytes"))
+
+func parseMemoryBytes(value string) int {
+	if n, err := strconv.Atoi(value); err == nil && n > 0 {
+		return n
+	}
+
+	return 32 << 20
+}
+
+// New returns a Redis cache on client that falls back to an in-memory LRU cache of the container
+// when Redis fails, or the in-memory cache alone when client has no address configured, so that the
+// functions can run locally without a Redis server.
+func New(client *redis.Client) Cache {
+	local := NewMemory(memoryBytes)
+	if client.Options().Addr == "" {
+		return local
+	}
+
+	return NewFallback(NewRedis(client), local)
+}
+
+// TTLFor returns how long the entries of a class of keys, such as "forward" or "alerts", are cached:
+// the duration in the cache_ttl_<class> environment variable, such as cache_ttl_alerts=5m, or
+// fallback when it is unset or not a positive duration.
+func TTLFor(class string, fallback time.Duration) time.Duration {
+	value := os.Getenv("cache_ttl_" + class)
+	if value == "" {
+		return fallback
+	}
+
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		hooks.Logger.Warn("ignoring invalid cache TTL", slog.String("class", class), slog.String("value", value))
+		return fallback
+	}
+
+	return ttl
+}
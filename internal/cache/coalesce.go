@@ -0,0 +1,20 @@
+package cache
+
+import "golang.org/x/sync/singleflight"
+
+// fetches coalesces the concurrent upstream fetches of the container by cache key.
+var fetches singleflight.Group
+
+// Coalesce calls fetch for key unless a call for the same key is already in flight, in which case it
+// waits for that call and returns its result instead. When a popular entry expires, the requests
+// that miss it at the same time then cost one upstream request per container rather than one each.
+// fetch should cache what it fetched before returning, so that the callers that arrive after it
+// returned find it in the cache.
+func Coalesce(key string, fetch func() (string, error)) (string, error) {
+	value, err, _ := fetches.Do(key, func() (any, error) {
+		return fetch()
+	})
+
+	result, _ := value.(string)
+	return result, err
+}
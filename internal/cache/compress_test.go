@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompress(t *testing.T) {
+	tests := []struct {
+		name       string
+		value      string
+		compressed bool
+	}{
+		{name: "empty", value: ""},
+		{name: "below the threshold", value: strings.Repeat("a", compressBytes-1)},
+		{name: "repetitive", value: strings.Repeat(`{"name":"Kathmandu","lat":27.7172,"lon":85.324}`, 100), compressed: true},
+		{name: "incompressible", value: incompressible(compressBytes)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stored := compress(tt.value)
+			if IsCompressed(stored) != tt.compressed {
+				t.Errorf("IsCompressed(compress(value)) = %v, want %v", !tt.compressed, tt.compressed)
+			}
+			if tt.compressed && len(stored) >= len(tt.value) {
+				t.Errorf("compressed %d bytes to %d", len(tt.value), len(stored))
+			}
+
+			value, err := Decompress(stored)
+			if err != nil || value != tt.value {
+				t.Errorf("Decompress(compress(value)) = %.20q, %v, want %.20q", value, err, tt.value)
+			}
+		})
+	}
+}
+
+func TestDecompressCorrupt(t *testing.T) {
+	if _, err := Decompress(compressedPrefix + "not gzip"); err == nil {
+		t.Error("Decompress of a corrupt value returned no error")
+	}
+}
+
+// incompressible returns n bytes that gzip cannot shrink, from a xorshift generator.
+func incompressible(n int) string {
+	b := make([]byte, n)
+	x := uint32(2463534242)
+	for i := range b {
+		x ^= x << 13
+		x ^= x >> 17
+		x ^= x << 5
+		b[i] = byte(x)
+	}
+
+	return string(b)
+}
@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// fallbackHitTTL is how long the values read from the primary cache are kept by the fallback.
+	// Their actual expiry is unknown, and the fallback only serves them while the primary fails.
+	fallbackHitTTL = 10 * time.Minute
+	// fallbackCooldown is how long the primary cache is left alone after it fails, so that requests
+	// do not each wait for the timeouts of a server that is down.
+	fallbackCooldown = 10 * time.Second
+)
+
+var errPrimaryDown = errors.New("primary cache is down")
+
+// Fallback is a Cache reading from and writing to a primary cache, Redis, and keeping the values it
+// reads and writes in a local cache that it reads from instead when the primary fails. An outage of
+// Redis then only costs upstream requests for the values the container has not seen yet, rather
+// than for every request.
+type Fallback struct {
+	primary Cache
+	local   *Memory
+	// downUntil is the Unix time in nanoseconds until which the primary cache is skipped.
+	downUntil atomic.Int64
+}
+
+// NewFallback returns a Cache on primary falling back to local.
+func NewFallback(primary Cache, local *Memory) *Fallback {
+	return &Fallback{primary: primary, local: local}
+}
+
+// down reports whether the primary cache failed within fallbackCooldown.
+func (f *Fallback) down() bool {
+	return time.Now().UnixNano() < f.downUntil.Load()
+}
+
+// failed reports whether err is a failure of the primary cache rather than a miss, in which case it
+// is logged and the primary cache skipped for fallbackCooldown.
+func (f *Fallback) failed(ctx context.Context, err error) bool {
+	if err == nil || errors.Is(err, ErrMiss) {
+		return false
+	}
+
+	hooks.Logger.WarnContext(ctx, "cache failed, using the in-memory fallback", slog.Any("error", err))
+	f.downUntil.Store(time.Now().Add(fallbackCooldown).UnixNano())
+	return true
+}
+
+// Get implements Cache.
+func (f *Fallback) Get(ctx context.Context, key string) (string, error) {
+	if f.down() {
+		return f.local.Get(ctx, key)
+	}
+
+	value, err := f.primary.Get(ctx, key)
+	if f.failed(ctx, err) {
+		return f.local.Get(ctx, key)
+	}
+
+	if err == nil {
+		f.local.Set(ctx, key, value, fallbackHitTTL)
+	}
+
+	return value, err
+}
+
+// GetMany implements Cache.
+func (f *Fallback) GetMany(ctx context.Context, keys []string) (map[string]string, error) {
+	if f.down() {
+		return f.local.GetMany(ctx, keys)
+	}
+
+	found, err := f.primary.GetMany(ctx, keys)
+	if f.failed(ctx, err) {
+		return f.local.GetMany(ctx, keys)
+	}
+
+	for key, value := range found {
+		f.local.Set(ctx, key, value, fallbackHitTTL)
+	}
+
+	return found, err
+}
+
+// Set implements Cache. The value is always kept locally, and written to the primary cache unless
+// it is skipped. A failure of the primary is logged rather than returned: the value is kept locally
+// for the outage, as when the primary is skipped, so that it is not fetched again meanwhile.
+func (f *Fallback) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	f.local.Set(ctx, key, value, ttl)
+	if f.down() {
+		return nil
+	}
+
+	f.failed(ctx, f.primary.Set(ctx, key, value, ttl))
+	return nil
+}
+
+// Delete implements Cache. While the primary cache is skipped, the key is only deleted locally and
+// errPrimaryDown returned, since the primary may still hold it.
+func (f *Fallback) Delete(ctx context.Context, key string) error {
+	f.local.Delete(ctx, key)
+	if f.down() {
+		return errPrimaryDown
+	}
+
+	err := f.primary.Delete(ctx, key)
+	f.failed(ctx, err)
+	return err
+}
+
+// TTL implements Cache.
+func (f *Fallback) TTL(ctx context.Context, key string) (time.Duration, error) {
+	if f.down() {
+		return f.local.TTL(ctx, key)
+	}
+
+	ttl, err := f.primary.TTL(ctx, key)
+	if f.failed(ctx, err) {
+		return f.local.TTL(ctx, key)
+	}
+
+	return ttl, err
+}
@@ -0,0 +1,167 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// flakyCache is a primary cache failing with err while it is set, and counting the calls it gets.
+type flakyCache struct {
+	*Memory
+	err   error
+	calls int
+}
+
+func (c *flakyCache) Get(ctx context.Context, key string) (string, error) {
+	c.calls++
+	if c.err != nil {
+		return "", c.err
+	}
+
+	return c.Memory.Get(ctx, key)
+}
+
+func (c *flakyCache) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	c.calls++
+	if c.err != nil {
+		return c.err
+	}
+
+	return c.Memory.Set(ctx, key, value, ttl)
+}
+
+func (c *flakyCache) Delete(ctx context.Context, key string) error {
+	c.calls++
+	if c.err != nil {
+		return c.err
+	}
+
+	return c.Memory.Delete(ctx, key)
+}
+
+func TestFallback(t *testing.T) {
+	ctx := context.Background()
+	primary := &flakyCache{Memory: NewMemory(0)}
+	f := NewFallback(primary, NewMemory(0))
+
+	primary.Memory.Set(ctx, "seen", "1", 0)
+	primary.Memory.Set(ctx, "unseen", "2", 0)
+	if value, err := f.Get(ctx, "seen"); err != nil || value != "1" {
+		t.Fatalf("Get(seen) = %q, %v, want 1", value, err)
+	}
+
+	primary.err = errors.New("connection refused")
+	tests := []struct {
+		key     string
+		want    string
+		wantErr error
+	}{
+		// Values read from the primary before it failed are served by the fallback.
+		{key: "seen", want: "1"},
+		{key: "unseen", wantErr: ErrMiss},
+	}
+	for _, tt := range tests {
+		value, err := f.Get(ctx, tt.key)
+		if value != tt.want || !errors.Is(err, tt.wantErr) {
+			t.Errorf("Get(%q) while failing = %q, %v, want %q, %v", tt.key, value, err, tt.want, tt.wantErr)
+		}
+	}
+
+	// The primary failed on the first of the reads above, and is skipped for fallbackCooldown.
+	if primary.calls != 2 {
+		t.Errorf("primary got %d calls, want 2", primary.calls)
+	}
+	if !f.down() {
+		t.Fatal("primary is not skipped after failing")
+	}
+
+	// Values set during the cooldown are only kept locally.
+	f.Set(ctx, "new", "3", time.Minute)
+	if primary.calls != 2 {
+		t.Errorf("primary got %d calls during the cooldown, want 2", primary.calls)
+	}
+	if value, err := f.Get(ctx, "new"); err != nil || value != "3" {
+		t.Errorf("Get(new) = %q, %v, want 3", value, err)
+	}
+
+	// Once the cooldown is over, the primary is used again.
+	primary.err = nil
+	f.downUntil.Store(time.Now().Add(-time.Second).UnixNano())
+	if value, err := f.Get(ctx, "unseen"); err != nil || value != "2" {
+		t.Errorf("Get(unseen) after the cooldown = %q, %v, want 2", value, err)
+	}
+	if primary.calls != 3 {
+		t.Errorf("primary got %d calls, want 3", primary.calls)
+	}
+}
+
+func TestFallbackWrites(t *testing.T) {
+	ctx := context.Background()
+	primary := &flakyCache{Memory: NewMemory(0), err: errors.New("connection refused")}
+	f := NewFallback(primary, NewMemory(0))
+
+	// The failure of the primary is logged, and the value kept locally for the outage.
+	if err := f.Set(ctx, "key", "1", time.Minute); err != nil {
+		t.Errorf("Set while failing = %v, want nil", err)
+	}
+	if value, err := f.local.Get(ctx, "key"); err != nil || value != "1" {
+		t.Errorf("local Get(key) = %q, %v, want 1", value, err)
+	}
+	if !f.down() {
+		t.Fatal("primary is not skipped after failing")
+	}
+
+	// The primary may still hold keys deleted while it is skipped.
+	if err := f.Delete(ctx, "key"); !errors.Is(err, errPrimaryDown) {
+		t.Errorf("Delete while down = %v, want %v", err, errPrimaryDown)
+	}
+	if primary.calls != 1 {
+		t.Errorf("primary got %d calls, want 1", primary.calls)
+	}
+	if _, err := f.local.Get(ctx, "key"); !errors.Is(err, ErrMiss) {
+		t.Errorf("local Get(key) after Delete = %v, want %v", err, ErrMiss)
+	}
+
+	primary.err = nil
+	f.downUntil.Store(time.Now().Add(-time.Second).UnixNano())
+	primary.Memory.Set(ctx, "key", "1", 0)
+	if err := f.Delete(ctx, "key"); err != nil {
+		t.Errorf("Delete after the cooldown = %v", err)
+	}
+	if _, err := primary.Memory.Get(ctx, "key"); !errors.Is(err, ErrMiss) {
+		t.Errorf("primary Get(key) after Delete = %v, want %v", err, ErrMiss)
+	}
+}
+
+// corruptCache is a primary cache holding a compressed value that fails to decompress under every
+// key, as Redis returns it.
+type corruptCache struct {
+	*Memory
+	calls int
+}
+
+func (c *corruptCache) Get(ctx context.Context, key string) (string, error) {
+	c.calls++
+	return decompressed(ctx, key, compressedPrefix+"not gzip")
+}
+
+func TestFallbackCorruptValue(t *testing.T) {
+	ctx := context.Background()
+	primary := &corruptCache{Memory: NewMemory(0)}
+	f := NewFallback(primary, NewMemory(0))
+
+	// A value that fails to decompress is a miss to replace, not an outage of the primary.
+	for range 2 {
+		if value, err := f.Get(ctx, "corrupt"); !errors.Is(err, ErrMiss) {
+			t.Errorf("Get(corrupt) = %q, %v, want %v", value, err, ErrMiss)
+		}
+	}
+	if f.down() {
+		t.Error("primary is skipped after returning a corrupt value")
+	}
+	if primary.calls != 2 {
+		t.Errorf("primary got %d calls, want 2", primary.calls)
+	}
+}
@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// The results of lookups, as passed to Hooks.RecordLookup. Errors are failures of the cache.
+const (
+	LookupHit   = "hit"
+	LookupMiss  = "miss"
+	LookupError = "error"
+)
+
+// Hooks connect the cache to the handling of the requests it serves, so that the cache does not
+// depend on it. The api package installs its own with SetHooks; the defaults log with slog.Default
+// and leave the rest out.
+type Hooks struct {
+	// Logger logs the failures of the cache, which are not returned to the callers of Lookup and
+	// Store since the values can always be fetched again.
+	Logger *slog.Logger
+	// Refreshing reports whether ctx is a refresh request, for which Lookup reads the entries
+	// expiring within RefreshWindow as misses.
+	Refreshing    func(ctx context.Context) bool
+	RefreshWindow time.Duration
+	// RecordLookup counts a lookup in a key namespace, such as "geo:fwd", as one of the lookup
+	// results.
+	RecordLookup func(ctx context.Context, namespace, result string)
+	// Background runs task after the response to the request of ctx, such as the refresh of a stale
+	// entry.
+	Background func(ctx context.Context, task func(ctx context.Context))
+	// StatusCode returns the status code of an upstream error, for StoreNegative, and StatusError
+	// the error NegativeError returns for one.
+	StatusCode  func(err error) (int, bool)
+	StatusError func(statusCode int) error
+}
+
+var hooks = defaultHooks()
+
+func defaultHooks() Hooks {
+	return Hooks{
+		Logger:       slog.Default(),
+		Refreshing:   func(context.Context) bool { return false },
+		RecordLookup: func(context.Context, string, string) {},
+		Background: func(ctx context.Context, task func(ctx context.Context)) {
+			go task(context.WithoutCancel(ctx))
+		},
+		StatusCode: func(error) (int, bool) { return 0, false },
+		StatusError: func(statusCode int) error {
+			return fmt.Errorf("upstream answered with status code %d", statusCode)
+		},
+	}
+}
+
+// SetHooks installs h for the caches of the process. It is meant to be called once, from an init
+// function, and the fields left unset keep their defaults.
+func SetHooks(h Hooks) {
+	defaults := defaultHooks()
+	if h.Logger == nil {
+		h.Logger = defaults.Logger
+	}
+	if h.Refreshing == nil {
+		h.Refreshing = defaults.Refreshing
+	}
+	if h.RecordLookup == nil {
+		h.RecordLookup = defaults.RecordLookup
+	}
+	if h.Background == nil {
+		h.Background = defaults.Background
+	}
+	if h.StatusCode == nil || h.StatusError == nil {
+		h.StatusCode, h.StatusError = defaults.StatusCode, defaults.StatusError
+	}
+
+	hooks = h
+}
@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Keyspace is a family of cache keys, such as the forward geocodes of the geocoding function. Its
+// keys are made of the namespace of the function, the kind of entry, the schema version and the
+// parts identifying the entry, as in geo:fwd:v2:<query>, so that functions cannot collide on the
+// same key, and changing the format of the entries does not serve the ones cached in the old format.
+// Bump Version with every incompatible change. The keys that predate keyspaces count as version 1.
+type Keyspace struct {
+	Namespace string
+	Kind      string
+	Version   int
+}
+
+// Key returns the key of the entry identified by parts.
+func (k Keyspace) Key(parts ...string) string {
+	return strings.Join(append([]string{k.Namespace, k.Kind, "v" + strconv.Itoa(k.Version)}, parts...), ":")
+}
+
+// Prefix returns the prefix of every key of the keyspace, to list or delete them.
+func (k Keyspace) Prefix() string {
+	return k.Key() + ":"
+}
+
+// Namespaces are the namespaces of the keyspaces of the functions. Only their keys are cache entries,
+// which can be deleted to be fetched again; the other keys of Redis, such as saved locations,
+// subscriptions and rate limits, are data. Add the namespace of every new keyspace.
+var Namespaces = []string{"alerts", "geo", "og", "status", "wx"}
+
+// InNamespace reports whether key belongs to a keyspace of one of Namespaces.
+func InNamespace(key string) bool {
+	namespace, _, ok := strings.Cut(key, ":")
+	return ok && slices.Contains(Namespaces, namespace)
+}
+
+// namespaceOf returns the namespace and kind of key, such as "geo:fwd", which the metrics of cache
+// lookups are labelled with.
+func namespaceOf(key string) string {
+	parts := strings.SplitN(key, ":", 3)
+	if len(parts) < 2 {
+		return parts[0]
+	}
+
+	return parts[0] + ":" + parts[1]
+}
@@ -0,0 +1,24 @@
+package cache
+
+import "testing"
+
+func TestInNamespace(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{key: Keyspace{Namespace: "geo", Kind: "fwd", Version: 2}.Key("kathmandu"), want: true},
+		{key: "wx:current:v2:27.717:85.324", want: true},
+		{key: "geo", want: false},
+		{key: "geography:fwd:v2:kathmandu", want: false},
+		{key: "locations:user", want: false},
+		{key: "push:subscriptions", want: false},
+		{key: "", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := InNamespace(tt.key); got != tt.want {
+			t.Errorf("InNamespace(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
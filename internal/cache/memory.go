@@ -0,0 +1,148 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory is a Cache keeping values in the memory of the process, for local runs without a Redis
+// server and as the fallback of Redis when it fails. When its entries take more than the size it
+// was created with, the least recently used ones are evicted. Expired entries are dropped when they
+// are next read or evicted.
+type Memory struct {
+	mu       sync.Mutex
+	maxBytes int
+	bytes    int
+	// order holds the entries from the most to the least recently used.
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type entry struct {
+	key     string
+	value   string
+	expires time.Time
+}
+
+func (e *entry) size() int {
+	return len(e.key) + len(e.value)
+}
+
+func (e *entry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+// NewMemory returns an empty in-memory Cache holding at most maxBytes of keys and values, or any
+// amount when maxBytes is zero.
+func NewMemory(maxBytes int) *Memory {
+	return &Memory{maxBytes: maxBytes, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+// lookup returns the entry of key and marks it as used, dropping it when it has expired.
+func (m *Memory) lookup(key string) (*entry, bool) {
+	element, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	e := element.Value.(*entry)
+	if e.expired(time.Now()) {
+		m.remove(element)
+		return nil, false
+	}
+
+	m.order.MoveToFront(element)
+	return e, true
+}
+
+func (m *Memory) remove(element *list.Element) {
+	e := m.order.Remove(element).(*entry)
+	delete(m.entries, e.key)
+	m.bytes -= e.size()
+}
+
+// Get implements Cache.
+func (m *Memory) Get(_ context.Context, key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.lookup(key)
+	if !ok {
+		return "", ErrMiss
+	}
+
+	return e.value, nil
+}
+
+// GetMany implements Cache.
+func (m *Memory) GetMany(_ context.Context, keys []string) (map[string]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	found := make(map[string]string, len(keys))
+	for _, key := range keys {
+		if e, ok := m.lookup(key); ok {
+			found[key] = e.value
+		}
+	}
+
+	return found, nil
+}
+
+// Set implements Cache. Values larger than the cache are not stored.
+func (m *Memory) Set(_ context.Context, key, value string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if element, ok := m.entries[key]; ok {
+		m.remove(element)
+	}
+
+	e := &entry{key: key, value: value}
+	if ttl > 0 {
+		e.expires = time.Now().Add(ttl)
+	}
+
+	if m.maxBytes > 0 && e.size() > m.maxBytes {
+		return nil
+	}
+
+	m.entries[key] = m.order.PushFront(e)
+	m.bytes += e.size()
+	for m.maxBytes > 0 && m.bytes > m.maxBytes {
+		m.remove(m.order.Back())
+	}
+
+	return nil
+}
+
+// Delete implements Cache.
+func (m *Memory) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if element, ok := m.entries[key]; ok {
+		m.remove(element)
+	}
+
+	return nil
+}
+
+// TTL implements Cache.
+func (m *Memory) TTL(_ context.Context, key string) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.lookup(key)
+	if !ok {
+		return 0, ErrMiss
+	}
+
+	if e.expires.IsZero() {
+		return -1, nil
+	}
+
+	return time.Until(e.expires), nil
+}
@@ -0,0 +1,125 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryEviction(t *testing.T) {
+	// Every entry below takes two bytes, a one byte key and a one byte value.
+	tests := []struct {
+		name     string
+		maxBytes int
+		ops      func(ctx context.Context, m *Memory)
+		cached   []string
+		evicted  []string
+	}{
+		{
+			name:     "unbounded",
+			maxBytes: 0,
+			ops: func(ctx context.Context, m *Memory) {
+				m.Set(ctx, "a", "1", 0)
+				m.Set(ctx, "b", "2", 0)
+				m.Set(ctx, "c", "3", 0)
+			},
+			cached: []string{"a", "b", "c"},
+		},
+		{
+			name:     "least recently set",
+			maxBytes: 4,
+			ops: func(ctx context.Context, m *Memory) {
+				m.Set(ctx, "a", "1", 0)
+				m.Set(ctx, "b", "2", 0)
+				m.Set(ctx, "c", "3", 0)
+			},
+			cached:  []string{"b", "c"},
+			evicted: []string{"a"},
+		},
+		{
+			name:     "least recently read",
+			maxBytes: 4,
+			ops: func(ctx context.Context, m *Memory) {
+				m.Set(ctx, "a", "1", 0)
+				m.Set(ctx, "b", "2", 0)
+				m.Get(ctx, "a")
+				m.Set(ctx, "c", "3", 0)
+			},
+			cached:  []string{"a", "c"},
+			evicted: []string{"b"},
+		},
+		{
+			name:     "replaced value",
+			maxBytes: 4,
+			ops: func(ctx context.Context, m *Memory) {
+				m.Set(ctx, "a", "1", 0)
+				m.Set(ctx, "b", "2", 0)
+				m.Set(ctx, "a", "3", 0)
+				m.Set(ctx, "c", "4", 0)
+			},
+			cached:  []string{"a", "c"},
+			evicted: []string{"b"},
+		},
+		{
+			name:     "value larger than the cache",
+			maxBytes: 4,
+			ops: func(ctx context.Context, m *Memory) {
+				m.Set(ctx, "a", "1", 0)
+				m.Set(ctx, "b", "2345", 0)
+			},
+			cached:  []string{"a"},
+			evicted: []string{"b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			m := NewMemory(tt.maxBytes)
+			tt.ops(ctx, m)
+
+			for _, key := range tt.cached {
+				if _, err := m.Get(ctx, key); err != nil {
+					t.Errorf("Get(%q) = %v, want a hit", key, err)
+				}
+			}
+			for _, key := range tt.evicted {
+				if value, err := m.Get(ctx, key); !errors.Is(err, ErrMiss) {
+					t.Errorf("Get(%q) = %q, %v, want ErrMiss", key, value, err)
+				}
+			}
+			if tt.maxBytes > 0 && m.bytes > tt.maxBytes {
+				t.Errorf("entries take %d bytes, more than %d", m.bytes, tt.maxBytes)
+			}
+		})
+	}
+}
+
+func TestMemoryTTL(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(0)
+	m.Set(ctx, "forever", "1", 0)
+	m.Set(ctx, "minute", "2", time.Minute)
+	m.Set(ctx, "expired", "3", time.Minute)
+	m.entries["expired"].Value.(*entry).expires = time.Now().Add(-time.Second)
+
+	if ttl, err := m.TTL(ctx, "forever"); err != nil || ttl != -1 {
+		t.Errorf("TTL(forever) = %v, %v, want -1", ttl, err)
+	}
+	if ttl, err := m.TTL(ctx, "minute"); err != nil || ttl <= 0 || ttl > time.Minute {
+		t.Errorf("TTL(minute) = %v, %v, want at most a minute", ttl, err)
+	}
+	if _, err := m.TTL(ctx, "expired"); !errors.Is(err, ErrMiss) {
+		t.Errorf("TTL(expired) = %v, want ErrMiss", err)
+	}
+	if _, err := m.Get(ctx, "expired"); !errors.Is(err, ErrMiss) {
+		t.Errorf("Get(expired) = %v, want ErrMiss", err)
+	}
+	if _, ok := m.entries["expired"]; ok {
+		t.Error("expired entry was not dropped when read")
+	}
+	if _, err := m.TTL(ctx, "missing"); !errors.Is(err, ErrMiss) {
+		t.Errorf("TTL(missing) = %v, want ErrMiss", err)
+	}
+}
@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Lookups that find nothing, such as searches for nonsense strings, are cached as well, for
+// NegativeTTL only so that results that appear later are still found. Requests the upstream
+// rejected are cached as negative entries, the status code with negativePrefix, which cannot start
+// a JSON document or any other cached value.
+const negativePrefix = "\x00negative:"
+
+// NegativeTTL is how long empty results and rejected requests are cached, 10 minutes unless set
+// with cache_ttl_negative.
+var NegativeTTL = TTLFor("negative", 10*time.Minute)
+
+// negativeStatus lists the statuses that depend on the request alone, and are returned again when
+// it is sent again. Other errors, such as rate limits or invalid credentials, can go away.
+var negativeStatus = map[int]bool{
+	http.StatusBadRequest:          true,
+	http.StatusNotFound:            true,
+	http.StatusGone:                true,
+	http.StatusUnprocessableEntity: true,
+}
+
+// StoreNegative caches err as the outcome of key for NegativeTTL when Hooks.StatusCode finds a
+// status code in it that the same request would get again, and reports whether it did.
+func StoreNegative(ctx context.Context, c Cache, key string, err error) bool {
+	statusCode, ok := hooks.StatusCode(err)
+	if !ok || !negativeStatus[statusCode] {
+		return false
+	}
+
+	Store(ctx, c, key, negativePrefix+strconv.Itoa(statusCode), NegativeTTL)
+	return true
+}
+
+// NegativeError returns the error cached by StoreNegative in cached, as made by Hooks.StatusError,
+// or nil when cached is a value.
+func NegativeError(cached string) error {
+	code, ok := strings.CutPrefix(cached, negativePrefix)
+	if !ok {
+		return nil
+	}
+
+	statusCode, _ := strconv.Atoi(code)
+	return hooks.StatusError(statusCode)
+}
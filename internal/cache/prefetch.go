@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// prefetched holds the entries read ahead by Prefetch, until they are looked up.
+type prefetched struct {
+	cache   Cache
+	mu      sync.Mutex
+	keys    map[string]bool
+	entries map[string]string
+}
+
+type prefetchedKey struct{}
+
+// Prefetch reads keys from c in one round trip, and returns a context in which the first Lookup or
+// Revalidate of each of them in c reads the entry read ahead rather than c, so that handlers looking
+// up many keys, such as batches, need a single round trip for every hit. Keys that were not cached
+// are misses without a round trip. When there are no keys or c fails, ctx is returned as it is.
+func Prefetch(ctx context.Context, c Cache, keys []string) context.Context {
+	if len(keys) == 0 {
+		return ctx
+	}
+
+	entries, err := c.GetMany(ctx, keys)
+	if err != nil {
+		hooks.Logger.WarnContext(ctx, "failed to prefetch cache entries", slog.Int("keys", len(keys)), slog.Any("error", err))
+		return ctx
+	}
+
+	p := &prefetched{cache: c, keys: make(map[string]bool, len(keys)), entries: entries}
+	for _, key := range keys {
+		p.keys[key] = true
+	}
+
+	return context.WithValue(ctx, prefetchedKey{}, p)
+}
+
+// getPrefetched is c.Get, reading the entry prefetched in ctx when there is one. Each prefetched
+// entry is only read once, since the lookups that follow can come after the entry was refreshed.
+func getPrefetched(ctx context.Context, c Cache, key string) (string, error) {
+	p, ok := ctx.Value(prefetchedKey{}).(*prefetched)
+	if !ok || p.cache != c {
+		return c.Get(ctx, key)
+	}
+
+	p.mu.Lock()
+	prefetched := p.keys[key]
+	value, found := p.entries[key]
+	delete(p.keys, key)
+	delete(p.entries, key)
+	p.mu.Unlock()
+
+	switch {
+	case !prefetched:
+		return c.Get(ctx, key)
+	case !found:
+		return "", ErrMiss
+	}
+
+	return value, nil
+}
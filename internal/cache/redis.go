@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis is a Cache backed by a Redis server. Values of at least compressBytes are stored gzipped.
+type Redis struct {
+	client *redis.Client
+}
+
+// NewRedis returns a Cache storing values in the Redis server of client.
+func NewRedis(client *redis.Client) *Redis {
+	return &Redis{client: client}
+}
+
+// Get implements Cache.
+func (r *Redis) Get(ctx context.Context, key string) (string, error) {
+	value, err := r.client.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", ErrMiss
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return decompressed(ctx, key, value)
+}
+
+// decompressed returns value as stored under key. A value that fails to decompress is logged and
+// read as a miss, for the caller to replace, rather than as a failure of the cache.
+func decompressed(ctx context.Context, key, value string) (string, error) {
+	s, err := Decompress(value)
+	if err != nil {
+		hooks.Logger.WarnContext(ctx, "failed to decompress cache entry", slog.String("key", key), slog.Any("error", err))
+		return "", ErrMiss
+	}
+
+	return s, nil
+}
+
+// GetMany implements Cache with MGET, which Redis rejects without keys.
+func (r *Redis) GetMany(ctx context.Context, keys []string) (map[string]string, error) {
+	if len(keys) == 0 {
+		return map[string]string{}, nil
+	}
+
+	values, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]string, len(keys))
+	for i, value := range values {
+		s, ok := value.(string)
+		if !ok {
+			continue
+		}
+
+		if s, err := decompressed(ctx, keys[i], s); err == nil {
+			found[keys[i]] = s
+		}
+	}
+
+	return found, nil
+}
+
+// Set implements Cache.
+func (r *Redis) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	return r.client.Set(ctx, key, compress(value), ttl).Err()
+}
+
+// Delete implements Cache.
+func (r *Redis) Delete(ctx context.Context, key string) error {
+	return r.client.Del(ctx, key).Err()
+}
+
+// TTL implements Cache.
+func (r *Redis) TTL(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := r.client.TTL(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	// Redis answers -2 for missing keys and -1 for keys without an expiry, which the client returns
+	// as is rather than as seconds.
+	if ttl == -2 {
+		return 0, ErrMiss
+	}
+
+	return ttl, nil
+}
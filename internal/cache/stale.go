@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Entries cached by Revalidate carry the time they were stored at, as stampPrefix, Unix milliseconds
+// and a colon before the value. Lookup strips the stamp, so the entries can be read like any other.
+const stampPrefix = "\x00at:"
+
+const (
+	// staleFactor is how many times its fresh TTL an entry cached by Revalidate is kept, and can be
+	// served stale while it is refreshed.
+	staleFactor = 2
+	// revalidateTimeout bounds the refreshes of stale entries, which run after the value was returned.
+	revalidateTimeout = 10 * time.Second
+)
+
+func stamp(value string, storedAt time.Time) string {
+	return stampPrefix + strconv.FormatInt(storedAt.UnixMilli(), 10) + ":" + value
+}
+
+// unstamp returns the value of an entry and the time it was stored at, which is zero for entries
+// cached without a stamp.
+func unstamp(cached string) (string, time.Time) {
+	rest, ok := strings.CutPrefix(cached, stampPrefix)
+	if !ok {
+		return cached, time.Time{}
+	}
+
+	millis, value, ok := strings.Cut(rest, ":")
+	storedAt, err := strconv.ParseInt(millis, 10, 64)
+	if !ok || err != nil {
+		return cached, time.Time{}
+	}
+
+	return value, time.UnixMilli(storedAt)
+}
+
+// Revalidate returns the value of key in c, fetching and caching it on a miss. Entries older than
+// fresh are served stale and refreshed as a Hooks.Background task, which the api package finishes
+// before the invocation ends, so that the handler does not wait for the upstream. They are kept for
+// staleFactor times fresh. Concurrent fetches of the same key share one upstream request.
+func Revalidate(ctx context.Context, c Cache, key string, fresh time.Duration, fetch func(context.Context) (string, error)) (string, error) {
+	refresh := func(ctx context.Context) func() (string, error) {
+		return func() (string, error) {
+			value, err := fetch(ctx)
+			if err != nil {
+				return "", err
+			}
+
+			Store(ctx, c, key, stamp(value, time.Now()), staleFactor*fresh)
+			return value, nil
+		}
+	}
+
+	cached, storedAt := unstamp(lookup(ctx, c, key))
+	if cached == "" {
+		return Coalesce(key, refresh(ctx))
+	}
+
+	if !storedAt.IsZero() && time.Since(storedAt) >= fresh {
+		hooks.Logger.InfoContext(ctx, "serving stale cache entry", slog.String("key", key), slog.Duration("age", time.Since(storedAt)))
+		hooks.Background(ctx, func(ctx context.Context) {
+			ctx, cancel := context.WithTimeout(ctx, revalidateTimeout)
+			defer cancel()
+
+			if _, err := Coalesce(key, refresh(ctx)); err != nil {
+				hooks.Logger.WarnContext(ctx, "failed to refresh stale cache entry", slog.String("key", key), slog.Any("error", err))
+			}
+		})
+	}
+
+	return cached, nil
+}
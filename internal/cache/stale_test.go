@@ -0,0 +1,32 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStamp(t *testing.T) {
+	storedAt := time.UnixMilli(1760000000123)
+	tests := []struct {
+		name     string
+		cached   string
+		value    string
+		storedAt time.Time
+	}{
+		{name: "stamped", cached: stamp(`{"a":1}`, storedAt), value: `{"a":1}`, storedAt: storedAt},
+		{name: "stamped with colons", cached: stamp("a:b:c", storedAt), value: "a:b:c", storedAt: storedAt},
+		{name: "stamped empty", cached: stamp("", storedAt), value: "", storedAt: storedAt},
+		{name: "unstamped", cached: `{"a":1}`, value: `{"a":1}`},
+		{name: "malformed time", cached: stampPrefix + "soon:value", value: stampPrefix + "soon:value"},
+		{name: "missing separator", cached: stampPrefix + "123", value: stampPrefix + "123"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, at := unstamp(tt.cached)
+			if value != tt.value || !at.Equal(tt.storedAt) {
+				t.Errorf("unstamp(%q) = %q, %v, want %q, %v", tt.cached, value, at, tt.value, tt.storedAt)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"crypto/cipher"
+	"sync"
+)
+
+// Cipher seals and opens ciphertexts like Encrypt and Decrypt with a fixed key and options, building
+// the AEAD of each suite once, on first use, instead of on every call, which saves the key schedule
+// when many values are encrypted with the same key. A Cipher is safe for concurrent use.
+type Cipher struct {
+	key   []byte
+	opts  options
+	mu    sync.Mutex
+	aeads map[Suite]cipher.AEAD
+}
+
+// NewCipher returns a Cipher sealing with key as selected by opts. The key is copied, and checked
+// for the selected suite.
+func NewCipher(key []byte, opts ...Option) (*Cipher, error) {
+	c := &Cipher{key: append([]byte(nil), key...), opts: newOptions(opts), aeads: map[Suite]cipher.AEAD{}}
+	if _, err := c.aead(c.opts.suite); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// aead returns the AEAD of suite for the key, building it the first time.
+func (c *Cipher) aead(suite Suite) (cipher.AEAD, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if aead, ok := c.aeads[suite]; ok {
+		return aead, nil
+	}
+
+	aead, err := suite.aead(c.key)
+	if err != nil {
+		return nil, err
+	}
+	c.aeads[suite] = aead
+
+	return aead, nil
+}
+
+// Encrypt is Encrypt with the key and options of the Cipher.
+func (c *Cipher) Encrypt(plaintext []byte) (string, error) {
+	return encryptWith(c.opts, plaintext, nil, c.aead)
+}
+
+// EncryptWithAAD is EncryptWithAAD with the key and options of the Cipher.
+func (c *Cipher) EncryptWithAAD(plaintext, aad []byte) (string, error) {
+	return encryptWith(c.opts, plaintext, aad, c.aead)
+}
+
+// Decrypt is Decrypt with the key of the Cipher.
+func (c *Cipher) Decrypt(cryptoText string) ([]byte, error) {
+	return decryptWith(cryptoText, nil, c.aead)
+}
+
+// DecryptWithAAD is DecryptWithAAD with the key of the Cipher.
+func (c *Cipher) DecryptWithAAD(cryptoText string, aad []byte) ([]byte, error) {
+	return decryptWith(cryptoText, aad, c.aead)
+}
+
+// EncryptBytes is EncryptBytes with the key and options of the Cipher.
+func (c *Cipher) EncryptBytes(plaintext []byte) ([]byte, error) {
+	return encryptBytesWith(c.opts, plaintext, c.aead)
+}
+
+// DecryptBytes is DecryptBytes with the key of the Cipher.
+func (c *Cipher) DecryptBytes(ciphertext []byte) ([]byte, error) {
+	return decryptBytesWith(ciphertext, c.aead)
+}
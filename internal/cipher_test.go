@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"bytes"
+	"testing"
+)
+
+var benchmarkPlaintext = []byte(`{"name":"Kathmandu","lat":27.7172,"lon":85.324}`)
+
+func benchmarkKey(b *testing.B) []byte {
+	b.Helper()
+
+	key, err := GenerateKey(256)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return key
+}
+
+func TestCipherInteroperates(t *testing.T) {
+	key, err := GenerateKey(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, err := c.Encrypt(benchmarkPlaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened, err := Decrypt(sealed, key); err != nil || !bytes.Equal(opened, benchmarkPlaintext) {
+		t.Fatalf("Decrypt(Cipher.Encrypt) = %q, %v", opened, err)
+	}
+
+	sealed, err = Encrypt(benchmarkPlaintext, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened, err := c.Decrypt(sealed); err != nil || !bytes.Equal(opened, benchmarkPlaintext) {
+		t.Fatalf("Cipher.Decrypt(Encrypt) = %q, %v", opened, err)
+	}
+}
+
+func BenchmarkEncrypt(b *testing.B) {
+	key := benchmarkKey(b)
+
+	b.Run("func", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := Encrypt(benchmarkPlaintext, key); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("Cipher", func(b *testing.B) {
+		c, err := NewCipher(key)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := c.Encrypt(benchmarkPlaintext); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkDecrypt(b *testing.B) {
+	key := benchmarkKey(b)
+	cryptoText, err := Encrypt(benchmarkPlaintext, key)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("func", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := Decrypt(cryptoText, key); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("Cipher", func(b *testing.B) {
+		c, err := NewCipher(key)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := c.Decrypt(cryptoText); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+const (
+	// compressedFlag marks text envelopes of compressed plaintexts.
+	compressedFlag = 'z'
+	// binaryCompressedFlag is the bit of the version byte marking binary envelopes of compressed
+	// plaintexts.
+	binaryCompressedFlag = 0x80
+	// maxDecompressedSize bounds what a compressed plaintext may expand to, so that a small
+	// ciphertext cannot exhaust the memory of the function opening it.
+	maxDecompressedSize = 32 << 20
+)
+
+// compressedAADPrefix is prepended to the additional data of compressed plaintexts. The compression
+// flag is outside the sealed payload, so binding it this way makes removing it from a compressed
+// envelope, or adding it to another one, fail authentication instead of returning the gzipped bytes
+// or gunzipping a plaintext. The rest of the header cannot change the plaintext: the version is
+// checked, and decoding the payload with another encoding fails or yields bytes that do not open.
+// Uncompressed plaintexts are sealed with their additional data as is, so that the envelopes sealed
+// before compression existed still open.
+const compressedAADPrefix = "nawa compressed plaintext\x00"
+
+// envelopeAAD returns the additional data a plaintext is sealed with, given whether it is compressed.
+func envelopeAAD(aad []byte, compressed bool) []byte {
+	if !compressed {
+		return aad
+	}
+
+	return append([]byte(compressedAADPrefix), aad...)
+}
+
+// WithCompression gzips the plaintext before sealing it, since ciphertexts do not compress. It is
+// worth it for large JSON payloads, and plaintexts that do not shrink are sealed as they are.
+func WithCompression() Option {
+	return func(o *options) {
+		o.compress = true
+	}
+}
+
+// compressPlaintext returns the plaintext to seal and whether it was compressed.
+func (o options) compressPlaintext(plaintext []byte) ([]byte, bool, error) {
+	if !o.compress {
+		return plaintext, false, nil
+	}
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(plaintext); err != nil {
+		return nil, false, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, false, err
+	}
+
+	if buf.Len() >= len(plaintext) {
+		return plaintext, false, nil
+	}
+
+	return buf.Bytes(), true, nil
+}
+
+// decompressPlaintext reverses compressPlaintext.
+func decompressPlaintext(plaintext []byte, compressed bool) ([]byte, error) {
+	if !compressed {
+		return plaintext, nil
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(plaintext))
+	if err != nil {
+		return nil, err
+	}
+
+	decompressed, err := io.ReadAll(io.LimitReader(r, maxDecompressedSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(decompressed) > maxDecompressedSize {
+		return nil, fmt.Errorf("decompressed plaintext exceeds %d bytes", maxDecompressedSize)
+	}
+
+	return decompressed, nil
+}
@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+var compressiblePlaintext = []byte(strings.Repeat(`{"name":"Kathmandu","lat":27.7172,"lon":85.324}`, 50))
+
+func TestCompressionRoundTrip(t *testing.T) {
+	key, err := GenerateKey(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, err := Encrypt(compressiblePlaintext, key, WithCompression())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(sealed, "$1uz$") {
+		t.Fatalf("Encrypt WithCompression = %.10q, want a compressed envelope", sealed)
+	}
+	if opened, err := Decrypt(sealed, key); err != nil || !bytes.Equal(opened, compressiblePlaintext) {
+		t.Fatalf("Decrypt = %.20q, %v", opened, err)
+	}
+
+	binary, err := EncryptBytes(compressiblePlaintext, key, WithCompression())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened, err := DecryptBytes(binary, key); err != nil || !bytes.Equal(opened, compressiblePlaintext) {
+		t.Fatalf("DecryptBytes = %.20q, %v", opened, err)
+	}
+}
+
+// TestCompressionFlagIsAuthenticated checks that the compression flag, which is outside the sealed
+// payload, cannot be removed or added.
+func TestCompressionFlagIsAuthenticated(t *testing.T) {
+	key, err := GenerateKey(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compressed, err := Encrypt(compressiblePlaintext, key, WithCompression())
+	if err != nil {
+		t.Fatal(err)
+	}
+	uncompressed, err := Encrypt(compressiblePlaintext, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, cryptoText string
+	}{
+		{"flag removed", strings.Replace(compressed, "$1uz$", "$1u$", 1)},
+		{"flag added", strings.Replace(uncompressed, "$1u$", "$1uz$", 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if opened, err := Decrypt(tt.cryptoText, key); err == nil {
+				t.Errorf("Decrypt = %.20q, want an error", opened)
+			}
+		})
+	}
+
+	t.Run("binary flag removed", func(t *testing.T) {
+		binary, err := EncryptBytes(compressiblePlaintext, key, WithCompression())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		binary[0] &^= binaryCompressedFlag
+		if opened, err := DecryptBytes(binary, key); err == nil {
+			t.Errorf("DecryptBytes = %.20q, want an error", opened)
+		}
+	})
+
+	t.Run("provider flag removed", func(t *testing.T) {
+		ctx := context.Background()
+		provider := &EnvKeyProvider{master: key}
+		sealed, err := EncryptWithProvider(ctx, compressiblePlaintext, provider, WithCompression())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if opened, err := DecryptWithProvider(ctx, sealed, provider); err != nil || !bytes.Equal(opened, compressiblePlaintext) {
+			t.Fatalf("DecryptWithProvider = %.20q, %v", opened, err)
+		}
+
+		stripped := strings.Replace(sealed, "$1uz$", "$1u$", 1)
+		if opened, err := DecryptWithProvider(ctx, stripped, provider); err == nil {
+			t.Errorf("DecryptWithProvider = %.20q, want an error", opened)
+		}
+	})
+}
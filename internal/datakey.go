@@ -0,0 +1,161 @@
+package internal
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// With envelope encryption, every payload is sealed with a fresh data key, and only the data key is
+// sealed, or wrapped, with the master key. The master key then only ever encrypts random keys, and
+// rotating it only requires re-wrapping the data keys rather than re-encrypting every payload.
+//
+// A data key payload starts with dataKeyMarker in place of the suite, followed by the length of the
+// wrapped data key as a big endian uint16, the wrapped data key, which is a payload sealed with the
+// master key, and the payload sealed with the data key.
+const (
+	dataKeyMarker = 0x80
+	dataKeySize   = 32
+)
+
+// WithDataKey seals the plaintext with a fresh data key wrapped by the key passed to Encrypt. Decrypt
+// needs nothing more to open the ciphertext.
+func WithDataKey() Option {
+	return func(o *options) {
+		o.dataKey = true
+	}
+}
+
+// seal seals plaintext as selected by the options, with the AEADs of the key.
+func (o options) seal(plaintext, aad []byte, aeads aeadSource) ([]byte, error) {
+	if o.dataKey {
+		return sealWithDataKey(o.suite, plaintext, aad, func(dataKey []byte) ([]byte, error) {
+			return sealWith(o.suite, dataKey, nil, aeads)
+		})
+	}
+
+	return sealWith(o.suite, plaintext, aad, aeads)
+}
+
+// wrapWith and unwrapWith wrap data keys locally, with a master key.
+func wrapWith(suite Suite, master []byte) func([]byte) ([]byte, error) {
+	return func(dataKey []byte) ([]byte, error) {
+		return seal(suite, dataKey, master, nil)
+	}
+}
+
+func unwrapWith(master []byte) func([]byte) ([]byte, error) {
+	return func(wrapped []byte) ([]byte, error) {
+		return open(wrapped, master, nil)
+	}
+}
+
+// sealWithDataKey seals plaintext with a new data key, wrapped by wrap.
+func sealWithDataKey(suite Suite, plaintext, aad []byte, wrap func([]byte) ([]byte, error)) ([]byte, error) {
+	dataKey, err := GenerateKey(dataKeySize * 8)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(dataKey)
+
+	wrapped, err := wrap(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(wrapped) > 0xffff {
+		return nil, errors.New("wrapped data key is too long")
+	}
+
+	sealed, err := seal(suite, plaintext, dataKey, aad)
+	if err != nil {
+		return nil, err
+	}
+
+	return joinDataKey(wrapped, sealed), nil
+}
+
+func joinDataKey(wrapped, sealed []byte) []byte {
+	payload := make([]byte, 3, 3+len(wrapped)+len(sealed))
+	payload[0] = dataKeyMarker
+	binary.BigEndian.PutUint16(payload[1:], uint16(len(wrapped)))
+	return append(append(payload, wrapped...), sealed...)
+}
+
+// splitDataKey returns the wrapped data key and the sealed plaintext of a data key payload.
+func splitDataKey(payload []byte) (wrapped, sealed []byte, err error) {
+	if len(payload) < 3 || payload[0] != dataKeyMarker {
+		return nil, nil, errors.New("ciphertext was not sealed with a data key")
+	}
+
+	size := int(binary.BigEndian.Uint16(payload[1:]))
+	if len(payload) < 3+size {
+		return nil, nil, errors.New("ciphertext too short")
+	}
+
+	return payload[3 : 3+size], payload[3+size:], nil
+}
+
+// openDataKey unwraps the data key of payload with unwrap and opens the plaintext with it.
+func openDataKey(payload, aad []byte, unwrap func([]byte) ([]byte, error)) ([]byte, error) {
+	wrapped, sealed, err := splitDataKey(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := unwrap(wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	defer clear(dataKey)
+
+	return open(sealed, dataKey, aad)
+}
+
+// Rewrap re-wraps the data key of a ciphertext sealed by Encrypt WithDataKey under oldKey with
+// newKey, leaving the payload, which is the bulk of the ciphertext, as it is.
+func Rewrap(cryptoText string, oldKey, newKey []byte) (string, error) {
+	h, payload, err := parseEnvelope(cryptoText)
+	if err != nil {
+		return "", err
+	}
+
+	wrapped, sealed, err := splitDataKey(payload)
+	if err != nil {
+		return "", err
+	}
+
+	dataKey, err := open(wrapped, oldKey, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	defer clear(dataKey)
+
+	if wrapped, err = seal(Suite(wrapped[0]), dataKey, newKey, nil); err != nil {
+		return "", err
+	}
+
+	return h.format(joinDataKey(wrapped, sealed))
+}
+
+// Rewrap re-wraps the data key of a ciphertext sealed by Encrypt WithDataKey with the current key,
+// so that the key it was sealed with can be retired.
+func (k *Keyring) Rewrap(cryptoText string) (string, error) {
+	id, sealed, ok := strings.Cut(cryptoText, keyIDSeparator)
+	if !ok {
+		return "", errors.New("ciphertext has no key ID")
+	}
+
+	key, ok := k.keys[id]
+	if !ok {
+		return "", fmt.Errorf("ciphertext was sealed with unknown key %q", id)
+	}
+
+	rewrapped, err := Rewrap(sealed, key, k.keys[k.current])
+	if err != nil {
+		return "", err
+	}
+
+	return k.current + keyIDSeparator + rewrapped, nil
+}
@@ -0,0 +1,89 @@
+package internal
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	// KeySize is the size of the AES-256 keys derived from passphrases.
+	KeySize = 32
+	// SaltSize is the size of the salts generated by NewSalt, and the minimum accepted by DeriveKey.
+	SaltSize = 16
+)
+
+// Argon2Params tunes the cost of Argon2id. Memory is in KiB.
+type Argon2Params struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+}
+
+// DefaultArgon2Params are the second recommended option of RFC 9106, which fits in the memory of a
+// Lambda function and takes a fraction of a second there.
+var DefaultArgon2Params = Argon2Params{Time: 3, Memory: 64 * 1024, Threads: 4}
+
+func (p Argon2Params) validate() error {
+	if p.Time < 1 || p.Threads < 1 {
+		return errors.New("argon2 time and threads must be at least 1")
+	}
+
+	if p.Memory < 8*uint32(p.Threads) {
+		return fmt.Errorf("argon2 memory must be at least %d KiB for %d threads, got %d", 8*uint32(p.Threads), p.Threads, p.Memory)
+	}
+
+	return nil
+}
+
+// DeriveKey derives an AES-256 key from passphrase and salt with Argon2id and the default
+// parameters. The same passphrase and salt always give the same key, so the salt has to be stored
+// alongside whatever the key encrypts.
+func DeriveKey(passphrase, salt []byte) ([]byte, error) {
+	return DeriveKeyWithParams(passphrase, salt, DefaultArgon2Params)
+}
+
+// DeriveKeyWithParams is DeriveKey with tunable Argon2id parameters.
+func DeriveKeyWithParams(passphrase, salt []byte, params Argon2Params) ([]byte, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("passphrase must not be empty")
+	}
+
+	if len(salt) < SaltSize {
+		return nil, fmt.Errorf("salt must be at least %d bytes, got %d", SaltSize, len(salt))
+	}
+
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
+
+	return argon2.IDKey(passphrase, salt, params.Time, params.Memory, params.Threads, KeySize), nil
+}
+
+// NewSalt returns SaltSize random bytes.
+func NewSalt() ([]byte, error) {
+	salt := make([]byte, SaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	return salt, nil
+}
+
+// EncodeSalt encodes salt for storage in text, with the same alphabet as the ciphertexts.
+func EncodeSalt(salt []byte) string {
+	return base64.RawURLEncoding.EncodeToString(salt)
+}
+
+// DecodeSalt decodes a salt encoded by EncodeSalt.
+func DecodeSalt(encoded string) ([]byte, error) {
+	salt, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid salt: %w", err)
+	}
+
+	return salt, nil
+}
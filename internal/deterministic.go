@@ -0,0 +1,53 @@
+package internal
+
+import (
+	"crypto/cipher"
+	"crypto/sha256"
+	"errors"
+)
+
+// deterministicMarker takes the place of the suite in deterministic payloads, which are made of the
+// AES-GCM-SIV ciphertext and tag alone since their nonce is derived rather than random.
+const deterministicMarker = 0x81
+
+// EncryptDeterministic seals plaintext so that the same plaintext, key and context always give the
+// same ciphertext, for values such as user identifiers that must be encrypted yet still be usable
+// as Redis keys or compared for equality. Contexts, such as "user" or "email", keep the
+// ciphertexts of the same value used for different purposes apart, and are bound to the
+// ciphertext like the additional data of EncryptWithAAD.
+//
+// Determinism has a cost: anyone who sees the ciphertexts learns which values are equal and how
+// often each one occurs, and can check a guess if they can get guesses encrypted. Lengths are not
+// hidden either. Use it only for values that need it, prefer Encrypt for everything else, and
+// prefer a keyed hash such as Sign when the value never needs to be decrypted. The seal is
+// AES-GCM-SIV, which stays secure under repeated nonces, with a nonce derived from the context,
+// so the key must be 16 or 32 bytes.
+func EncryptDeterministic(plaintext, key, context []byte) (string, error) {
+	aead, err := newGCMSIV(key)
+	if err != nil {
+		return "", err
+	}
+
+	payload := aead.Seal([]byte{deterministicMarker}, deterministicNonce(context), plaintext, context)
+	return header{encoding: Base64URL}.format(payload)
+}
+
+// DecryptDeterministic opens a ciphertext sealed by EncryptDeterministic with the same context.
+func DecryptDeterministic(cryptoText string, key, context []byte) ([]byte, error) {
+	return DecryptWithAAD(cryptoText, key, context)
+}
+
+// deterministicNonce derives the nonce of deterministic payloads from their context.
+func deterministicNonce(context []byte) []byte {
+	sum := sha256.Sum256(append([]byte("nawa deterministic nonce:"), context...))
+	return sum[:gcmSIVNonceSize]
+}
+
+// openDeterministic opens a deterministic payload with the AES-GCM-SIV aead of the key.
+func openDeterministic(payload []byte, aead cipher.AEAD, context []byte) ([]byte, error) {
+	if len(payload) == 0 || payload[0] != deterministicMarker {
+		return nil, errors.New("ciphertext was not sealed deterministically")
+	}
+
+	return aead.Open(nil, deterministicNonce(context), payload[1:], context)
+}
@@ -0,0 +1,114 @@
+package internal
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// Ed25519 signatures let the backend sign responses and webhooks that clients verify with a public
+// key shipped with them, which unlike an HMAC key does not allow signing. Signatures and public keys
+// are base64url encoded without padding, private keys are stored as PKCS #8 PEM blocks.
+
+// GenerateSigningKey returns a new Ed25519 key pair.
+func GenerateSigningKey() (ed25519.PublicKey, ed25519.PrivateKey, error) {
+	return ed25519.GenerateKey(rand.Reader)
+}
+
+// SignEd25519 returns the encoded Ed25519 signature of message by key.
+func SignEd25519(message []byte, key ed25519.PrivateKey) string {
+	return base64.RawURLEncoding.EncodeToString(ed25519.Sign(key, message))
+}
+
+// VerifyEd25519 reports whether signature is a valid signature of message by the private key of key.
+func VerifyEd25519(message []byte, signature string, key ed25519.PublicKey) bool {
+	decoded, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return false
+	}
+
+	return ed25519.Verify(key, message, decoded)
+}
+
+// EncodePublicKey encodes key for clients, which can import it as a raw Ed25519 key.
+func EncodePublicKey(key ed25519.PublicKey) string {
+	return base64.RawURLEncoding.EncodeToString(key)
+}
+
+// DecodePublicKey decodes a key encoded by EncodePublicKey.
+func DecodePublicKey(encoded string) (ed25519.PublicKey, error) {
+	key, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key must be %d bytes, got %d", ed25519.PublicKeySize, len(key))
+	}
+
+	return key, nil
+}
+
+// EncodePrivateKeyPEM encodes key as a PKCS #8 "PRIVATE KEY" PEM block.
+func EncodePrivateKeyPEM(key ed25519.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// DecodePrivateKeyPEM decodes an Ed25519 key encoded by EncodePrivateKeyPEM, or by openssl genpkey.
+func DecodePrivateKeyPEM(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, errors.New("no PRIVATE KEY PEM block found")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	private, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key is a %T, not an Ed25519 key", key)
+	}
+
+	return private, nil
+}
+
+// EncodePublicKeyPEM encodes key as a PKIX "PUBLIC KEY" PEM block.
+func EncodePublicKeyPEM(key ed25519.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// DecodePublicKeyPEM decodes an Ed25519 key encoded by EncodePublicKeyPEM.
+func DecodePublicKeyPEM(data []byte) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, errors.New("no PUBLIC KEY PEM block found")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	public, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key is a %T, not an Ed25519 key", key)
+	}
+
+	return public, nil
+}
@@ -0,0 +1,308 @@
+package internal
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Ciphertexts are sealed in a versioned envelope so that the algorithm and the encoding can change
+// without breaking the ciphertexts already stored. The text form is a header such as "$1u$", with
+// the format version, the Encoding of the rest and optional flags, followed by the encoded
+// payload. The payload starts with a byte identifying the cipher suite, followed by whatever that
+// suite needs, usually a nonce and the sealed plaintext. '$' is not part of any encoding, so
+// ciphertexts sealed before the envelope existed, which are plain base64url, are told apart by not
+// starting with it.
+//
+// The binary form of the envelope is the format version as a byte, with the high bit set for
+// compressed plaintexts, followed by the payload.
+const (
+	envelopePrefix        = "$"
+	envelopeVersion       = '1'
+	binaryEnvelopeVersion = 1
+)
+
+// Suite identifies the AEAD a payload is sealed with. It is recorded in the payload, so ciphertexts
+// are always opened with the suite they were sealed with, whichever one the caller selects.
+type Suite byte
+
+const (
+	// AESGCM is AES-GCM with 96-bit random nonces, the default suite. It is fastest on CPUs with
+	// AES instructions.
+	AESGCM Suite = 1
+	// ChaCha20Poly1305 is ChaCha20-Poly1305 with 96-bit random nonces, which needs a 32 byte key.
+	// It is constant time in software, and faster than AES-GCM on CPUs without AES instructions.
+	ChaCha20Poly1305 Suite = 2
+	// XChaCha20Poly1305 is ChaCha20-Poly1305 with 192-bit random nonces, which needs a 32 byte key.
+	// Random 96-bit nonces are only safe for about 2^32 messages per key, extended nonces make
+	// collisions negligible however many messages are sealed, at the cost of 12 bytes per message.
+	XChaCha20Poly1305 Suite = 3
+	// AESGCMSIV is AES-GCM-SIV with 96-bit random nonces, which needs a 16 or 32 byte key. It stays
+	// secure when nonces repeat, for callers that cannot guarantee they are unique, such as jobs
+	// re-encrypting the same records deterministically.
+	AESGCMSIV Suite = 4
+)
+
+// Option tunes how Encrypt seals a ciphertext.
+type Option func(*options)
+
+type options struct {
+	suite    Suite
+	encoding Encoding
+	dataKey  bool
+	compress bool
+}
+
+func newOptions(opts []Option) options {
+	o := options{suite: AESGCM, encoding: Base64URL}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// WithSuite seals with suite instead of AES-GCM.
+func WithSuite(suite Suite) Option {
+	return func(o *options) {
+		o.suite = suite
+	}
+}
+
+// Encoding identifies how the payload of a text envelope is encoded. It is recorded in the header,
+// so ciphertexts are always decoded with the encoding they were encoded with.
+type Encoding byte
+
+const (
+	// Base64URL is base64url without padding, the default encoding, which is safe in URLs, headers
+	// and JSON.
+	Base64URL Encoding = 'u'
+	// Base64Std is standard base64 with padding, for consumers that only decode that alphabet.
+	Base64Std Encoding = 's'
+	// Hex is lowercase hexadecimal, for contexts restricted to alphanumeric characters such as
+	// SQL identifiers, at the cost of doubling the size of the payload.
+	Hex Encoding = 'h'
+)
+
+func (e Encoding) encode(payload []byte) (string, error) {
+	switch e {
+	case Base64URL:
+		return base64.RawURLEncoding.EncodeToString(payload), nil
+	case Base64Std:
+		return base64.StdEncoding.EncodeToString(payload), nil
+	case Hex:
+		return hex.EncodeToString(payload), nil
+	}
+
+	return "", fmt.Errorf("unknown ciphertext encoding %q", byte(e))
+}
+
+func (e Encoding) decode(encoded string) ([]byte, error) {
+	switch e {
+	case Base64URL:
+		return base64.RawURLEncoding.DecodeString(encoded)
+	case Base64Std:
+		return base64.StdEncoding.DecodeString(encoded)
+	case Hex:
+		return hex.DecodeString(encoded)
+	}
+
+	return nil, fmt.Errorf("unknown ciphertext encoding %q", byte(e))
+}
+
+// String returns the name of the suite.
+func (s Suite) String() string {
+	switch s {
+	case AESGCM:
+		return "AES-GCM"
+	case ChaCha20Poly1305:
+		return "ChaCha20-Poly1305"
+	case XChaCha20Poly1305:
+		return "XChaCha20-Poly1305"
+	case AESGCMSIV:
+		return "AES-GCM-SIV"
+	}
+
+	return fmt.Sprintf("Suite(%d)", byte(s))
+}
+
+// validateKey checks the size of key for the suite, since the errors of the ciphers do not say
+// what they expected.
+func (s Suite) validateKey(key []byte) error {
+	switch s {
+	case AESGCM:
+		if len(key) != 16 && len(key) != 24 && len(key) != 32 {
+			return fmt.Errorf("key must be 16, 24 or 32 bytes for %s, got %d", s, len(key))
+		}
+	case AESGCMSIV:
+		if len(key) != 16 && len(key) != 32 {
+			return fmt.Errorf("key must be 16 or 32 bytes for %s, got %d", s, len(key))
+		}
+	case ChaCha20Poly1305, XChaCha20Poly1305:
+		if len(key) != chacha20poly1305.KeySize {
+			return fmt.Errorf("key must be %d bytes for %s, got %d", chacha20poly1305.KeySize, s, len(key))
+		}
+	default:
+		return fmt.Errorf("unknown cipher suite %d", byte(s))
+	}
+
+	return nil
+}
+
+// ValidateKey checks that key can be used with the suite selected by opts, AES-GCM by default, so
+// that misconfigured keys can be reported at startup rather than on the first encryption.
+func ValidateKey(key []byte, opts ...Option) error {
+	return newOptions(opts).suite.validateKey(key)
+}
+
+func (s Suite) aead(key []byte) (cipher.AEAD, error) {
+	if err := s.validateKey(key); err != nil {
+		return nil, err
+	}
+
+	switch s {
+	case AESGCM:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+
+		return cipher.NewGCM(block)
+	case ChaCha20Poly1305:
+		return chacha20poly1305.New(key)
+	case XChaCha20Poly1305:
+		return chacha20poly1305.NewX(key)
+	case AESGCMSIV:
+		return newGCMSIV(key)
+	}
+
+	return nil, fmt.Errorf("unknown cipher suite %d", byte(s))
+}
+
+// WithEncoding encodes text ciphertexts with encoding instead of base64url.
+func WithEncoding(encoding Encoding) Option {
+	return func(o *options) {
+		o.encoding = encoding
+	}
+}
+
+// aeadSource returns the AEAD of a suite for the key a payload is sealed or opened with. Cipher
+// reuses the AEADs it returns, while keyAEADs builds them on every call.
+type aeadSource func(Suite) (cipher.AEAD, error)
+
+func keyAEADs(key []byte) aeadSource {
+	return func(suite Suite) (cipher.AEAD, error) {
+		return suite.aead(key)
+	}
+}
+
+// seal encrypts plaintext with suite into a payload, authenticating aad along with it.
+func seal(suite Suite, plaintext, key, aad []byte) ([]byte, error) {
+	return sealWith(suite, plaintext, aad, keyAEADs(key))
+}
+
+func sealWith(suite Suite, plaintext, aad []byte, aeads aeadSource) ([]byte, error) {
+	aead, err := aeads(suite)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 1+aead.NonceSize(), 1+aead.NonceSize()+len(plaintext)+aead.Overhead())
+	out[0] = byte(suite)
+	nonce := out[1:]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(out, nonce, plaintext, aad), nil
+}
+
+// open decrypts a payload sealed by seal, sealWithDataKey or EncryptDeterministic with the same aad.
+func open(payload, key, aad []byte) ([]byte, error) {
+	return openWith(payload, aad, keyAEADs(key))
+}
+
+func openWith(payload, aad []byte, aeads aeadSource) ([]byte, error) {
+	if len(payload) == 0 {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	switch payload[0] {
+	case dataKeyMarker:
+		return openDataKey(payload, aad, func(wrapped []byte) ([]byte, error) {
+			return openWith(wrapped, nil, aeads)
+		})
+	case deterministicMarker:
+		aead, err := aeads(AESGCMSIV)
+		if err != nil {
+			return nil, err
+		}
+
+		return openDeterministic(payload, aead, aad)
+	}
+
+	aead, err := aeads(Suite(payload[0]))
+	if err != nil {
+		return nil, err
+	}
+
+	data := payload[1:]
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	return aead.Open(ciphertext[:0], nonce, ciphertext, aad)
+}
+
+// header is the text header of a version 1 envelope: the encoding of the payload, optionally
+// followed by flags such as compressedFlag.
+type header struct {
+	encoding   Encoding
+	compressed bool
+}
+
+// format returns the text envelope of payload.
+func (h header) format(payload []byte) (string, error) {
+	encoded, err := h.encoding.encode(payload)
+	if err != nil {
+		return "", err
+	}
+
+	flags := ""
+	if h.compressed {
+		flags = string(compressedFlag)
+	}
+
+	return envelopePrefix + string(envelopeVersion) + string(h.encoding) + flags + envelopePrefix + encoded, nil
+}
+
+// parseEnvelope splits a text envelope into its header and decoded payload.
+func parseEnvelope(cryptoText string) (header, []byte, error) {
+	prefix, encoded, ok := strings.Cut(strings.TrimPrefix(cryptoText, envelopePrefix), envelopePrefix)
+	if !ok || len(prefix) < 2 {
+		return header{}, nil, errors.New("malformed ciphertext envelope")
+	}
+
+	if prefix[0] != envelopeVersion {
+		return header{}, nil, fmt.Errorf("unsupported ciphertext version %q", prefix[0])
+	}
+
+	h := header{encoding: Encoding(prefix[1])}
+	for _, flag := range []byte(prefix[2:]) {
+		if flag != compressedFlag {
+			return header{}, nil, fmt.Errorf("unknown ciphertext flag %q", flag)
+		}
+		h.compressed = true
+	}
+
+	payload, err := h.encoding.decode(encoded)
+	return h, payload, err
+}
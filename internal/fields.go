@@ -0,0 +1,121 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// cryptTag marks the struct fields that EncryptFields encrypts, as in `json:"email" crypt:"true"`.
+const cryptTag = "crypt"
+
+// EncryptFields encodes v, a struct or a pointer to one, as JSON with the value of every field
+// tagged crypt:"true" replaced by its ciphertext, so that records stored in Redis stay partially
+// readable and queryable while their sensitive fields are protected. Each ciphertext is bound to
+// the JSON name of its field, so that values cannot be swapped between fields. Only the fields of
+// v itself are considered, not those of nested or embedded structs.
+func EncryptFields[T any](v T, key []byte, opts ...Option) ([]byte, error) {
+	names, err := cryptFields(reflect.TypeOf(v))
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		value, ok := fields[name]
+		if !ok {
+			continue
+		}
+
+		sealed, err := EncryptWithAAD(value, key, []byte(name), opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt field %s: %w", name, err)
+		}
+
+		fields[name], _ = json.Marshal(sealed)
+	}
+
+	return json.Marshal(fields)
+}
+
+// DecryptFields decodes JSON encoded by EncryptFields into a T, decrypting its encrypted fields.
+func DecryptFields[T any](data, key []byte) (T, error) {
+	var v T
+	names, err := cryptFields(reflect.TypeOf(v))
+	if err != nil {
+		return v, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return v, err
+	}
+
+	for _, name := range names {
+		value, ok := fields[name]
+		if !ok {
+			continue
+		}
+
+		var sealed string
+		if err := json.Unmarshal(value, &sealed); err != nil {
+			return v, fmt.Errorf("field %s is not encrypted", name)
+		}
+
+		plaintext, err := DecryptWithAAD(sealed, key, []byte(name))
+		if err != nil {
+			return v, fmt.Errorf("failed to decrypt field %s: %w", name, err)
+		}
+
+		fields[name] = plaintext
+	}
+
+	decrypted, err := json.Marshal(fields)
+	if err != nil {
+		return v, err
+	}
+
+	err = json.Unmarshal(decrypted, &v)
+	return v, err
+}
+
+// cryptFields returns the JSON names of the fields of t tagged crypt:"true".
+func cryptFields(t reflect.Type) ([]string, error) {
+	if t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("field encryption requires a struct, got %v", t)
+	}
+
+	var names []string
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if field.Tag.Get(cryptTag) != "true" || !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		names = append(names, name)
+	}
+
+	return names, nil
+}
@@ -0,0 +1,155 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+type fieldsRecord struct {
+	ID      string  `json:"id"`
+	Email   string  `json:"email" crypt:"true"`
+	Phone   string  `json:"phone,omitempty" crypt:"true"`
+	Lat     float64 `json:"lat" crypt:"true"`
+	Name    string  `crypt:"true"`
+	Ignored string  `json:"-" crypt:"true"`
+}
+
+var testFieldsRecord = fieldsRecord{ID: "1", Email: "user@example.com", Phone: "+977 1 4000000", Lat: 27.7172, Name: "Kathmandu"}
+
+func encryptTestFields(t *testing.T, key []byte) map[string]json.RawMessage {
+	t.Helper()
+
+	encoded, err := EncryptFields(testFieldsRecord, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		t.Fatal(err)
+	}
+
+	return fields
+}
+
+func TestFieldsRoundTrip(t *testing.T) {
+	key, err := GenerateKey(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields := encryptTestFields(t, key)
+	if string(fields["id"]) != `"1"` {
+		t.Errorf("id = %s, want it in clear", fields["id"])
+	}
+	for _, name := range []string{"email", "phone", "lat", "Name"} {
+		if bytes.Contains(fields[name], []byte("Kathmandu")) || bytes.Contains(fields[name], []byte("example")) || bytes.Contains(fields[name], []byte("27.7")) {
+			t.Errorf("%s = %s, want it encrypted", name, fields[name])
+		}
+	}
+
+	encoded, _ := json.Marshal(fields)
+	decrypted, err := DecryptFields[fieldsRecord](encoded, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decrypted != testFieldsRecord {
+		t.Errorf("DecryptFields = %+v, want %+v", decrypted, testFieldsRecord)
+	}
+
+	pointer, err := EncryptFields(&testFieldsRecord, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decrypted, err := DecryptFields[*fieldsRecord](pointer, key); err != nil || *decrypted != testFieldsRecord {
+		t.Errorf("DecryptFields(pointer) = %+v, %v", decrypted, err)
+	}
+
+	// Omitted fields are not encrypted.
+	omitted, err := EncryptFields(fieldsRecord{ID: "2"}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decrypted, err := DecryptFields[fieldsRecord](omitted, key); err != nil || decrypted != (fieldsRecord{ID: "2"}) {
+		t.Errorf("DecryptFields(omitted) = %+v, %v", decrypted, err)
+	}
+}
+
+func TestFieldsRequireStruct(t *testing.T) {
+	key, err := GenerateKey(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := EncryptFields("email", key); err == nil {
+		t.Error("EncryptFields(string) succeeded")
+	}
+	if _, err := DecryptFields[map[string]string]([]byte(`{}`), key); err == nil {
+		t.Error("DecryptFields(map) succeeded")
+	}
+}
+
+func TestFieldsRejectTampering(t *testing.T) {
+	key, err := GenerateKey(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := GenerateKey(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tamper := func(edit func(fields map[string]json.RawMessage)) []byte {
+		fields := encryptTestFields(t, key)
+		edit(fields)
+		encoded, _ := json.Marshal(fields)
+		return encoded
+	}
+	sealed := func(fields map[string]json.RawMessage, name string) string {
+		var value string
+		if err := json.Unmarshal(fields[name], &value); err != nil {
+			t.Fatal(err)
+		}
+		return value
+	}
+	set := func(fields map[string]json.RawMessage, name, value string) {
+		fields[name], _ = json.Marshal(value)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		key  []byte
+	}{
+		{name: "wrong key", data: tamper(func(map[string]json.RawMessage) {}), key: otherKey},
+		// Ciphertexts are bound to their field, so that they cannot be swapped.
+		{name: "swapped fields", data: tamper(func(fields map[string]json.RawMessage) {
+			fields["email"], fields["phone"] = fields["phone"], fields["email"]
+		}), key: key},
+		{name: "flipped ciphertext", data: tamper(func(fields map[string]json.RawMessage) {
+			value := []byte(sealed(fields, "email"))
+			value[len(value)-2] ^= 1
+			set(fields, "email", string(value))
+		}), key: key},
+		{name: "truncated ciphertext", data: tamper(func(fields map[string]json.RawMessage) {
+			value := sealed(fields, "email")
+			set(fields, "email", value[:len(value)-4])
+		}), key: key},
+		{name: "plaintext value", data: tamper(func(fields map[string]json.RawMessage) {
+			set(fields, "email", "attacker@example.com")
+		}), key: key},
+		{name: "not a string", data: tamper(func(fields map[string]json.RawMessage) {
+			fields["lat"] = json.RawMessage("27.7172")
+		}), key: key},
+		{name: "truncated JSON", data: tamper(func(map[string]json.RawMessage) {})[:20], key: key},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if decrypted, err := DecryptFields[fieldsRecord](tt.data, tt.key); err == nil {
+				t.Errorf("DecryptFields = %+v", decrypted)
+			}
+		})
+	}
+}
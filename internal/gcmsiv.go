@@ -0,0 +1,237 @@
+package internal
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+)
+
+// gcmSIV implements AES-GCM-SIV as specified by RFC 8452. It is nonce misuse resistant: sealing
+// two messages with the same nonce only reveals whether they are equal, where AES-GCM would leak
+// their XOR and allow forgeries. Neither the standard library nor x/crypto implement it.
+//
+// The key is only used to derive a fresh authentication and encryption key for each nonce. The
+// tag is the encryption of the POLYVAL hash of the additional data, the plaintext and their
+// lengths, mixed with the nonce, and also serves as the initial counter of the encryption of the
+// plaintext, which makes it a synthetic IV.
+type gcmSIV struct {
+	keyGenerating cipher.Block
+	keySize       int
+}
+
+const (
+	gcmSIVNonceSize = 12
+	gcmSIVTagSize   = 16
+	// gcmSIVMaxLength is the limit of RFC 8452 on the length of the plaintext and additional data.
+	gcmSIVMaxLength = 1 << 36
+)
+
+// newGCMSIV returns AES-GCM-SIV with a 16 or 32 byte key.
+func newGCMSIV(key []byte) (cipher.AEAD, error) {
+	if len(key) != 16 && len(key) != 32 {
+		return nil, errors.New("AES-GCM-SIV requires a 16 or 32 byte key")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &gcmSIV{keyGenerating: block, keySize: len(key)}, nil
+}
+
+func (g *gcmSIV) NonceSize() int {
+	return gcmSIVNonceSize
+}
+
+func (g *gcmSIV) Overhead() int {
+	return gcmSIVTagSize
+}
+
+// deriveKeys returns the message authentication key and the cipher of the message encryption key
+// for nonce, from the first half of the encryptions of consecutive counters under the key.
+func (g *gcmSIV) deriveKeys(nonce []byte) (authKey [16]byte, encryption cipher.Block, err error) {
+	var input, output [16]byte
+	copy(input[4:], nonce)
+
+	derived := make([]byte, 0, 16+g.keySize)
+	for i := uint32(0); len(derived) < cap(derived); i++ {
+		binary.LittleEndian.PutUint32(input[:4], i)
+		g.keyGenerating.Encrypt(output[:], input[:])
+		derived = append(derived, output[:8]...)
+	}
+	defer clear(derived)
+
+	copy(authKey[:], derived[:16])
+	encryption, err = aes.NewCipher(derived[16:])
+	return authKey, encryption, err
+}
+
+// tag computes the tag of plaintext and additionalData.
+func (g *gcmSIV) tag(authKey [16]byte, encryption cipher.Block, nonce, plaintext, additionalData []byte) [16]byte {
+	var lengths [16]byte
+	binary.LittleEndian.PutUint64(lengths[:8], uint64(len(additionalData))*8)
+	binary.LittleEndian.PutUint64(lengths[8:], uint64(len(plaintext))*8)
+
+	p := newPolyval(authKey)
+	p.update(additionalData)
+	p.update(plaintext)
+	p.update(lengths[:])
+
+	s := p.sum()
+	subtle.XORBytes(s[:gcmSIVNonceSize], s[:gcmSIVNonceSize], nonce)
+	s[15] &= 0x7f
+
+	var tag [16]byte
+	encryption.Encrypt(tag[:], s[:])
+	return tag
+}
+
+// ctr XORs in with the key stream starting at the counter block derived from tag into out. The
+// counter is the little endian uint32 in the first four bytes of the block, wrapping around.
+func ctr(encryption cipher.Block, tag [16]byte, out, in []byte) {
+	counter := tag
+	counter[15] |= 0x80
+
+	var stream [16]byte
+	for len(in) > 0 {
+		encryption.Encrypt(stream[:], counter[:])
+		n := subtle.XORBytes(out, in, stream[:])
+		out, in = out[n:], in[n:]
+		binary.LittleEndian.PutUint32(counter[:4], binary.LittleEndian.Uint32(counter[:4])+1)
+	}
+}
+
+func (g *gcmSIV) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != gcmSIVNonceSize {
+		panic("crypto: incorrect nonce length given to AES-GCM-SIV")
+	}
+	if uint64(len(plaintext)) > gcmSIVMaxLength || uint64(len(additionalData)) > gcmSIVMaxLength {
+		panic("crypto: message too large for AES-GCM-SIV")
+	}
+
+	authKey, encryption, err := g.deriveKeys(nonce)
+	if err != nil {
+		panic(err)
+	}
+	tag := g.tag(authKey, encryption, nonce, plaintext, additionalData)
+
+	ret, out := sliceForAppend(dst, len(plaintext)+gcmSIVTagSize)
+	ctr(encryption, tag, out, plaintext)
+	copy(out[len(plaintext):], tag[:])
+
+	return ret
+}
+
+var errGCMSIVOpen = errors.New("cipher: message authentication failed")
+
+func (g *gcmSIV) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != gcmSIVNonceSize {
+		panic("crypto: incorrect nonce length given to AES-GCM-SIV")
+	}
+	if len(ciphertext) < gcmSIVTagSize || uint64(len(ciphertext)) > gcmSIVMaxLength+gcmSIVTagSize {
+		return nil, errGCMSIVOpen
+	}
+
+	authKey, encryption, err := g.deriveKeys(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	var tag [16]byte
+	copy(tag[:], ciphertext[len(ciphertext)-gcmSIVTagSize:])
+	ciphertext = ciphertext[:len(ciphertext)-gcmSIVTagSize]
+
+	ret, out := sliceForAppend(dst, len(ciphertext))
+	ctr(encryption, tag, out, ciphertext)
+
+	expected := g.tag(authKey, encryption, nonce, out, additionalData)
+	if subtle.ConstantTimeCompare(expected[:], tag[:]) != 1 {
+		clear(out)
+		return nil, errGCMSIVOpen
+	}
+
+	return ret, nil
+}
+
+// sliceForAppend extends in by n bytes, returning the whole slice and the extension.
+func sliceForAppend(in []byte, n int) (head, tail []byte) {
+	if total := len(in) + n; cap(in) >= total {
+		head = in[:total]
+	} else {
+		head = make([]byte, total)
+		copy(head, in)
+	}
+
+	return head, head[len(in):]
+}
+
+// fieldElement is an element of GF(2^128) as POLYVAL represents them: the coefficient of x^i is
+// bit i%64 of the little endian word i/64.
+type fieldElement struct {
+	lo, hi uint64
+}
+
+func loadFieldElement(b []byte) fieldElement {
+	return fieldElement{binary.LittleEndian.Uint64(b[:8]), binary.LittleEndian.Uint64(b[8:])}
+}
+
+// mulX multiplies e by x modulo x^128 + x^127 + x^126 + x^121 + 1.
+func (e fieldElement) mulX() fieldElement {
+	carry := -(e.hi >> 63)
+	return fieldElement{
+		lo: e.lo<<1 ^ carry&1,
+		hi: (e.hi<<1 | e.lo>>63) ^ carry&(1<<63|1<<62|1<<57),
+	}
+}
+
+// mul multiplies e by f modulo the POLYVAL polynomial, in constant time.
+func (e fieldElement) mul(f fieldElement) fieldElement {
+	var product fieldElement
+	for i := 127; i >= 0; i-- {
+		product = product.mulX()
+		word := e.lo
+		if i >= 64 {
+			word = e.hi
+		}
+
+		bit := -(word >> (i % 64) & 1)
+		product.lo ^= f.lo & bit
+		product.hi ^= f.hi & bit
+	}
+
+	return product
+}
+
+// xInverse128 is x^-128, by which the product of POLYVAL is multiplied.
+var xInverse128 = fieldElement{lo: 1, hi: 1<<63 | 1<<60 | 1<<57 | 1<<50}
+
+// polyval computes the POLYVAL hash of RFC 8452, zero padding each input to a whole block.
+type polyval struct {
+	h, s fieldElement
+}
+
+func newPolyval(key [16]byte) *polyval {
+	return &polyval{h: loadFieldElement(key[:]).mul(xInverse128)}
+}
+
+func (p *polyval) update(data []byte) {
+	var block [16]byte
+	for len(data) > 0 {
+		n := copy(block[:], data)
+		clear(block[n:])
+		data = data[n:]
+
+		x := loadFieldElement(block[:])
+		p.s = fieldElement{p.s.lo ^ x.lo, p.s.hi ^ x.hi}.mul(p.h)
+	}
+}
+
+func (p *polyval) sum() [16]byte {
+	var out [16]byte
+	binary.LittleEndian.PutUint64(out[:8], p.s.lo)
+	binary.LittleEndian.PutUint64(out[8:], p.s.hi)
+	return out
+}
@@ -0,0 +1,177 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// gcmSIVVectors are test vectors from RFC 8452, Appendix C.1 for AEAD_AES_128_GCM_SIV, C.2 for
+// AEAD_AES_256_GCM_SIV and C.3 for the wrapping of the counter, also with AEAD_AES_256_GCM_SIV. result
+// is the ciphertext followed by the tag.
+var gcmSIVVectors = []struct {
+	name, key, nonce, aad, plaintext, result string
+}{
+	{
+		name:   "C.1 empty",
+		key:    "01000000000000000000000000000000",
+		nonce:  "030000000000000000000000",
+		result: "dc20e2d83f25705bb49e439eca56de25",
+	},
+	{
+		name:      "C.1 8 bytes",
+		key:       "01000000000000000000000000000000",
+		nonce:     "030000000000000000000000",
+		plaintext: "0100000000000000",
+		result:    "b5d839330ac7b786578782fff6013b815b287c22493a364c",
+	},
+	{
+		name:      "C.1 12 bytes",
+		key:       "01000000000000000000000000000000",
+		nonce:     "030000000000000000000000",
+		plaintext: "010000000000000000000000",
+		result:    "7323ea61d05932260047d942a4978db357391a0bc4fdec8b0d106639",
+	},
+	{
+		name:      "C.1 16 bytes",
+		key:       "01000000000000000000000000000000",
+		nonce:     "030000000000000000000000",
+		plaintext: "01000000000000000000000000000000",
+		result:    "743f7c8077ab25f8624e2e948579cf77303aaf90f6fe21199c6068577437a0c4",
+	},
+	{
+		name:      "C.1 32 bytes",
+		key:       "01000000000000000000000000000000",
+		nonce:     "030000000000000000000000",
+		plaintext: "0100000000000000000000000000000002000000000000000000000000000000",
+		result:    "84e07e62ba83a6585417245d7ec413a9fe427d6315c09b57ce45f2e3936a94451a8e45dcd4578c667cd86847bf6155ff",
+	},
+	{
+		name:      "C.1 8 bytes with additional data",
+		key:       "01000000000000000000000000000000",
+		nonce:     "030000000000000000000000",
+		aad:       "01",
+		plaintext: "0200000000000000",
+		result:    "1e6daba35669f4273b0a1a2560969cdf790d99759abd1508",
+	},
+	{
+		name:      "C.1 16 bytes with additional data",
+		key:       "01000000000000000000000000000000",
+		nonce:     "030000000000000000000000",
+		aad:       "01",
+		plaintext: "02000000000000000000000000000000",
+		result:    "e2b0c5da79a901c1745f700525cb335b8f8936ec039e4e4bb97ebd8c4457441f",
+	},
+	{
+		name:   "C.2 empty",
+		key:    "0100000000000000000000000000000000000000000000000000000000000000",
+		nonce:  "030000000000000000000000",
+		result: "07f5f4169bbf55a8400cd47ea6fd400f",
+	},
+	{
+		name:      "C.2 8 bytes",
+		key:       "0100000000000000000000000000000000000000000000000000000000000000",
+		nonce:     "030000000000000000000000",
+		plaintext: "0100000000000000",
+		result:    "c2ef328e5c71c83b843122130f7364b761e0b97427e3df28",
+	},
+	{
+		name:      "C.2 12 bytes",
+		key:       "0100000000000000000000000000000000000000000000000000000000000000",
+		nonce:     "030000000000000000000000",
+		plaintext: "010000000000000000000000",
+		result:    "9aab2aeb3faa0a34aea8e2b18ca50da9ae6559e48fd10f6e5c9ca17e",
+	},
+	{
+		name:      "C.2 16 bytes",
+		key:       "0100000000000000000000000000000000000000000000000000000000000000",
+		nonce:     "030000000000000000000000",
+		plaintext: "01000000000000000000000000000000",
+		result:    "85a01b63025ba19b7fd3ddfc033b3e76c9eac6fa700942702e90862383c6c366",
+	},
+	{
+		name:      "C.3 counter wrap",
+		key:       "0000000000000000000000000000000000000000000000000000000000000000",
+		nonce:     "000000000000000000000000",
+		plaintext: "000000000000000000000000000000004db923dc793ee6497c76dcc03a98e108",
+		result:    "f3f80f2cf0cb2dd9c5984fcda908456cc537703b5ba70324a6793a7bf218d3eaffffffff000000000000000000000000",
+	},
+	{
+		name:      "C.3 counter wrap, short block",
+		key:       "0000000000000000000000000000000000000000000000000000000000000000",
+		nonce:     "000000000000000000000000",
+		plaintext: "eb3640277c7ffd1303c7a542d02d3e4c0000000000000000",
+		result:    "18ce4f0b8cb4d0cac65fea8f79257b20888e53e72299e56dffffffff000000000000000000000000",
+	},
+}
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+func TestGCMSIVVectors(t *testing.T) {
+	for _, v := range gcmSIVVectors {
+		t.Run(v.name, func(t *testing.T) {
+			aead, err := newGCMSIV(mustDecodeHex(t, v.key))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			nonce, aad := mustDecodeHex(t, v.nonce), mustDecodeHex(t, v.aad)
+			plaintext, result := mustDecodeHex(t, v.plaintext), mustDecodeHex(t, v.result)
+
+			if sealed := aead.Seal(nil, nonce, plaintext, aad); !bytes.Equal(sealed, result) {
+				t.Errorf("Seal = %x, want %x", sealed, result)
+			}
+
+			opened, err := aead.Open(nil, nonce, result, aad)
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			if !bytes.Equal(opened, plaintext) {
+				t.Errorf("Open = %x, want %x", opened, plaintext)
+			}
+		})
+	}
+}
+
+func TestGCMSIVRejectsTampering(t *testing.T) {
+	aead, err := newGCMSIV(mustDecodeHex(t, "01000000000000000000000000000000"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nonce, aad := mustDecodeHex(t, "030000000000000000000000"), []byte("aad")
+	sealed := aead.Seal(nil, nonce, []byte("attack at dawn"), aad)
+
+	flip := func(b []byte, i int) []byte {
+		b = bytes.Clone(b)
+		b[i] ^= 0x01
+		return b
+	}
+
+	tests := []struct {
+		name              string
+		nonce, sealed, ad []byte
+	}{
+		{"tag", nonce, flip(sealed, len(sealed)-1), aad},
+		{"ciphertext", nonce, flip(sealed, 0), aad},
+		{"additional data", nonce, sealed, flip(aad, 0)},
+		{"nonce", flip(nonce, 0), sealed, aad},
+		{"truncated", nonce, sealed[:aead.Overhead()-1], aad},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if opened, err := aead.Open(nil, tt.nonce, tt.sealed, tt.ad); err == nil {
+				t.Errorf("Open = %q, want an error", opened)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+// Package geo contains the coordinate helpers shared by the Netlify functions.
+package geo
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// ParseCoordinates parses lat and lon as decimal degrees and checks that they are within range.
+func ParseCoordinates(lat, lon string) (float64, float64, error) {
+	latitude, err := strconv.ParseFloat(lat, 64)
+	if err != nil || math.IsNaN(latitude) {
+		return 0, 0, fmt.Errorf("lat must be a number, got %q", lat)
+	}
+
+	longitude, err := strconv.ParseFloat(lon, 64)
+	if err != nil || math.IsNaN(longitude) {
+		return 0, 0, fmt.Errorf("lon must be a number, got %q", lon)
+	}
+
+	if err := ValidateCoordinates(latitude, longitude); err != nil {
+		return 0, 0, err
+	}
+
+	return latitude, longitude, nil
+}
+
+// ValidateCoordinates checks that lat and lon are within range.
+func ValidateCoordinates(lat, lon float64) error {
+	if lat < -90 || lat > 90 {
+		return fmt.Errorf("lat must be between -90 and 90, got %v", lat)
+	}
+
+	if lon < -180 || lon > 180 {
+		return fmt.Errorf("lon must be between -180 and 180, got %v", lon)
+	}
+
+	return nil
+}
+
+// FormatCoordinate snaps a coordinate to precision decimal places so that nearby lookups share the
+// same upstream request and cache entry.
+func FormatCoordinate(value float64, precision int) string {
+	return strconv.FormatFloat(value, 'f', precision, 64)
+}
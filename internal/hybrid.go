@@ -0,0 +1,149 @@
+package internal
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Sealed boxes let clients encrypt payloads to the public key of the backend, so that only the
+// holder of the private key can open them, whatever terminates TLS in between. The sender generates
+// an ephemeral X25519 key pair, and the AES-256-GCM key is derived by HKDF-SHA256 from the shared
+// secret, with both public keys in the info so that the box is bound to its recipient. The sender is
+// anonymous: a box does not prove who sealed it.
+//
+// A box is base64url encoded without padding: the version byte, the ephemeral public key, the nonce
+// and the ciphertext. Browsers can seal boxes with WebCrypto, which supports X25519, HKDF and AES-GCM.
+const (
+	boxVersion = 1
+	boxInfo    = "nawa sealed box v1"
+)
+
+// GenerateBoxKey returns a new X25519 key to open sealed boxes with.
+func GenerateBoxKey() (*ecdh.PrivateKey, error) {
+	return ecdh.X25519().GenerateKey(rand.Reader)
+}
+
+// EncodeBoxPublicKey encodes key for clients, which can import it as a raw X25519 key.
+func EncodeBoxPublicKey(key *ecdh.PublicKey) string {
+	return base64.RawURLEncoding.EncodeToString(key.Bytes())
+}
+
+// DecodeBoxPublicKey decodes a key encoded by EncodeBoxPublicKey.
+func DecodeBoxPublicKey(encoded string) (*ecdh.PublicKey, error) {
+	key, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+
+	return ecdh.X25519().NewPublicKey(key)
+}
+
+// BoxKeyFromEnv decodes the private X25519 key in the environment variable name, encoded by
+// EncodeKey.
+func BoxKeyFromEnv(name string) (*ecdh.PrivateKey, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil, fmt.Errorf("%s is not set", name)
+	}
+
+	key, err := DecodeKey(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	private, err := ecdh.X25519().NewPrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return private, nil
+}
+
+// SealBox seals plaintext so that only the holder of the private key of recipient can open it,
+// binding it to aad like EncryptWithAAD.
+func SealBox(plaintext []byte, recipient *ecdh.PublicKey, aad []byte) (string, error) {
+	ephemeral, err := GenerateBoxKey()
+	if err != nil {
+		return "", err
+	}
+
+	shared, err := ephemeral.ECDH(recipient)
+	if err != nil {
+		return "", err
+	}
+
+	aead, err := boxAEAD(shared, ephemeral.PublicKey(), recipient)
+	if err != nil {
+		return "", err
+	}
+
+	box := append([]byte{boxVersion}, ephemeral.PublicKey().Bytes()...)
+	box = append(box, make([]byte, aead.NonceSize())...)
+	nonce := box[len(box)-aead.NonceSize():]
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(aead.Seal(box, nonce, plaintext, aad)), nil
+}
+
+// OpenBox opens a box sealed by SealBox to the public key of key, with the same aad.
+func OpenBox(sealed string, key *ecdh.PrivateKey, aad []byte) ([]byte, error) {
+	box, err := base64.RawURLEncoding.DecodeString(sealed)
+	if err != nil {
+		return nil, err
+	}
+
+	const publicKeySize = 32
+	if len(box) < 1+publicKeySize || box[0] != boxVersion {
+		return nil, errors.New("invalid sealed box")
+	}
+
+	ephemeral, err := ecdh.X25519().NewPublicKey(box[1 : 1+publicKeySize])
+	if err != nil {
+		return nil, err
+	}
+
+	shared, err := key.ECDH(ephemeral)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := boxAEAD(shared, ephemeral, key.PublicKey())
+	if err != nil {
+		return nil, err
+	}
+
+	rest := box[1+publicKeySize:]
+	if len(rest) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	nonce, ciphertext := rest[:aead.NonceSize()], rest[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, aad)
+}
+
+// boxAEAD returns the AES-GCM cipher keyed from the shared secret of a box.
+func boxAEAD(shared []byte, ephemeral, recipient *ecdh.PublicKey) (cipher.AEAD, error) {
+	info := append(append([]byte(boxInfo), ephemeral.Bytes()...), recipient.Bytes()...)
+	key, err := hkdf.Key(sha256.New, shared, nil, string(info), 32)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(key)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,150 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"encoding/base64"
+	"testing"
+)
+
+func TestBoxRoundTrip(t *testing.T) {
+	key, err := GenerateBoxKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		plaintext, aad []byte
+	}{
+		{name: "empty"},
+		{name: "plaintext", plaintext: benchmarkPlaintext},
+		{name: "plaintext and aad", plaintext: benchmarkPlaintext, aad: []byte("locations:user")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sealed, err := SealBox(tt.plaintext, key.PublicKey(), tt.aad)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			opened, err := OpenBox(sealed, key, tt.aad)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(opened, tt.plaintext) {
+				t.Errorf("OpenBox = %q, want %q", opened, tt.plaintext)
+			}
+
+			if again, _ := SealBox(tt.plaintext, key.PublicKey(), tt.aad); again == sealed {
+				t.Error("boxes of the same plaintext are identical")
+			}
+		})
+	}
+}
+
+func TestBoxKeys(t *testing.T) {
+	key, err := GenerateBoxKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	public, err := DecodeBoxPublicKey(EncodeBoxPublicKey(key.PublicKey()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !public.Equal(key.PublicKey()) {
+		t.Error("DecodeBoxPublicKey(EncodeBoxPublicKey) is another key")
+	}
+	if _, err := DecodeBoxPublicKey(EncodeBoxPublicKey(key.PublicKey())[:10]); err == nil {
+		t.Error("DecodeBoxPublicKey(truncated key) succeeded")
+	}
+
+	t.Setenv("box_key", EncodeKey(key.Bytes()))
+	loaded, err := BoxKeyFromEnv("box_key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Equal(key) {
+		t.Error("BoxKeyFromEnv is another key")
+	}
+
+	t.Setenv("box_key", EncodeKey(key.Bytes()[:16]))
+	if _, err := BoxKeyFromEnv("box_key"); err == nil {
+		t.Error("BoxKeyFromEnv(short key) succeeded")
+	}
+	if _, err := BoxKeyFromEnv("unset_box_key"); err == nil {
+		t.Error("BoxKeyFromEnv(unset) succeeded")
+	}
+}
+
+func TestBoxRejectsTampering(t *testing.T) {
+	key, err := GenerateBoxKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := GenerateBoxKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	aad := []byte("locations:user")
+	sealed, err := SealBox(benchmarkPlaintext, key.PublicKey(), aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := SealBox(benchmarkPlaintext, key.PublicKey(), aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	box, _ := base64.RawURLEncoding.DecodeString(sealed)
+	otherBox, _ := base64.RawURLEncoding.DecodeString(other)
+
+	// A box is the version, a 32 byte ephemeral key, a 12 byte nonce and the ciphertext.
+	const ephemeral, nonce = 1, 1 + 32
+	ciphertext := nonce + 12
+	encode := func(box []byte) string {
+		return base64.RawURLEncoding.EncodeToString(box)
+	}
+	flip := func(i int) string {
+		tampered := bytes.Clone(box)
+		tampered[i] ^= 1
+		return encode(tampered)
+	}
+	splice := func(parts ...[]byte) string {
+		return encode(bytes.Join(parts, nil))
+	}
+
+	tests := []struct {
+		name, sealed string
+		key          *ecdh.PrivateKey
+		aad          []byte
+	}{
+		{name: "other recipient", sealed: sealed, key: otherKey, aad: aad},
+		{name: "other aad", sealed: sealed, key: key, aad: []byte("locations:other")},
+		{name: "no aad", sealed: sealed, key: key},
+		{name: "version", sealed: flip(0), key: key, aad: aad},
+		{name: "ephemeral key", sealed: flip(ephemeral), key: key, aad: aad},
+		{name: "nonce", sealed: flip(nonce), key: key, aad: aad},
+		{name: "ciphertext", sealed: flip(ciphertext), key: key, aad: aad},
+		{name: "tag", sealed: flip(len(box) - 1), key: key, aad: aad},
+		{name: "low order ephemeral key", sealed: splice(box[:ephemeral], make([]byte, 32), box[nonce:]), key: key, aad: aad},
+		{name: "ephemeral key of another box", sealed: splice(box[:ephemeral], otherBox[ephemeral:nonce], box[nonce:]), key: key, aad: aad},
+		{name: "nonce of another box", sealed: splice(box[:nonce], otherBox[nonce:ciphertext], box[ciphertext:]), key: key, aad: aad},
+		{name: "truncated tag", sealed: encode(box[:len(box)-1]), key: key, aad: aad},
+		{name: "no ciphertext", sealed: encode(box[:ciphertext]), key: key, aad: aad},
+		{name: "truncated nonce", sealed: encode(box[:ciphertext-1]), key: key, aad: aad},
+		{name: "truncated ephemeral key", sealed: encode(box[:nonce-1]), key: key, aad: aad},
+		{name: "empty", sealed: "", key: key, aad: aad},
+		{name: "padded", sealed: sealed + "=", key: key, aad: aad},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if opened, err := OpenBox(tt.sealed, tt.key, tt.aad); err == nil {
+				t.Errorf("OpenBox = %q", opened)
+			}
+		})
+	}
+}
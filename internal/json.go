@@ -0,0 +1,27 @@
+package internal
+
+import "encoding/json"
+
+// EncryptJSON encodes v as JSON and seals it with Encrypt.
+func EncryptJSON[T any](v T, key []byte, opts ...Option) (string, error) {
+	plaintext, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	defer clear(plaintext)
+
+	return Encrypt(plaintext, key, opts...)
+}
+
+// DecryptJSON opens a ciphertext sealed by EncryptJSON and decodes it into a T.
+func DecryptJSON[T any](cryptoText string, key []byte) (T, error) {
+	var v T
+	plaintext, err := Decrypt(cryptoText, key)
+	if err != nil {
+		return v, err
+	}
+	defer clear(plaintext)
+
+	err = json.Unmarshal(plaintext, &v)
+	return v, err
+}
@@ -1,56 +1,128 @@
 package internal
 
 import (
-	"crypto/aes"
-	"crypto/cipher"
 	"crypto/rand"
 	"encoding/base64"
 	"errors"
-	"io"
+	"fmt"
+	"os"
+	"strings"
 )
 
-func Encrypt(plaintext []byte, key []byte) (string, error) {
-	block, err := aes.NewCipher(key)
+// Encrypt seals plaintext under key in a version 1 envelope, with AES-GCM unless opts select
+// another suite.
+func Encrypt(plaintext []byte, key []byte, opts ...Option) (string, error) {
+	return EncryptWithAAD(plaintext, key, nil, opts...)
+}
+
+// EncryptWithAAD is Encrypt binding the ciphertext to aad, additional data such as a user ID or a
+// record type that is authenticated but not stored. The ciphertext can only be opened by
+// DecryptWithAAD with the same aad, so that it cannot be swapped with the one of another context.
+func EncryptWithAAD(plaintext, key, aad []byte, opts ...Option) (string, error) {
+	return encryptWith(newOptions(opts), plaintext, aad, keyAEADs(key))
+}
+
+func encryptWith(o options, plaintext, aad []byte, aeads aeadSource) (string, error) {
+	plaintext, compressed, err := o.compressPlaintext(plaintext)
 	if err != nil {
 		return "", err
 	}
 
-	gcm, err := cipher.NewGCM(block)
+	payload, err := o.seal(plaintext, envelopeAAD(aad, compressed), aeads)
 	if err != nil {
 		return "", err
 	}
 
-	// 1. Allocate a single buffer for [nonce + ciphertext + tag]
-	rawSize := gcm.NonceSize() + len(plaintext) + 16 // 16 is standard GCM tag size
-	out := make([]byte, rawSize)
+	return header{encoding: o.encoding, compressed: compressed}.format(payload)
+}
+
+// Decrypt opens a ciphertext sealed by Encrypt, dispatching on its envelope. Ciphertexts sealed
+// before the envelope existed are still accepted.
+func Decrypt(cryptoText string, key []byte) ([]byte, error) {
+	return DecryptWithAAD(cryptoText, key, nil)
+}
 
-	// 2. Generate Nonce directly into the start of the buffer
-	nonce := out[:gcm.NonceSize()]
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return "", err
+// DecryptWithAAD opens a ciphertext sealed by EncryptWithAAD with the same aad.
+func DecryptWithAAD(cryptoText string, key, aad []byte) ([]byte, error) {
+	return decryptWith(cryptoText, aad, keyAEADs(key))
+}
+
+func decryptWith(cryptoText string, aad []byte, aeads aeadSource) ([]byte, error) {
+	if !strings.HasPrefix(cryptoText, envelopePrefix) {
+		return decryptLegacy(cryptoText, aad, aeads)
 	}
 
-	// 3. Encrypt directly into the remainder of the buffer
-	// Seal(dst, nonce, plaintext, data) -> appends to dst
-	gcm.Seal(out[:gcm.NonceSize()], nonce, plaintext, nil)
+	h, payload, err := parseEnvelope(cryptoText)
+	if err != nil {
+		return nil, err
+	}
 
-	// 4. Encode to Base64 string
-	return base64.RawURLEncoding.EncodeToString(out), nil
+	plaintext, err := openWith(payload, envelopeAAD(aad, h.compressed), aeads)
+	if err != nil {
+		return nil, err
+	}
+
+	return decompressPlaintext(plaintext, h.compressed)
 }
 
-func Decrypt(cryptoText string, key []byte) ([]byte, error) {
-	// 1. Decode Base64 string back to bytes
-	data, err := base64.RawURLEncoding.DecodeString(cryptoText)
+// EncryptBytes is Encrypt returning the binary form of the envelope, for storage that does not
+// need text: the format version byte followed by the payload. It saves the third that base64 adds.
+func EncryptBytes(plaintext, key []byte, opts ...Option) ([]byte, error) {
+	return encryptBytesWith(newOptions(opts), plaintext, keyAEADs(key))
+}
+
+func encryptBytesWith(o options, plaintext []byte, aeads aeadSource) ([]byte, error) {
+	plaintext, compressed, err := o.compressPlaintext(plaintext)
 	if err != nil {
 		return nil, err
 	}
 
-	block, err := aes.NewCipher(key)
+	payload, err := o.seal(plaintext, envelopeAAD(nil, compressed), aeads)
 	if err != nil {
 		return nil, err
 	}
 
-	gcm, err := cipher.NewGCM(block)
+	version := byte(binaryEnvelopeVersion)
+	if compressed {
+		version |= binaryCompressedFlag
+	}
+
+	return append([]byte{version}, payload...), nil
+}
+
+// DecryptBytes opens a ciphertext sealed by EncryptBytes.
+func DecryptBytes(ciphertext, key []byte) ([]byte, error) {
+	return decryptBytesWith(ciphertext, keyAEADs(key))
+}
+
+func decryptBytesWith(ciphertext []byte, aeads aeadSource) ([]byte, error) {
+	if len(ciphertext) == 0 {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	version, compressed := ciphertext[0]&^binaryCompressedFlag, ciphertext[0]&binaryCompressedFlag != 0
+	if version != binaryEnvelopeVersion {
+		return nil, fmt.Errorf("unsupported ciphertext version %d", version)
+	}
+
+	plaintext, err := openWith(ciphertext[1:], envelopeAAD(nil, compressed), aeads)
+	if err != nil {
+		return nil, err
+	}
+
+	return decompressPlaintext(plaintext, compressed)
+}
+
+// decryptLegacy opens the raw base64url AES-GCM ciphertexts [nonce + ciphertext + tag] sealed
+// before the envelope existed.
+func decryptLegacy(cryptoText string, aad []byte, aeads aeadSource) ([]byte, error) {
+	// 1. Decode Base64 string back to bytes
+	data, err := base64.RawURLEncoding.DecodeString(cryptoText)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := aeads(AESGCM)
 	if err != nil {
 		return nil, err
 	}
@@ -63,5 +135,68 @@ func Decrypt(cryptoText string, key []byte) ([]byte, error) {
 	nonce, ciphertext := data[:nonceSize], data[nonceSize:]
 
 	// 2. Decrypt in-place using the decoded buffer to save memory
-	return gcm.Open(ciphertext[:0], nonce, ciphertext, nil)
+	return gcm.Open(ciphertext[:0], nonce, ciphertext, aad)
+}
+
+// keyPrefix marks encoded keys, so that they are not mistaken for the raw keys that the functions
+// have been configured with so far, which are often valid base64 as well.
+const keyPrefix = "base64:"
+
+// GenerateKey returns a random AES key of bits bits, 128, 192 or 256.
+func GenerateKey(bits int) ([]byte, error) {
+	switch bits {
+	case 128, 192, 256:
+	default:
+		return nil, fmt.Errorf("key size must be 128, 192 or 256 bits, got %d", bits)
+	}
+
+	key := make([]byte, bits/8)
+
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// EncodeKey encodes key for environment variables and configuration files, as "base64:" followed
+// by its standard base64 encoding.
+func EncodeKey(key []byte) string {
+	return keyPrefix + base64.StdEncoding.EncodeToString(key)
+}
+
+// DecodeKey decodes a key encoded by EncodeKey. Values without the "base64:" prefix are raw keys
+// and returned as is.
+func DecodeKey(encoded string) ([]byte, error) {
+	value, ok := strings.CutPrefix(encoded, keyPrefix)
+	if !ok {
+		return []byte(encoded), nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 key: %w", err)
+	}
+
+	return key, nil
+}
+
+// KeyFromEnv decodes the key in the environment variable name and checks that it can be used with
+// AES-GCM.
+func KeyFromEnv(name string) ([]byte, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil, fmt.Errorf("%s is not set", name)
+	}
+
+	key, err := DecodeKey(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	if err := ValidateKey(key); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return key, nil
 }
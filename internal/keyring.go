@@ -0,0 +1,120 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// keyIDSeparator separates the key ID from the ciphertext. It is not part of the base64url alphabet,
+// so ciphertexts sealed by Encrypt before keyrings existed are told apart by not containing it.
+const keyIDSeparator = "."
+
+// Keyring holds the keys an application has encrypted with over time. Its ciphertexts are prefixed
+// with the ID of the key that sealed them, so that the current key can be rotated while ciphertexts
+// sealed with the previous ones stay readable.
+type Keyring struct {
+	current string
+	keys    map[string][]byte
+}
+
+// NewKeyring returns a keyring encrypting with the key with ID current, which must be one of keys.
+// Key IDs are short names such as "2024-06" made of letters, digits, '-' and '_'.
+func NewKeyring(current string, keys map[string][]byte) (*Keyring, error) {
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("current key %q is not in the keyring", current)
+	}
+
+	ring := &Keyring{current: current, keys: make(map[string][]byte, len(keys))}
+	for id, key := range keys {
+		if err := validateKeyID(id); err != nil {
+			return nil, err
+		}
+
+		ring.keys[id] = key
+	}
+
+	return ring, nil
+}
+
+func validateKeyID(id string) error {
+	if id == "" {
+		return errors.New("key ID must not be empty")
+	}
+
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return fmt.Errorf("key ID %q must only contain letters, digits, '-' and '_'", id)
+		}
+	}
+
+	return nil
+}
+
+// Current returns the ID of the key new ciphertexts are sealed with.
+func (k *Keyring) Current() string {
+	return k.current
+}
+
+// Key returns the key with the given ID, for the other primitives built on the keyring, such as
+// token signatures.
+func (k *Keyring) Key(id string) ([]byte, bool) {
+	key, ok := k.keys[id]
+	return key, ok
+}
+
+// Encrypt seals plaintext with the current key.
+func (k *Keyring) Encrypt(plaintext []byte, opts ...Option) (string, error) {
+	return k.EncryptWithAAD(plaintext, nil, opts...)
+}
+
+// EncryptWithAAD seals plaintext with the current key, bound to aad as with the package level
+// EncryptWithAAD.
+func (k *Keyring) EncryptWithAAD(plaintext, aad []byte, opts ...Option) (string, error) {
+	sealed, err := EncryptWithAAD(plaintext, k.keys[k.current], aad, opts...)
+	if err != nil {
+		return "", err
+	}
+
+	return k.current + keyIDSeparator + sealed, nil
+}
+
+// Decrypt opens a ciphertext sealed by Encrypt with any key of the keyring. Ciphertexts without a
+// key ID were sealed by the package level Encrypt and are tried against every key.
+func (k *Keyring) Decrypt(cryptoText string) ([]byte, error) {
+	return k.DecryptWithAAD(cryptoText, nil)
+}
+
+// DecryptWithAAD opens a ciphertext sealed by EncryptWithAAD with the same aad.
+func (k *Keyring) DecryptWithAAD(cryptoText string, aad []byte) ([]byte, error) {
+	id, sealed, ok := strings.Cut(cryptoText, keyIDSeparator)
+	if !ok {
+		return k.decryptLegacy(cryptoText, aad)
+	}
+
+	key, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("ciphertext was sealed with unknown key %q", id)
+	}
+
+	return DecryptWithAAD(sealed, key, aad)
+}
+
+func (k *Keyring) decryptLegacy(cryptoText string, aad []byte) ([]byte, error) {
+	var err error
+	for _, key := range k.keys {
+		var plaintext []byte
+		if plaintext, err = DecryptWithAAD(cryptoText, key, aad); err == nil {
+			return plaintext, nil
+		}
+	}
+
+	return nil, err
+}
+
+// NeedsRotation reports whether cryptoText was sealed with another key than the current one and
+// should be re-encrypted.
+func (k *Keyring) NeedsRotation(cryptoText string) bool {
+	id, _, ok := strings.Cut(cryptoText, keyIDSeparator)
+	return !ok || id != k.current
+}
@@ -0,0 +1,242 @@
+package internal
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Keystores save keyrings to files for the CLI and local development, so that keys do not have to
+// be pasted into environment variables. Files ending in .pem hold one PEM block per key, any other
+// file is JSON. With a passphrase, every key is sealed with a key derived from it by Argon2id and
+// bound to its ID.
+
+// keystore is a keystore file once decoded: the keys, in clear or sealed with the key derived from
+// the passphrase with the parameters of kdf.
+type keystore struct {
+	current string
+	kdf     *keystoreKDF
+	entries map[string][]byte
+}
+
+// keystoreJSON is the JSON form of a keystore. Keys are encoded by EncodeKey, and sealed keys are
+// text envelopes.
+type keystoreJSON struct {
+	Current string            `json:"current"`
+	KDF     *keystoreKDF      `json:"kdf,omitempty"`
+	Keys    map[string]string `json:"keys"`
+}
+
+// keystoreKDF records how the key protecting a keystore derives from its passphrase.
+type keystoreKDF struct {
+	Salt    string `json:"salt"`
+	Time    uint32 `json:"time"`
+	Memory  uint32 `json:"memory"`
+	Threads uint8  `json:"threads"`
+}
+
+func (k *keystoreKDF) derive(passphrase []byte) ([]byte, error) {
+	salt, err := DecodeSalt(k.Salt)
+	if err != nil {
+		return nil, err
+	}
+
+	return DeriveKeyWithParams(passphrase, salt, Argon2Params{Time: k.Time, Memory: k.Memory, Threads: k.Threads})
+}
+
+const (
+	keyBlockType          = "NAWA KEY"
+	encryptedKeyBlockType = "ENCRYPTED NAWA KEY"
+)
+
+// SaveKeystore writes the keys of ring to path, readable only by its owner. An empty passphrase
+// stores them in clear.
+func SaveKeystore(path string, ring *Keyring, passphrase []byte) error {
+	store := keystore{current: ring.current, entries: map[string][]byte{}}
+
+	var protection []byte
+	if len(passphrase) > 0 {
+		salt, err := NewSalt()
+		if err != nil {
+			return err
+		}
+
+		params := DefaultArgon2Params
+		store.kdf = &keystoreKDF{Salt: EncodeSalt(salt), Time: params.Time, Memory: params.Memory, Threads: params.Threads}
+		if protection, err = store.kdf.derive(passphrase); err != nil {
+			return err
+		}
+		defer clear(protection)
+	}
+
+	for id, key := range ring.keys {
+		if protection == nil {
+			store.entries[id] = key
+			continue
+		}
+
+		sealed, err := seal(AESGCM, key, protection, []byte(id))
+		if err != nil {
+			return err
+		}
+		store.entries[id] = sealed
+	}
+
+	var data []byte
+	if isPEM(path) {
+		data = store.pem()
+	} else {
+		var err error
+		if data, err = store.json(); err != nil {
+			return err
+		}
+	}
+
+	return os.WriteFile(path, data, 0o600)
+}
+
+// LoadKeystore reads a keyring saved by SaveKeystore, with the passphrase it was saved with.
+func LoadKeystore(path string, passphrase []byte) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var store keystore
+	if isPEM(path) {
+		store, err = parseKeystorePEM(data)
+	} else {
+		store, err = parseKeystoreJSON(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid keystore %s: %w", path, err)
+	}
+
+	if store.kdf == nil {
+		return NewKeyring(store.current, store.entries)
+	}
+
+	if len(passphrase) == 0 {
+		return nil, fmt.Errorf("keystore %s is protected by a passphrase", path)
+	}
+
+	protection, err := store.kdf.derive(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(protection)
+
+	keys := make(map[string][]byte, len(store.entries))
+	for id, sealed := range store.entries {
+		if keys[id], err = open(sealed, protection, []byte(id)); err != nil {
+			return nil, fmt.Errorf("failed to open key %q, is the passphrase right? %w", id, err)
+		}
+	}
+
+	return NewKeyring(store.current, keys)
+}
+
+func isPEM(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".pem")
+}
+
+func (s keystore) json() ([]byte, error) {
+	file := keystoreJSON{Current: s.current, KDF: s.kdf, Keys: map[string]string{}}
+	for id, entry := range s.entries {
+		if s.kdf == nil {
+			file.Keys[id] = EncodeKey(entry)
+			continue
+		}
+
+		sealed, err := header{encoding: Base64URL}.format(entry)
+		if err != nil {
+			return nil, err
+		}
+		file.Keys[id] = sealed
+	}
+
+	return json.MarshalIndent(file, "", "  ")
+}
+
+func parseKeystoreJSON(data []byte) (keystore, error) {
+	var file keystoreJSON
+	if err := json.Unmarshal(data, &file); err != nil {
+		return keystore{}, err
+	}
+
+	store := keystore{current: file.Current, kdf: file.KDF, entries: map[string][]byte{}}
+	for id, value := range file.Keys {
+		var err error
+		if file.KDF == nil {
+			store.entries[id], err = DecodeKey(value)
+		} else {
+			_, store.entries[id], err = parseEnvelope(value)
+		}
+		if err != nil {
+			return keystore{}, fmt.Errorf("key %q: %w", id, err)
+		}
+	}
+
+	return store, nil
+}
+
+// pem encodes the keystore as one block per key, holding the key or the sealed key. The current
+// key and the parameters of the passphrase are recorded in the headers of the blocks.
+func (s keystore) pem() []byte {
+	var out []byte
+	for _, id := range slices.Sorted(maps.Keys(s.entries)) {
+		block := &pem.Block{Type: keyBlockType, Headers: map[string]string{"Key-Id": id}, Bytes: s.entries[id]}
+		if s.kdf != nil {
+			block.Type = encryptedKeyBlockType
+			block.Headers["Salt"] = s.kdf.Salt
+			block.Headers["Argon2id"] = fmt.Sprintf("m=%d,t=%d,p=%d", s.kdf.Memory, s.kdf.Time, s.kdf.Threads)
+		}
+		if id == s.current {
+			block.Headers["Current"] = "true"
+		}
+
+		out = append(out, pem.EncodeToMemory(block)...)
+	}
+
+	return out
+}
+
+func parseKeystorePEM(data []byte) (keystore, error) {
+	store := keystore{entries: map[string][]byte{}}
+	for {
+		var block *pem.Block
+		if block, data = pem.Decode(data); block == nil {
+			break
+		}
+
+		if block.Type != keyBlockType && block.Type != encryptedKeyBlockType {
+			continue
+		}
+
+		id := block.Headers["Key-Id"]
+		store.entries[id] = block.Bytes
+		if block.Headers["Current"] == "true" {
+			store.current = id
+		}
+
+		if block.Type == encryptedKeyBlockType {
+			kdf := &keystoreKDF{Salt: block.Headers["Salt"]}
+			if _, err := fmt.Sscanf(block.Headers["Argon2id"], "m=%d,t=%d,p=%d", &kdf.Memory, &kdf.Time, &kdf.Threads); err != nil {
+				return keystore{}, fmt.Errorf("invalid Argon2id header of key %q", id)
+			}
+			store.kdf = kdf
+		}
+	}
+
+	if len(store.entries) == 0 {
+		return keystore{}, errors.New("no keys found")
+	}
+
+	return store, nil
+}
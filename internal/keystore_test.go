@@ -0,0 +1,182 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testKeyring(t *testing.T) *Keyring {
+	t.Helper()
+
+	keys := map[string][]byte{}
+	for _, id := range []string{"a", "b"} {
+		key, err := GenerateKey(256)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[id] = key
+	}
+
+	ring, err := NewKeyring("b", keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ring
+}
+
+func assertSameKeyring(t *testing.T, got, want *Keyring) {
+	t.Helper()
+
+	if got.Current() != want.Current() {
+		t.Errorf("Current = %q, want %q", got.Current(), want.Current())
+	}
+	if len(got.keys) != len(want.keys) {
+		t.Errorf("loaded %d keys, want %d", len(got.keys), len(want.keys))
+	}
+	for id, key := range want.keys {
+		if loaded, ok := got.Key(id); !ok || !bytes.Equal(loaded, key) {
+			t.Errorf("key %q was not loaded as saved", id)
+		}
+	}
+}
+
+func TestKeystoreRoundTrip(t *testing.T) {
+	ring := testKeyring(t)
+
+	tests := []struct {
+		name, file, passphrase string
+	}{
+		{name: "JSON", file: "keys.json"},
+		{name: "PEM", file: "keys.pem"},
+		{name: "JSON with passphrase", file: "keys.json", passphrase: "correct horse"},
+		{name: "PEM with passphrase", file: "keys.PEM", passphrase: "correct horse"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := SaveKeystore(path, ring, []byte(tt.passphrase)); err != nil {
+				t.Fatal(err)
+			}
+
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if mode := info.Mode().Perm(); mode != 0o600 {
+				t.Errorf("mode = %v, want 0600", mode)
+			}
+
+			loaded, err := LoadKeystore(path, []byte(tt.passphrase))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertSameKeyring(t, loaded, ring)
+
+			if tt.passphrase == "" {
+				return
+			}
+
+			if _, err := LoadKeystore(path, nil); err == nil {
+				t.Error("LoadKeystore without the passphrase succeeded")
+			}
+			if _, err := LoadKeystore(path, []byte("wrong horse")); err == nil {
+				t.Error("LoadKeystore with another passphrase succeeded")
+			}
+		})
+	}
+}
+
+func TestKeystoreRejectsTampering(t *testing.T) {
+	ring := testKeyring(t)
+	dir := t.TempDir()
+	passphrase := []byte("correct horse")
+
+	jsonPath := filepath.Join(dir, "keys.json")
+	if err := SaveKeystore(jsonPath, ring, passphrase); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pemPath := filepath.Join(dir, "keys.pem")
+	if err := SaveKeystore(pemPath, ring, passphrase); err != nil {
+		t.Fatal(err)
+	}
+	pemData, err := os.ReadFile(pemPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tamperJSON := func(edit func(file *keystoreJSON)) []byte {
+		var file keystoreJSON
+		if err := json.Unmarshal(data, &file); err != nil {
+			t.Fatal(err)
+		}
+		edit(&file)
+		tampered, err := json.Marshal(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tampered
+	}
+	flip := func(s string) string {
+		b := []byte(s)
+		if b[len(b)-2] == 'A' {
+			b[len(b)-2] = 'B'
+		} else {
+			b[len(b)-2] = 'A'
+		}
+		return string(b)
+	}
+
+	tests := []struct {
+		name, file string
+		data       []byte
+	}{
+		{name: "truncated JSON", file: "keys.json", data: data[:len(data)/2]},
+		{name: "sealed key", file: "keys.json", data: tamperJSON(func(file *keystoreJSON) {
+			file.Keys["a"] = flip(file.Keys["a"])
+		})},
+		{name: "truncated sealed key", file: "keys.json", data: tamperJSON(func(file *keystoreJSON) {
+			file.Keys["a"] = file.Keys["a"][:len(file.Keys["a"])-8]
+		})},
+		// Keys are bound to their IDs, so that they cannot be swapped.
+		{name: "swapped keys", file: "keys.json", data: tamperJSON(func(file *keystoreJSON) {
+			file.Keys["a"], file.Keys["b"] = file.Keys["b"], file.Keys["a"]
+		})},
+		{name: "salt", file: "keys.json", data: tamperJSON(func(file *keystoreJSON) {
+			file.KDF.Salt = flip(file.KDF.Salt)
+		})},
+		{name: "memory", file: "keys.json", data: tamperJSON(func(file *keystoreJSON) {
+			file.KDF.Memory /= 2
+		})},
+		{name: "current", file: "keys.json", data: tamperJSON(func(file *keystoreJSON) {
+			file.Current = "c"
+		})},
+		{name: "no PEM blocks", file: "keys.pem", data: []byte("not a keystore\n")},
+		{name: "truncated PEM", file: "keys.pem", data: pemData[:bytes.Index(pemData, []byte("-----END"))]},
+		{name: "swapped PEM blocks", file: "keys.pem", data: []byte(strings.NewReplacer("Key-Id: a", "Key-Id: b", "Key-Id: b", "Key-Id: a").Replace(string(pemData)))},
+		{name: "PEM Argon2id header", file: "keys.pem", data: []byte(strings.Replace(string(pemData), "Argon2id: m=", "Argon2id: x=", 1))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, tt.data, 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := LoadKeystore(path, passphrase); err == nil {
+				t.Error("LoadKeystore succeeded")
+			}
+		})
+	}
+}
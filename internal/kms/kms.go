@@ -0,0 +1,79 @@
+// Package kms implements internal.KeyProvider with AWS KMS, so that master keys never leave KMS
+// and the functions only hold keys wrapped by it.
+package kms
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"nawa-functions/internal"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	awskms "github.com/aws/aws-sdk-go-v2/service/kms"
+)
+
+// Provider wraps data keys with a KMS key.
+type Provider struct {
+	client *awskms.Client
+	keyID  string
+}
+
+var _ internal.KeyProvider = (*Provider)(nil)
+
+// New returns a provider wrapping data keys with the KMS key keyID, an ID, ARN or alias. Netlify
+// reserves the AWS_ environment variables, so credentials and region are read from kms_access_key_id,
+// kms_secret_access_key and kms_region when they are set, and from the default AWS configuration
+// otherwise.
+func New(ctx context.Context, keyID string) (*Provider, error) {
+	var opts []func(*config.LoadOptions) error
+	if region := os.Getenv("kms_region"); region != "" {
+		opts = append(opts, config.WithRegion(region))
+	}
+
+	if id := os.Getenv("kms_access_key_id"); id != "" {
+		opts = append(opts, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(id, os.Getenv("kms_secret_access_key"), "")))
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Provider{client: awskms.NewFromConfig(cfg), keyID: keyID}, nil
+}
+
+// GetKey decrypts the key in the environment variable name, which holds the base64 encoded
+// ciphertext blob returned by the KMS Encrypt or GenerateDataKey APIs.
+func (p *Provider) GetKey(ctx context.Context, name string) ([]byte, error) {
+	blob, err := base64.StdEncoding.DecodeString(os.Getenv(name))
+	if err != nil || len(blob) == 0 {
+		return nil, fmt.Errorf("%s must hold a base64 KMS ciphertext blob", name)
+	}
+
+	return p.UnwrapKey(ctx, blob)
+}
+
+// WrapKey encrypts key with the KMS key.
+func (p *Provider) WrapKey(ctx context.Context, key []byte) ([]byte, error) {
+	out, err := p.client.Encrypt(ctx, &awskms.EncryptInput{KeyId: aws.String(p.keyID), Plaintext: key})
+	if err != nil {
+		return nil, err
+	}
+
+	return out.CiphertextBlob, nil
+}
+
+// UnwrapKey decrypts a key encrypted with the KMS key. Decryption is pinned to the key of the
+// provider, so that blobs encrypted with another key the credentials can use are rejected.
+func (p *Provider) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	out, err := p.client.Decrypt(ctx, &awskms.DecryptInput{KeyId: aws.String(p.keyID), CiphertextBlob: wrapped})
+	if err != nil {
+		return nil, err
+	}
+
+	return out.Plaintext, nil
+}
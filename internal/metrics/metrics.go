@@ -0,0 +1,289 @@
+// Package metrics accumulates request, cache and upstream metrics of the functions in Redis, so
+// that they survive across invocations and containers, and exports them in the Prometheus text
+// format. Observations are collected in the context of a request and written in a single pipeline
+// once it has been served.
+package metrics
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/redis/go-redis/v9"
+)
+
+// Key is the Redis hash holding every series, keyed by series name and labels.
+const Key = "metrics"
+
+// recentWindow is the span of the hourly upstream counters that RecentUpstream reads, which expire
+// once they no longer fall into it.
+const recentWindow = time.Hour
+
+// recentKey returns the Redis hash counting the upstream requests of the hour of t by host and
+// outcome.
+func recentKey(t time.Time) string {
+	return "metrics:upstream:" + strconv.FormatInt(t.Truncate(recentWindow).Unix(), 10)
+}
+
+// durationBuckets are the upper bounds of the latency histograms in seconds.
+var durationBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// families are the exported metrics with their types and help texts.
+var families = map[string][2]string{
+	"nawa_requests_total":            {"counter", "Requests served, by function, route and status code."},
+	"nawa_request_errors_total":      {"counter", "Requests answered with a 5xx status code, by function and route."},
+	"nawa_request_duration_seconds":  {"histogram", "Time to serve requests, by function and route."},
+	"nawa_cache_lookups_total":       {"counter", "Cache lookups, by function, key namespace and result: hit, miss or error."},
+	"nawa_upstream_requests_total":   {"counter", "Upstream requests, by host and outcome."},
+	"nawa_upstream_duration_seconds": {"histogram", "Latency of upstream requests, by host."},
+}
+
+// cacheLookups identifies a counter of cache lookups by key namespace and result.
+type cacheLookups struct {
+	namespace string
+	result    string
+}
+
+type upstreamObservation struct {
+	host     string
+	duration time.Duration
+	failed   bool
+}
+
+// collector gathers the observations of a single request.
+type collector struct {
+	mu       sync.Mutex
+	route    string
+	cache    map[cacheLookups]int
+	upstream []upstreamObservation
+}
+
+type collectorKey struct{}
+
+func fromContext(ctx context.Context) *collector {
+	c, _ := ctx.Value(collectorKey{}).(*collector)
+	return c
+}
+
+// SetRoute records the route pattern that served the request of ctx, such as "GET /forward".
+func SetRoute(ctx context.Context, route string) {
+	if c := fromContext(ctx); c != nil {
+		c.mu.Lock()
+		c.route = route
+		c.mu.Unlock()
+	}
+}
+
+// CacheLookup records a cache lookup of the request of ctx in the key namespace, such as "geo:fwd",
+// with its result: "hit", "miss" or "error".
+func CacheLookup(ctx context.Context, namespace, result string) {
+	if c := fromContext(ctx); c != nil {
+		c.mu.Lock()
+		if c.cache == nil {
+			c.cache = map[cacheLookups]int{}
+		}
+		c.cache[cacheLookups{namespace: namespace, result: result}]++
+		c.mu.Unlock()
+	}
+}
+
+// ObserveUpstream records an upstream request made for the request of ctx.
+func ObserveUpstream(ctx context.Context, host string, duration time.Duration, failed bool) {
+	if c := fromContext(ctx); c != nil {
+		c.mu.Lock()
+		c.upstream = append(c.upstream, upstreamObservation{host: host, duration: duration, failed: failed})
+		c.mu.Unlock()
+	}
+}
+
+// labels formats label pairs as a Prometheus label set, escaping the values.
+func labels(pairs ...string) string {
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	parts := make([]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		parts = append(parts, pairs[i]+`="`+escaper.Replace(pairs[i+1])+`"`)
+	}
+
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// observe adds an observation to the buckets, sum and count of a histogram.
+func observe(ctx context.Context, pipe redis.Pipeliner, name, labelSet string, seconds float64) {
+	inner := strings.TrimSuffix(strings.TrimPrefix(labelSet, "{"), "}")
+	for _, bound := range durationBuckets {
+		if seconds <= bound {
+			pipe.HIncrBy(ctx, Key, name+"_bucket{"+inner+`,le="`+strconv.FormatFloat(bound, 'g', -1, 64)+`"}`, 1)
+		}
+	}
+	pipe.HIncrBy(ctx, Key, name+"_bucket{"+inner+`,le="+Inf"}`, 1)
+	pipe.HIncrByFloat(ctx, Key, name+"_sum"+labelSet, seconds)
+	pipe.HIncrBy(ctx, Key, name+"_count"+labelSet, 1)
+}
+
+// flush writes the observations of a served request to Redis.
+func (c *collector) flush(ctx context.Context, client *redis.Client, function string, status int, duration time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	route := c.route
+	if route == "" {
+		route = "unmatched"
+	}
+
+	pipe := client.Pipeline()
+	routeLabels := labels("function", function, "route", route)
+	pipe.HIncrBy(ctx, Key, "nawa_requests_total"+labels("function", function, "route", route, "status", strconv.Itoa(status)), 1)
+	if status >= 500 {
+		pipe.HIncrBy(ctx, Key, "nawa_request_errors_total"+routeLabels, 1)
+	}
+	observe(ctx, pipe, "nawa_request_duration_seconds", routeLabels, duration.Seconds())
+
+	for lookups, count := range c.cache {
+		pipe.HIncrBy(ctx, Key, "nawa_cache_lookups_total"+labels("function", function, "namespace", lookups.namespace, "result", lookups.result), int64(count))
+	}
+
+	recent := recentKey(time.Now())
+	for _, o := range c.upstream {
+		outcome := "ok"
+		if o.failed {
+			outcome = "error"
+		}
+		pipe.HIncrBy(ctx, Key, "nawa_upstream_requests_total"+labels("host", o.host, "outcome", outcome), 1)
+		observe(ctx, pipe, "nawa_upstream_duration_seconds", labels("host", o.host), o.duration.Seconds())
+		pipe.HIncrBy(ctx, recent, o.host+":"+outcome, 1)
+	}
+	if len(c.upstream) > 0 {
+		pipe.Expire(ctx, recent, 2*recentWindow)
+	}
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Handler is the signature of the Lambda handlers returned by api.Handler.
+type Handler func(ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error)
+
+// Instrument wraps the Lambda handler of function so that the metrics of every request are
+// recorded in Redis. Failing to record them never fails the request.
+func Instrument(client *redis.Client, function string, handler Handler) Handler {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+		c := &collector{}
+		start := time.Now()
+		res, err := handler(context.WithValue(ctx, collectorKey{}, c), request)
+
+		status := 500
+		if res != nil && err == nil {
+			status = res.StatusCode
+		}
+		if flushErr := c.flush(ctx, client, function, status, time.Since(start)); flushErr != nil {
+			// The api package imports this one, so its logger cannot be used here.
+			slog.WarnContext(ctx, "failed to record metrics", slog.Any("error", flushErr))
+		}
+
+		return res, err
+	}
+}
+
+// RecentUpstream returns the number of upstream requests made to host and how many of them failed,
+// over the current and the previous hour.
+func RecentUpstream(ctx context.Context, client *redis.Client, host string) (requests, failures int64, err error) {
+	now := time.Now()
+	pipe := client.Pipeline()
+	var results []*redis.SliceCmd
+	for _, t := range []time.Time{now, now.Add(-recentWindow)} {
+		results = append(results, pipe.HMGet(ctx, recentKey(t), host+":ok", host+":error"))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return 0, 0, err
+	}
+
+	for _, result := range results {
+		for i, value := range result.Val() {
+			counted, _ := value.(string)
+			count, _ := strconv.ParseInt(counted, 10, 64)
+			requests += count
+			if i == 1 {
+				failures += count
+			}
+		}
+	}
+
+	return requests, failures, nil
+}
+
+// seriesOrder sorts the series of a family by their labels, with the buckets of a histogram in
+// increasing order of their bounds, followed by its sum and count.
+func seriesOrder(a, b string) int {
+	key := func(name string) (string, int, float64) {
+		family, labelSet, _ := strings.Cut(name, "{")
+		rank := 0
+		switch {
+		case strings.HasSuffix(family, "_sum"):
+			rank = 1
+		case strings.HasSuffix(family, "_count"):
+			rank = 2
+		}
+
+		bound := 0.0
+		if i := strings.LastIndex(labelSet, `,le="`); i >= 0 {
+			bound, _ = strconv.ParseFloat(strings.TrimSuffix(labelSet[i+5:], `"}`), 64)
+			labelSet = labelSet[:i] + "}"
+		}
+
+		return labelSet, rank, bound
+	}
+
+	labelsA, rankA, boundA := key(a)
+	labelsB, rankB, boundB := key(b)
+	return cmp.Or(strings.Compare(labelsA, labelsB), cmp.Compare(rankA, rankB), cmp.Compare(boundA, boundB))
+}
+
+// family returns the metric family of a series, stripping the suffixes of histogram series.
+func family(name string) string {
+	base, _, _ := strings.Cut(name, "{")
+	for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+		if trimmed, ok := strings.CutSuffix(base, suffix); ok && families[trimmed][0] == "histogram" {
+			return trimmed
+		}
+	}
+
+	return base
+}
+
+// Export renders every series stored in Redis in the Prometheus text exposition format.
+func Export(ctx context.Context, client *redis.Client) (string, error) {
+	series, err := client.HGetAll(ctx, Key).Result()
+	if err != nil {
+		return "", err
+	}
+
+	byFamily := map[string][]string{}
+	for name := range series {
+		f := family(name)
+		byFamily[f] = append(byFamily[f], name)
+	}
+
+	var b strings.Builder
+	for _, f := range slices.Sorted(maps.Keys(byFamily)) {
+		if info, ok := families[f]; ok {
+			fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", f, info[1], f, info[0])
+		}
+
+		names := byFamily[f]
+		slices.SortFunc(names, seriesOrder)
+		for _, name := range names {
+			fmt.Fprintf(&b, "%s %s\n", name, series[name])
+		}
+	}
+
+	return b.String(), nil
+}
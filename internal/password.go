@@ -0,0 +1,89 @@
+package internal
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// passwordHashSize is the size of the Argon2id output stored in password hashes.
+const passwordHashSize = 32
+
+var errInvalidPasswordHash = errors.New("invalid password hash")
+
+// HashPassword hashes password with Argon2id, a random salt and the default parameters. The hash
+// is in the PHC string format other Argon2 implementations read, such as
+// "$argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash>", so that it records everything VerifyPassword
+// needs, including the parameters, which can then be raised without invalidating existing hashes.
+func HashPassword(password string) (string, error) {
+	return HashPasswordWithParams(password, DefaultArgon2Params)
+}
+
+// HashPasswordWithParams is HashPassword with tunable Argon2id parameters.
+func HashPasswordWithParams(password string, params Argon2Params) (string, error) {
+	if err := params.validate(); err != nil {
+		return "", err
+	}
+
+	salt, err := NewSalt()
+	if err != nil {
+		return "", err
+	}
+
+	hash := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, passwordHashSize)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, params.Memory, params.Time, params.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(hash)), nil
+}
+
+// VerifyPassword reports whether password matches a hash returned by HashPassword. An error is
+// only returned for malformed hashes.
+func VerifyPassword(password, encoded string) (bool, error) {
+	params, salt, hash, err := parsePasswordHash(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	computed := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(len(hash)))
+	return subtle.ConstantTimeCompare(computed, hash) == 1, nil
+}
+
+// PasswordNeedsRehash reports whether a hash was computed with other parameters than params, so
+// that callers can rehash the password once it has been verified.
+func PasswordNeedsRehash(encoded string, params Argon2Params) bool {
+	current, _, _, err := parsePasswordHash(encoded)
+	return err != nil || current != params
+}
+
+func parsePasswordHash(encoded string) (params Argon2Params, salt, hash []byte, err error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" {
+		return params, nil, nil, errInvalidPasswordHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2 version %q", parts[2])
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil {
+		return params, nil, nil, errInvalidPasswordHash
+	}
+
+	if err := params.validate(); err != nil {
+		return params, nil, nil, err
+	}
+
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return params, nil, nil, errInvalidPasswordHash
+	}
+
+	if hash, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(hash) == 0 {
+		return params, nil, nil, errInvalidPasswordHash
+	}
+
+	return params, salt, hash, nil
+}
@@ -0,0 +1,125 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+// testArgon2Params keep the tests fast; the format and verification do not depend on the cost.
+var testArgon2Params = Argon2Params{Time: 1, Memory: 64, Threads: 2}
+
+func mustHashPassword(t *testing.T, password string, params Argon2Params) string {
+	t.Helper()
+
+	hash, err := HashPasswordWithParams(password, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return hash
+}
+
+func TestPasswordRoundTrip(t *testing.T) {
+	hash := mustHashPassword(t, "correct horse", testArgon2Params)
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=2$") {
+		t.Errorf("hash = %q, want the PHC string format", hash)
+	}
+
+	if ok, err := VerifyPassword("correct horse", hash); err != nil || !ok {
+		t.Errorf("VerifyPassword(password) = %v, %v", ok, err)
+	}
+	if ok, err := VerifyPassword("correct horse ", hash); err != nil || ok {
+		t.Errorf("VerifyPassword(other password) = %v, %v", ok, err)
+	}
+	if ok, err := VerifyPassword("", hash); err != nil || ok {
+		t.Errorf("VerifyPassword(empty password) = %v, %v", ok, err)
+	}
+
+	if other := mustHashPassword(t, "correct horse", testArgon2Params); other == hash {
+		t.Error("hashes of the same password share their salt")
+	}
+}
+
+func TestPasswordNeedsRehash(t *testing.T) {
+	hash := mustHashPassword(t, "correct horse", testArgon2Params)
+
+	if PasswordNeedsRehash(hash, testArgon2Params) {
+		t.Error("PasswordNeedsRehash(same params) = true")
+	}
+	if !PasswordNeedsRehash(hash, Argon2Params{Time: 2, Memory: 64, Threads: 2}) {
+		t.Error("PasswordNeedsRehash(raised params) = false")
+	}
+	if !PasswordNeedsRehash("not a hash", testArgon2Params) {
+		t.Error("PasswordNeedsRehash(malformed hash) = false")
+	}
+}
+
+func TestPasswordRejectsInvalidParams(t *testing.T) {
+	for _, params := range []Argon2Params{{Time: 0, Memory: 64, Threads: 1}, {Time: 1, Memory: 64, Threads: 0}, {Time: 1, Memory: 8, Threads: 2}} {
+		if _, err := HashPasswordWithParams("correct horse", params); err == nil {
+			t.Errorf("HashPasswordWithParams(%+v) succeeded", params)
+		}
+	}
+}
+
+func TestPasswordRejectsTampering(t *testing.T) {
+	hash := mustHashPassword(t, "correct horse", testArgon2Params)
+	parts := strings.Split(hash, "$")
+	with := func(i int, value string) string {
+		tampered := append([]string(nil), parts...)
+		tampered[i] = value
+		return strings.Join(tampered, "$")
+	}
+	flip := func(s string) string {
+		b := []byte(s)
+		if b[0] == 'A' {
+			b[0] = 'B'
+		} else {
+			b[0] = 'A'
+		}
+		return string(b)
+	}
+
+	// Hashes that parse but no longer match the password.
+	mismatched := []struct {
+		name, hash string
+	}{
+		{name: "salt", hash: with(4, flip(parts[4]))},
+		{name: "hash", hash: with(5, flip(parts[5]))},
+		{name: "time", hash: with(3, "m=64,t=2,p=2")},
+		{name: "memory", hash: with(3, "m=128,t=1,p=2")},
+		{name: "threads", hash: with(3, "m=64,t=1,p=1")},
+		{name: "swapped salt and hash", hash: with(5, parts[4])},
+		{name: "truncated hash", hash: with(5, parts[5][:len(parts[5])-4])},
+	}
+
+	for _, tt := range mismatched {
+		t.Run(tt.name, func(t *testing.T) {
+			if ok, err := VerifyPassword("correct horse", tt.hash); ok {
+				t.Errorf("VerifyPassword = %v, %v", ok, err)
+			}
+		})
+	}
+
+	malformed := []struct {
+		name, hash string
+	}{
+		{name: "empty", hash: ""},
+		{name: "truncated", hash: strings.Join(parts[:5], "$")},
+		{name: "algorithm", hash: with(1, "argon2i")},
+		{name: "version", hash: with(2, "v=16")},
+		{name: "params", hash: with(3, "t=1,m=64,p=2")},
+		{name: "invalid params", hash: with(3, "m=64,t=0,p=2")},
+		{name: "salt encoding", hash: with(4, parts[4]+"=")},
+		{name: "hash encoding", hash: with(5, "!")},
+		{name: "empty hash", hash: with(5, "")},
+	}
+
+	for _, tt := range malformed {
+		t.Run(tt.name, func(t *testing.T) {
+			if ok, err := VerifyPassword("correct horse", tt.hash); ok || err == nil {
+				t.Errorf("VerifyPassword = %v, %v, want an error", ok, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+// Package prewarm tracks the most requested locations for the prewarm function, which refreshes
+// their cache entries before they expire.
+package prewarm
+
+import (
+	"context"
+	"log/slog"
+	"nawa-functions/internal/api"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Sorted sets of request counts, by normalized forward query and by rounded "lat,lon" forecast
+// location.
+const (
+	ForwardKey  = "prewarm:forward"
+	ForecastKey = "prewarm:forecast"
+)
+
+// Track counts a request for member in the sorted set key. Failures are only logged, tracking must
+// never fail a request.
+func Track(ctx context.Context, client *redis.Client, key, member string) {
+	if api.Refreshing(ctx) {
+		return
+	}
+
+	if err := client.ZIncrBy(ctx, key, 1, member).Err(); err != nil {
+		api.Logger.WarnContext(ctx, "failed to track request", slog.String("key", key), slog.Any("error", err))
+	}
+}
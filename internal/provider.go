@@ -0,0 +1,90 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// KeyProvider supplies keys and wraps data keys with a master key it may never reveal, such as a
+// key managed by AWS KMS, so that deployments do not have to hold raw key material.
+type KeyProvider interface {
+	// GetKey returns the key named name.
+	GetKey(ctx context.Context, name string) ([]byte, error)
+	// WrapKey encrypts a data key with the master key of the provider.
+	WrapKey(ctx context.Context, key []byte) ([]byte, error)
+	// UnwrapKey decrypts a data key wrapped by WrapKey.
+	UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// EnvKeyProvider is a KeyProvider reading keys from environment variables with KeyFromEnv. It wraps
+// data keys with the same format as Encrypt WithDataKey, so ciphertexts sealed with it can also be
+// opened by Decrypt with the master key.
+type EnvKeyProvider struct {
+	master []byte
+}
+
+// NewEnvKeyProvider returns a provider wrapping data keys with the master key in the environment
+// variable masterKeyName.
+func NewEnvKeyProvider(masterKeyName string) (*EnvKeyProvider, error) {
+	master, err := KeyFromEnv(masterKeyName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EnvKeyProvider{master: master}, nil
+}
+
+// GetKey returns the key in the environment variable name.
+func (p *EnvKeyProvider) GetKey(_ context.Context, name string) ([]byte, error) {
+	return KeyFromEnv(name)
+}
+
+// WrapKey seals key with the master key.
+func (p *EnvKeyProvider) WrapKey(_ context.Context, key []byte) ([]byte, error) {
+	return wrapWith(AESGCM, p.master)(key)
+}
+
+// UnwrapKey opens a key sealed by WrapKey.
+func (p *EnvKeyProvider) UnwrapKey(_ context.Context, wrapped []byte) ([]byte, error) {
+	return unwrapWith(p.master)(wrapped)
+}
+
+// EncryptWithProvider is Encrypt WithDataKey, with the data key wrapped by provider.
+func EncryptWithProvider(ctx context.Context, plaintext []byte, provider KeyProvider, opts ...Option) (string, error) {
+	o := newOptions(opts)
+	plaintext, compressed, err := o.compressPlaintext(plaintext)
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := sealWithDataKey(o.suite, plaintext, envelopeAAD(nil, compressed), func(dataKey []byte) ([]byte, error) {
+		return provider.WrapKey(ctx, dataKey)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return header{encoding: o.encoding, compressed: compressed}.format(payload)
+}
+
+// DecryptWithProvider opens a ciphertext sealed by EncryptWithProvider with the same provider.
+func DecryptWithProvider(ctx context.Context, cryptoText string, provider KeyProvider) ([]byte, error) {
+	if !strings.HasPrefix(cryptoText, envelopePrefix) {
+		return nil, errors.New("ciphertext was not sealed with a data key")
+	}
+
+	h, payload, err := parseEnvelope(cryptoText)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := openDataKey(payload, envelopeAAD(nil, h.compressed), func(wrapped []byte) ([]byte, error) {
+		return provider.UnwrapKey(ctx, wrapped)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return decompressPlaintext(plaintext, h.compressed)
+}
@@ -0,0 +1,50 @@
+// Package push stores the Web Push subscriptions of the nawa app for the push function, which
+// manages them, and the push-alerts function, which notifies them of severe weather alerts.
+// Subscriptions are encrypted at rest since their endpoints and keys allow sending notifications
+// to the subscriber.
+package push
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"nawa-functions/internal"
+
+	"github.com/SherClockHolmes/webpush-go"
+)
+
+// SubscriptionsKey is the Redis hash holding the sealed records, keyed by ID.
+const SubscriptionsKey = "push:subscriptions"
+
+var encryptionKey, encryptionKeyErr = internal.SecretFromEnv("push_key")
+
+// Record is a Web Push subscription with the location whose alerts it receives.
+type Record struct {
+	Subscription webpush.Subscription `json:"subscription"`
+	Lat          float64              `json:"lat"`
+	Lon          float64              `json:"lon"`
+}
+
+// ID returns the identifier of the subscription with the given endpoint. The endpoint is hashed so
+// that it does not appear in Redis in clear text.
+func ID(endpoint string) string {
+	sum := sha256.Sum256([]byte(endpoint))
+	return hex.EncodeToString(sum[:])
+}
+
+// Seal encrypts record for storage.
+func Seal(record Record) (string, error) {
+	if encryptionKeyErr != nil {
+		return "", encryptionKeyErr
+	}
+
+	return internal.EncryptJSON(record, encryptionKey)
+}
+
+// Open decrypts a record sealed by Seal.
+func Open(sealed string) (Record, error) {
+	if encryptionKeyErr != nil {
+		return Record{}, encryptionKeyErr
+	}
+
+	return internal.DecryptJSON[Record](sealed, encryptionKey)
+}
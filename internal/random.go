@@ -0,0 +1,22 @@
+package internal
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+)
+
+// NewToken returns nBytes random bytes encoded as base64url without padding, for identifiers and
+// secrets that end up in URLs, headers and Redis keys. 16 bytes are enough for anything that must
+// not be guessed.
+func NewToken(nBytes int) string {
+	b := make([]byte, nBytes)
+	// crypto/rand.Read never fails, it crashes the program when the system cannot provide randomness.
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// NewPrefixedToken returns a NewToken prefixed with prefix and an underscore, such as "nawa_...",
+// so that the kind of a token can be told at a glance and secret scanners can recognize it.
+func NewPrefixedToken(prefix string, nBytes int) string {
+	return prefix + "_" + NewToken(nBytes)
+}
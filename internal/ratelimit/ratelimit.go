@@ -0,0 +1,100 @@
+// Package ratelimit counts requests against fixed window budgets in Redis.
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"nawa-functions/internal/api"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/redis/go-redis/v9"
+)
+
+// Limit is a budget of requests per fixed window.
+type Limit struct {
+	Name     string
+	Requests int
+	Window   time.Duration
+}
+
+// Result is the state of a caller's budget after a request was counted.
+type Result struct {
+	Allowed   bool
+	Remaining int
+	Reset     time.Time
+}
+
+// Caller returns an identifier for a client address that can be stored without storing the address.
+func Caller(ip string) string {
+	sum := sha256.Sum256([]byte(ip))
+	return hex.EncodeToString(sum[:8])
+}
+
+// key returns the counter of caller in the current window of limit, which expires with the window.
+func (l Limit) key(caller string, now time.Time) (string, time.Time) {
+	window := now.Truncate(l.Window)
+	return "ratelimit:" + l.Name + ":" + caller + ":" + strconv.FormatInt(window.Unix(), 10), window.Add(l.Window)
+}
+
+// Allow counts a request of caller against limit.
+func Allow(ctx context.Context, client *redis.Client, limit Limit, caller string) (Result, error) {
+	key, reset := limit.key(caller, time.Now())
+
+	pipe := client.TxPipeline()
+	count := pipe.Incr(ctx, key)
+	pipe.ExpireAt(ctx, key, reset)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return Result{}, err
+	}
+
+	used := int(count.Val())
+	return Result{Allowed: used <= limit.Requests, Remaining: max(limit.Requests-used, 0), Reset: reset}, nil
+}
+
+// Peek returns the state of the budget of caller without counting a request.
+func Peek(ctx context.Context, client *redis.Client, limit Limit, caller string) (Result, error) {
+	key, reset := limit.key(caller, time.Now())
+	used, err := client.Get(ctx, key).Int()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return Result{}, err
+	}
+
+	return Result{Allowed: used < limit.Requests, Remaining: max(limit.Requests-used, 0), Reset: reset}, nil
+}
+
+// Limited wraps handler so that each caller, identified by its address, can make at most
+// limit.Requests requests per window. Responses report the budget in X-RateLimit headers, and
+// requests beyond it are answered with 429 Too Many Requests. Requests are let through when Redis
+// cannot be reached, and a limit of zero requests disables limiting.
+func Limited(client *redis.Client, limit Limit, handler api.HandlerFunc) api.HandlerFunc {
+	return func(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+		if limit.Requests <= 0 {
+			return handler(ctx, req)
+		}
+
+		result, err := Allow(ctx, client, limit, Caller(api.ClientIP(req)))
+		if err != nil {
+			api.Logger.ErrorContext(ctx, "failed to check rate limit", slog.String("limit", limit.Name), slog.Any("error", err))
+			return handler(ctx, req)
+		}
+
+		var res *events.APIGatewayProxyResponse
+		if result.Allowed {
+			res = handler(ctx, req)
+		} else {
+			res = api.Error(req, http.StatusTooManyRequests, "rate_limited", "too many requests, try again later")
+			res.Headers["Retry-After"] = strconv.Itoa(int(time.Until(result.Reset).Seconds()) + 1)
+		}
+
+		res.Headers["X-RateLimit-Limit"] = strconv.Itoa(limit.Requests)
+		res.Headers["X-RateLimit-Remaining"] = strconv.Itoa(result.Remaining)
+		res.Headers["X-RateLimit-Reset"] = strconv.FormatInt(result.Reset.Unix(), 10)
+		return res
+	}
+}
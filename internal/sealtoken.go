@@ -0,0 +1,62 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrTokenExpired is returned by OpenToken for tokens past their expiry.
+var ErrTokenExpired = errors.New("token expired")
+
+// tokenAAD keeps sealed tokens from being opened as other ciphertexts under the same key, and the
+// other way around.
+var tokenAAD = []byte("nawa sealed token")
+
+// sealedToken is the payload of a sealed token. The expiry is inside the ciphertext, so it cannot be
+// changed without the key.
+type sealedToken struct {
+	Expires int64             `json:"exp"`
+	Claims  map[string]string `json:"claims,omitempty"`
+}
+
+// SealToken seals claims under key in an opaque token that OpenToken rejects once ttl has passed,
+// for short-lived links such as shares and password resets.
+func SealToken(claims map[string]string, ttl time.Duration, key []byte) (string, error) {
+	if ttl <= 0 {
+		return "", errors.New("token ttl must be positive")
+	}
+
+	plaintext, err := json.Marshal(sealedToken{Expires: time.Now().Add(ttl).Unix(), Claims: claims})
+	if err != nil {
+		return "", err
+	}
+	defer clear(plaintext)
+
+	return EncryptWithAAD(plaintext, key, tokenAAD)
+}
+
+// OpenToken returns the claims of a token sealed by SealToken under key, or ErrTokenExpired when it
+// has expired.
+func OpenToken(token string, key []byte) (map[string]string, error) {
+	plaintext, err := DecryptWithAAD(token, key, tokenAAD)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(plaintext)
+
+	var t sealedToken
+	if err := json.Unmarshal(plaintext, &t); err != nil {
+		return nil, err
+	}
+
+	if !time.Now().Before(time.Unix(t.Expires, 0)) {
+		return nil, ErrTokenExpired
+	}
+
+	if t.Claims == nil {
+		t.Claims = map[string]string{}
+	}
+
+	return t.Claims, nil
+}
@@ -0,0 +1,52 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+const redacted = "[REDACTED]"
+
+// Secret holds key material. It is a byte slice, so it can be passed to Encrypt, Decrypt and the
+// other functions taking keys as is, but it never shows its contents when printed, logged or
+// encoded as JSON, whatever the verb.
+type Secret []byte
+
+// SecretFromEnv returns the key in the environment variable name as loaded by KeyFromEnv.
+func SecretFromEnv(name string) (Secret, error) {
+	key, err := KeyFromEnv(name)
+	return Secret(key), err
+}
+
+// Wipe overwrites the secret with zeros once it is no longer needed. Go may have copied it
+// elsewhere in memory, so this limits its lifetime rather than guaranteeing it is gone.
+func (s Secret) Wipe() {
+	clear(s)
+}
+
+// String implements fmt.Stringer.
+func (s Secret) String() string {
+	return redacted
+}
+
+// GoString implements fmt.GoStringer.
+func (s Secret) GoString() string {
+	return redacted
+}
+
+// Format implements fmt.Formatter, so that verbs such as %x and %d, which bypass String for byte
+// slices, do not print the secret either.
+func (s Secret) Format(f fmt.State, _ rune) {
+	_, _ = io.WriteString(f, redacted)
+}
+
+// LogValue implements slog.LogValuer.
+func (s Secret) LogValue() slog.Value {
+	return slog.StringValue(redacted)
+}
+
+// MarshalJSON implements json.Marshaler.
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + redacted + `"`), nil
+}
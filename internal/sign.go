@@ -0,0 +1,36 @@
+package internal
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+)
+
+// Sign returns the hex encoded HMAC-SHA256 of message under key.
+func Sign(message []byte, key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(message)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is the signature Sign returns for message under key. The
+// signatures are compared in constant time, so that a forged one cannot be guessed byte by byte.
+func Verify(message []byte, signature string, key []byte) bool {
+	decoded, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(message)
+	return hmac.Equal(mac.Sum(nil), decoded)
+}
+
+// SecureCompare reports whether a and b are equal in an amount of time that does not depend on
+// their contents, for checking tokens and secrets presented by clients. Both are hashed first, so
+// that the time does not reveal the length of the secret either.
+func SecureCompare(a, b string) bool {
+	hashA, hashB := sha256.Sum256([]byte(a)), sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(hashA[:], hashB[:]) == 1
+}
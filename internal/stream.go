@@ -0,0 +1,230 @@
+package internal
+
+import (
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Streams are sealed in chunks with the STREAM construction, so that payloads of any size can be
+// encrypted and decrypted without holding them in memory. A stream starts with a header made of
+// the stream version, the cipher suite and a random salt, from which the key of the stream is derived.
+// Each chunk of up to streamChunkSize bytes is then sealed with a nonce made of its index and a
+// flag marking the last chunk, which detects reordered, dropped and truncated chunks.
+const (
+	streamVersion  = 1
+	streamSaltSize = 16
+	streamInfo     = "nawa stream"
+	// streamChunkSize is the size of the plaintext of every chunk but the last.
+	streamChunkSize = 64 * 1024
+)
+
+var errStreamCorrupted = errors.New("encrypted stream is truncated or corrupted")
+
+// streamAEAD derives the key of a stream from key and salt.
+func streamAEAD(suite Suite, key, salt []byte) (cipher.AEAD, error) {
+	streamKey, err := hkdf.Key(sha256.New, key, salt, streamInfo, len(key))
+	if err != nil {
+		return nil, err
+	}
+
+	return suite.aead(streamKey)
+}
+
+// chunkNonce returns the nonce of the chunk with the given index.
+func chunkNonce(nonce []byte, index uint64, last bool) []byte {
+	clear(nonce)
+	binary.BigEndian.PutUint64(nonce[len(nonce)-9:], index)
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+
+	return nonce
+}
+
+type encryptWriter struct {
+	w     io.Writer
+	aead  cipher.AEAD
+	nonce []byte
+	buf   []byte
+	chunk []byte
+	index uint64
+	err   error
+}
+
+// NewEncryptWriter returns a writer encrypting what is written to it under key into w, with the
+// suite selected by opts. The stream is only complete once Close is called, which does not close w.
+func NewEncryptWriter(w io.Writer, key []byte, opts ...Option) (io.WriteCloser, error) {
+	suite := newOptions(opts).suite
+	header := make([]byte, 2+streamSaltSize)
+	header[0], header[1] = streamVersion, byte(suite)
+	if _, err := rand.Read(header[2:]); err != nil {
+		return nil, err
+	}
+
+	aead, err := streamAEAD(suite, key, header[2:])
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &encryptWriter{
+		w:     w,
+		aead:  aead,
+		nonce: make([]byte, aead.NonceSize()),
+		buf:   make([]byte, 0, streamChunkSize),
+		chunk: make([]byte, 0, streamChunkSize+aead.Overhead()),
+	}, nil
+}
+
+// Write buffers p, sealing chunks as they fill up. A full chunk is only sealed once more data
+// follows it, since it may still turn out to be the last one.
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+
+	written := 0
+	for len(p) > 0 {
+		if len(e.buf) == streamChunkSize {
+			if e.err = e.flush(false); e.err != nil {
+				return written, e.err
+			}
+		}
+
+		n := copy(e.buf[len(e.buf):streamChunkSize], p)
+		e.buf = e.buf[:len(e.buf)+n]
+		p = p[n:]
+		written += n
+	}
+
+	return written, nil
+}
+
+// Close seals the last chunk.
+func (e *encryptWriter) Close() error {
+	if e.err != nil {
+		return e.err
+	}
+
+	if e.err = e.flush(true); e.err != nil {
+		return e.err
+	}
+
+	e.err = errors.New("write to closed encrypted stream")
+	return nil
+}
+
+func (e *encryptWriter) flush(last bool) error {
+	e.chunk = e.aead.Seal(e.chunk[:0], chunkNonce(e.nonce, e.index, last), e.buf, nil)
+	e.buf = e.buf[:0]
+	e.index++
+
+	_, err := e.w.Write(e.chunk)
+	return err
+}
+
+type decryptReader struct {
+	r         io.Reader
+	aead      cipher.AEAD
+	nonce     []byte
+	chunk     []byte
+	carry     byte
+	carried   bool
+	plaintext []byte
+	index     uint64
+	done      bool
+	err       error
+}
+
+// NewDecryptReader returns a reader decrypting a stream written by NewEncryptWriter from r. Read
+// fails rather than return data from a chunk that does not authenticate, but the data of the
+// previous chunks has already been returned by then, so callers must not act on it before reaching
+// io.EOF.
+func NewDecryptReader(r io.Reader, key []byte) (io.Reader, error) {
+	header := make([]byte, 2+streamSaltSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, errStreamCorrupted
+	}
+
+	if header[0] != streamVersion {
+		return nil, fmt.Errorf("unsupported encrypted stream version %d", header[0])
+	}
+
+	aead, err := streamAEAD(Suite(header[1]), key, header[2:])
+	if err != nil {
+		return nil, err
+	}
+
+	return &decryptReader{
+		r:     r,
+		aead:  aead,
+		nonce: make([]byte, aead.NonceSize()),
+		// One byte more than a full chunk tells whether another chunk follows it.
+		chunk: make([]byte, streamChunkSize+aead.Overhead()+1),
+	}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.plaintext) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+
+		if d.done {
+			return 0, io.EOF
+		}
+
+		d.err = d.next()
+	}
+
+	n := copy(p, d.plaintext)
+	d.plaintext = d.plaintext[n:]
+	return n, nil
+}
+
+// next reads and opens the next chunk.
+func (d *decryptReader) next() error {
+	start := 0
+	if d.carried {
+		d.chunk[0], start = d.carry, 1
+	}
+
+	n, err := io.ReadFull(d.r, d.chunk[start:])
+	n += start
+
+	sealed := d.chunk[:n]
+	switch {
+	case err == nil:
+		// The chunk is full and followed by at least one byte of the next one, which is moved aside
+		// before the chunk is decrypted in place.
+		sealed = d.chunk[:n-1]
+		d.carry, d.carried = d.chunk[n-1], true
+	case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+		d.done, d.carried = true, false
+	default:
+		return err
+	}
+
+	if len(sealed) < d.aead.Overhead() {
+		return errStreamCorrupted
+	}
+
+	plaintext, err := d.aead.Open(sealed[:0], chunkNonce(d.nonce, d.index, d.done), sealed, nil)
+	if err != nil {
+		return errStreamCorrupted
+	}
+	d.index++
+
+	d.plaintext = plaintext
+
+	return nil
+}
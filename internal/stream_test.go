@@ -0,0 +1,148 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"slices"
+	"testing"
+)
+
+func sealStream(t *testing.T, plaintext, key []byte, opts ...Option) []byte {
+	t.Helper()
+
+	var sealed bytes.Buffer
+	w, err := NewEncryptWriter(&sealed, key, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return sealed.Bytes()
+}
+
+func openStream(sealed, key []byte) ([]byte, error) {
+	r, err := NewDecryptReader(bytes.NewReader(sealed), key)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(r)
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+	key, err := GenerateKey(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sizes := []int{0, 1, streamChunkSize - 1, streamChunkSize, streamChunkSize + 1, 3*streamChunkSize + 5}
+	for _, suite := range []Suite{AESGCM, ChaCha20Poly1305, XChaCha20Poly1305, AESGCMSIV} {
+		for _, size := range sizes {
+			t.Run(fmt.Sprintf("suite %d/%d bytes", suite, size), func(t *testing.T) {
+				plaintext := bytes.Repeat([]byte("nawa"), size/4+1)[:size]
+
+				opened, err := openStream(sealStream(t, plaintext, key, WithSuite(suite)), key)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(opened, plaintext) {
+					t.Errorf("opened %d bytes, want the %d bytes sealed", len(opened), size)
+				}
+			})
+		}
+	}
+}
+
+func TestStreamSmallWrites(t *testing.T) {
+	key, err := GenerateKey(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext := bytes.Repeat([]byte("0123456789"), streamChunkSize/5)
+	var sealed bytes.Buffer
+	w, err := NewEncryptWriter(&sealed, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for chunk := range slices.Chunk(plaintext, 7) {
+		if _, err := w.Write(chunk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(sealed.Bytes()[:2], []byte{streamVersion, byte(AESGCM)}) {
+		t.Errorf("header = %x", sealed.Bytes()[:2])
+	}
+	if opened, err := openStream(sealed.Bytes(), key); err != nil || !bytes.Equal(opened, plaintext) {
+		t.Errorf("openStream = %d bytes, %v", len(opened), err)
+	}
+	if _, err := w.Write([]byte("more")); err == nil {
+		t.Error("Write after Close succeeded")
+	}
+}
+
+func TestStreamRejectsTampering(t *testing.T) {
+	key, err := GenerateKey(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := GenerateKey(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Three chunks, the first two full, so that whole chunks can be dropped and swapped.
+	plaintext := bytes.Repeat([]byte{'x'}, 2*streamChunkSize+100)
+	sealed := sealStream(t, plaintext, key)
+	header := 2 + streamSaltSize
+	chunk := streamChunkSize + 16
+	first, second := sealed[header:header+chunk], sealed[header+chunk:header+2*chunk]
+
+	flip := func(i int) []byte {
+		tampered := bytes.Clone(sealed)
+		tampered[i] ^= 1
+		return tampered
+	}
+	concat := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+
+	tests := []struct {
+		name   string
+		sealed []byte
+		key    []byte
+	}{
+		{name: "wrong key", sealed: sealed, key: otherKey},
+		{name: "empty", sealed: nil, key: key},
+		{name: "header only", sealed: sealed[:header], key: key},
+		{name: "truncated header", sealed: sealed[:header-1], key: key},
+		{name: "version", sealed: flip(0), key: key},
+		{name: "suite", sealed: flip(1), key: key},
+		{name: "salt", sealed: flip(2), key: key},
+		{name: "first chunk", sealed: flip(header + 10), key: key},
+		{name: "last chunk", sealed: flip(len(sealed) - 1), key: key},
+		{name: "truncated last chunk", sealed: sealed[:len(sealed)-1], key: key},
+		{name: "dropped last chunk", sealed: sealed[:header+2*chunk], key: key},
+		{name: "dropped first chunk", sealed: concat(sealed[:header], sealed[header+chunk:]), key: key},
+		{name: "reordered chunks", sealed: concat(sealed[:header], second, first, sealed[header+2*chunk:]), key: key},
+		{name: "appended chunk", sealed: concat(sealed, first), key: key},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if opened, err := openStream(tt.sealed, tt.key); err == nil {
+				t.Errorf("openStream succeeded with %d bytes", len(opened))
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+// Package token mints and verifies compact JWTs for the functions: client tokens, short lived
+// links and anything else that needs a bearer credential anyone can hold but only the backend can
+// issue. Tokens are signed with HS256 under the keys of an internal.Keyring, or with EdDSA when
+// they have to be verified by parties that must not be able to mint them.
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"maps"
+	"nawa-functions/internal"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// leeway tolerates the clock skew between the functions minting and verifying a token.
+const leeway = 30 * time.Second
+
+// registeredClaims are the claims Mint sets itself, which custom claims cannot override.
+var registeredClaims = []string{"sub", "aud", "iat", "exp", "jti"}
+
+// Claims are the verified claims of a token.
+type Claims struct {
+	Subject   string
+	Audience  []string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+	ID        string
+	// Custom holds the claims that are not registered claims.
+	Custom map[string]any
+}
+
+// Issuer mints and verifies tokens with one signing method. The ID of the signing key is set in
+// the kid header, so that the key a token was signed with can be rotated.
+type Issuer struct {
+	method     jwt.SigningMethod
+	keyID      string
+	signingKey any
+	keys       func(id string) (any, bool)
+}
+
+// NewHS256 returns an issuer signing with the current key of ring and verifying with any of its
+// keys.
+func NewHS256(ring *internal.Keyring) *Issuer {
+	key, _ := ring.Key(ring.Current())
+
+	return &Issuer{
+		method:     jwt.SigningMethodHS256,
+		keyID:      ring.Current(),
+		signingKey: key,
+		keys: func(id string) (any, bool) {
+			key, ok := ring.Key(id)
+			return key, ok
+		},
+	}
+}
+
+// NewEdDSA returns an issuer signing with key under the ID keyID, and verifying the tokens it signs.
+func NewEdDSA(keyID string, key ed25519.PrivateKey) *Issuer {
+	verifier := NewEdDSAVerifier(map[string]ed25519.PublicKey{keyID: key.Public().(ed25519.PublicKey)})
+	verifier.keyID, verifier.signingKey = keyID, key
+	return verifier
+}
+
+// NewEdDSAVerifier returns an issuer that only verifies EdDSA tokens, signed with the private keys
+// of keys by ID.
+func NewEdDSAVerifier(keys map[string]ed25519.PublicKey) *Issuer {
+	return &Issuer{
+		method: jwt.SigningMethodEdDSA,
+		keys: func(id string) (any, bool) {
+			key, ok := keys[id]
+			return key, ok
+		},
+	}
+}
+
+// Mint returns a token for subject, valid for ttl and only for audience. custom claims are added
+// to the registered ones.
+func (i *Issuer) Mint(subject, audience string, ttl time.Duration, custom map[string]any) (string, error) {
+	if i.signingKey == nil {
+		return "", errors.New("issuer cannot sign tokens")
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{}
+	maps.Copy(claims, custom)
+	claims["sub"] = subject
+	claims["aud"] = audience
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(ttl).Unix()
+	claims["jti"] = base64.RawURLEncoding.EncodeToString(id)
+
+	token := jwt.NewWithClaims(i.method, claims)
+	token.Header["kid"] = i.keyID
+	return token.SignedString(i.signingKey)
+}
+
+// Verify checks the signature, expiry and audience of token and returns its claims. Tokens without
+// an expiry are rejected, and the audience is only left unchecked when audience is empty.
+func (i *Issuer) Verify(token, audience string) (Claims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, i.keyFunc,
+		jwt.WithValidMethods([]string{i.method.Alg()}),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithAudience(audience),
+		jwt.WithLeeway(leeway))
+	if err != nil {
+		return Claims{}, err
+	}
+
+	verified := Claims{Custom: maps.Clone(map[string]any(claims))}
+	verified.Subject, _ = claims.GetSubject()
+	verified.Audience, _ = claims.GetAudience()
+	if issued, _ := claims.GetIssuedAt(); issued != nil {
+		verified.IssuedAt = issued.Time
+	}
+	if expires, _ := claims.GetExpirationTime(); expires != nil {
+		verified.ExpiresAt = expires.Time
+	}
+	verified.ID, _ = claims["jti"].(string)
+
+	for _, name := range registeredClaims {
+		delete(verified.Custom, name)
+	}
+
+	return verified, nil
+}
+
+func (i *Issuer) keyFunc(token *jwt.Token) (any, error) {
+	id, _ := token.Header["kid"].(string)
+	key, ok := i.keys(id)
+	if !ok {
+		return nil, fmt.Errorf("token was signed with unknown key %q", id)
+	}
+
+	return key, nil
+}
@@ -0,0 +1,199 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"nawa-functions/internal"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newHS256(t *testing.T, current string, keys map[string][]byte) *Issuer {
+	t.Helper()
+
+	ring, err := internal.NewKeyring(current, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewHS256(ring)
+}
+
+func newEdDSA(t *testing.T) (*Issuer, ed25519.PublicKey) {
+	t.Helper()
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewEdDSA("signing", private), public
+}
+
+func TestMintVerify(t *testing.T) {
+	eddsa, _ := newEdDSA(t)
+	issuers := []struct {
+		name   string
+		issuer *Issuer
+	}{
+		{name: "HS256", issuer: newHS256(t, "a", map[string][]byte{"a": []byte("secret a")})},
+		{name: "EdDSA", issuer: eddsa},
+	}
+
+	for _, tt := range issuers {
+		t.Run(tt.name, func(t *testing.T) {
+			signed, err := tt.issuer.Mint("user", "nawa-user", time.Hour, map[string]any{"scope": "read", "sub": "admin"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			claims, err := tt.issuer.Verify(signed, "nawa-user")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.Subject != "user" {
+				t.Errorf("Subject = %q, want custom claims not to override it", claims.Subject)
+			}
+			if len(claims.Audience) != 1 || claims.Audience[0] != "nawa-user" {
+				t.Errorf("Audience = %q", claims.Audience)
+			}
+			if claims.ID == "" {
+				t.Error("ID is empty")
+			}
+			if got := claims.ExpiresAt.Sub(claims.IssuedAt); got != time.Hour {
+				t.Errorf("ExpiresAt - IssuedAt = %v, want %v", got, time.Hour)
+			}
+			if len(claims.Custom) != 1 || claims.Custom["scope"] != "read" {
+				t.Errorf("Custom = %v, want only scope", claims.Custom)
+			}
+		})
+	}
+}
+
+func TestVerifyRotatedKey(t *testing.T) {
+	old := newHS256(t, "a", map[string][]byte{"a": []byte("secret a")})
+	rotated := newHS256(t, "b", map[string][]byte{"a": []byte("secret a"), "b": []byte("secret b")})
+
+	signed, err := old.Mint("user", "nawa-client", time.Hour, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rotated.Verify(signed, "nawa-client"); err != nil {
+		t.Errorf("Verify(token of the previous key) = %v", err)
+	}
+
+	signed, err = rotated.Mint("user", "nawa-client", time.Hour, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := old.Verify(signed, "nawa-client"); err == nil {
+		t.Error("Verify(token of an unknown key) succeeded")
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	issuer := newHS256(t, "a", map[string][]byte{"a": []byte("secret a")})
+	other := newHS256(t, "a", map[string][]byte{"a": []byte("secret b")})
+
+	mint := func(issuer *Issuer, audience string, ttl time.Duration) string {
+		signed, err := issuer.Mint("user", audience, ttl, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	tests := []struct {
+		name, token string
+	}{
+		{name: "expired", token: mint(issuer, "nawa-client", -2*leeway)},
+		{name: "audience mismatch", token: mint(issuer, "nawa-user", time.Hour)},
+		{name: "other secret", token: mint(other, "nawa-client", time.Hour)},
+		{name: "malformed", token: "not.a.token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := issuer.Verify(tt.token, "nawa-client"); err == nil {
+				t.Error("Verify succeeded")
+			}
+		})
+	}
+}
+
+func TestVerifyWithinLeeway(t *testing.T) {
+	issuer := newHS256(t, "a", map[string][]byte{"a": []byte("secret a")})
+
+	signed, err := issuer.Mint("user", "nawa-client", -leeway/2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := issuer.Verify(signed, "nawa-client"); err != nil {
+		t.Errorf("Verify(token expired within the leeway) = %v", err)
+	}
+}
+
+// TestVerifyAlgorithmConfusion checks that tokens are only accepted with the signing method of the
+// issuer, whatever their alg header claims.
+func TestVerifyAlgorithmConfusion(t *testing.T) {
+	eddsa, public := newEdDSA(t)
+	hs256 := newHS256(t, "signing", map[string][]byte{"signing": []byte(public)})
+
+	claims := jwt.MapClaims{
+		"sub": "user",
+		"aud": "nawa-client",
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	sign := func(method jwt.SigningMethod, key any) string {
+		token := jwt.NewWithClaims(method, claims)
+		token.Header["kid"] = "signing"
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	tests := []struct {
+		name   string
+		issuer *Issuer
+		token  string
+	}{
+		{
+			// The public key of an EdDSA issuer is public, so HMACs keyed with it prove nothing.
+			name:   "HS256 keyed with the EdDSA public key",
+			issuer: eddsa,
+			token:  sign(jwt.SigningMethodHS256, []byte(public)),
+		},
+		{
+			name:   "EdDSA for an HS256 issuer",
+			issuer: hs256,
+			token:  sign(jwt.SigningMethodEdDSA, eddsa.signingKey),
+		},
+		{
+			name:   "none",
+			issuer: hs256,
+			token:  sign(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.issuer.Verify(tt.token, "nawa-client"); err == nil {
+				t.Error("Verify succeeded")
+			}
+		})
+	}
+}
+
+func TestVerifierCannotMint(t *testing.T) {
+	_, public := newEdDSA(t)
+	verifier := NewEdDSAVerifier(map[string]ed25519.PublicKey{"signing": public})
+
+	if _, err := verifier.Mint("user", "nawa-client", time.Hour, nil); err == nil {
+		t.Error("Mint succeeded without a signing key")
+	}
+}
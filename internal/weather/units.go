@@ -0,0 +1,88 @@
+package weather
+
+import (
+	"fmt"
+	"math"
+)
+
+// UnitSystem is the system of units weather values are returned in. Upstream values are metric and
+// converted on the server, so that every client shows the same rounded values.
+type UnitSystem string
+
+const (
+	Metric   UnitSystem = "metric"
+	Imperial UnitSystem = "imperial"
+)
+
+// ParseUnitSystem reads the units parameter of a weather request, which defaults to Metric.
+func ParseUnitSystem(value string) (UnitSystem, error) {
+	switch UnitSystem(value) {
+	case "", Metric:
+		return Metric, nil
+	case Imperial:
+		return Imperial, nil
+	}
+
+	return "", fmt.Errorf("units must be metric or imperial, got %q", value)
+}
+
+// KeySuffix returns the suffix of the cache keys of values in u. Metric values keep the keys they
+// had before units could be chosen.
+func (u UnitSystem) KeySuffix() string {
+	if u == Metric {
+		return ""
+	}
+
+	return ":" + string(u)
+}
+
+func round(value float64, decimals int) float64 {
+	scale := math.Pow(10, float64(decimals))
+	return math.Round(value*scale) / scale
+}
+
+// Temperature converts a temperature in °C to u, rounded to a tenth of a degree.
+func (u UnitSystem) Temperature(celsius float64) float64 {
+	if u == Imperial {
+		return round(celsius*9/5+32, 1)
+	}
+
+	return celsius
+}
+
+// WindSpeed converts a speed in km/h to u, rounded to a tenth.
+func (u UnitSystem) WindSpeed(kmh float64) float64 {
+	if u == Imperial {
+		return round(kmh/1.609344, 1)
+	}
+
+	return kmh
+}
+
+// Precipitation converts an amount in mm to u, rounded to a hundredth of an inch.
+func (u UnitSystem) Precipitation(mm float64) float64 {
+	if u == Imperial {
+		return round(mm/25.4, 2)
+	}
+
+	return mm
+}
+
+// Units returns the units of values converted to u from values in units.
+func (u UnitSystem) Units(units Units) Units {
+	if u == Imperial {
+		units.Temperature, units.WindSpeed, units.Precipitation = "°F", "mph", "inch"
+	}
+
+	return units
+}
+
+// Convert converts the conditions of c from metric to u.
+func (c *Current) Convert(u UnitSystem) {
+	c.Units = u.Units(c.Units)
+	c.Current.Temperature = u.Temperature(c.Current.Temperature)
+	c.Current.ApparentTemperature = u.Temperature(c.Current.ApparentTemperature)
+	c.Current.Precipitation = u.Precipitation(c.Current.Precipitation)
+	c.Current.WindSpeed = u.WindSpeed(c.Current.WindSpeed)
+	c.Current.WindGusts = u.WindSpeed(c.Current.WindGusts)
+}
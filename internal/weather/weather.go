@@ -0,0 +1,169 @@
+// Package weather fetches and normalizes the current conditions served by the weather function, so
+// that other functions can embed them in their responses and share their cache entries.
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/cache"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ForecastURL is the Open-Meteo forecast API, which serves current conditions and forecasts.
+var ForecastURL = api.EnvOr("forecast_api_url", "https://api.open-meteo.com/v1/forecast")
+
+const (
+	// CoordinatePrecision rounds locations to roughly 1km, well below the resolution of the weather
+	// models, so that nearby clients share cache entries.
+	CoordinatePrecision = 2
+	Attribution         = "Weather data by Open-Meteo.com"
+)
+
+// CurrentCacheTTL is how long current conditions are cached, 10 minutes unless set with
+// cache_ttl_weather_current.
+var CurrentCacheTTL = cache.TTLFor("weather_current", 10*time.Minute)
+
+// CurrentKeys is the keyspace of the current conditions, which the functions embedding them share.
+var CurrentKeys = cache.Keyspace{Namespace: "wx", Kind: "current", Version: 2}
+
+// CurrentKey returns the cache key of the current conditions at a location rounded to
+// CoordinatePrecision.
+func CurrentKey(lat, lon string) string {
+	return CurrentKeys.Key(lat + "," + lon)
+}
+
+// currentVariables are the Open-Meteo current variables, in the order they are requested.
+var currentVariables = []string{
+	"temperature_2m", "apparent_temperature", "relative_humidity_2m", "precipitation", "weather_code",
+	"cloud_cover", "pressure_msl", "wind_speed_10m", "wind_direction_10m", "wind_gusts_10m", "is_day",
+}
+
+// Units are the units of the values of a weather response, as reported by the upstream.
+type Units struct {
+	Temperature   string `json:"temperature"`
+	Precipitation string `json:"precipitation"`
+	WindSpeed     string `json:"windSpeed"`
+	Pressure      string `json:"pressure,omitempty"`
+}
+
+// Conditions are the normalized current conditions at a location.
+type Conditions struct {
+	Time                string  `json:"time"`
+	Temperature         float64 `json:"temperature"`
+	ApparentTemperature float64 `json:"apparentTemperature"`
+	Humidity            float64 `json:"humidity"`
+	Precipitation       float64 `json:"precipitation"`
+	WeatherCode         int     `json:"weatherCode"`
+	Condition           string  `json:"condition"`
+	CloudCover          float64 `json:"cloudCover"`
+	Pressure            float64 `json:"pressure"`
+	WindSpeed           float64 `json:"windSpeed"`
+	WindDirection       float64 `json:"windDirection"`
+	WindGusts           float64 `json:"windGusts"`
+	IsDay               bool    `json:"isDay"`
+}
+
+// Current is the response body of the current conditions route.
+type Current struct {
+	Provider    string     `json:"provider"`
+	Attribution string     `json:"attribution"`
+	Lat         float64    `json:"lat"`
+	Lon         float64    `json:"lon"`
+	Timezone    string     `json:"timezone"`
+	Units       Units      `json:"units"`
+	Current     Conditions `json:"current"`
+}
+
+// openMeteoCurrent is the subset of the Open-Meteo forecast response holding current conditions.
+type openMeteoCurrent struct {
+	Latitude     float64 `json:"latitude"`
+	Longitude    float64 `json:"longitude"`
+	Timezone     string  `json:"timezone"`
+	CurrentUnits struct {
+		Temperature   string `json:"temperature_2m"`
+		Precipitation string `json:"precipitation"`
+		WindSpeed     string `json:"wind_speed_10m"`
+		Pressure      string `json:"pressure_msl"`
+	} `json:"current_units"`
+	Current struct {
+		Time                string  `json:"time"`
+		Temperature         float64 `json:"temperature_2m"`
+		ApparentTemperature float64 `json:"apparent_temperature"`
+		Humidity            float64 `json:"relative_humidity_2m"`
+		Precipitation       float64 `json:"precipitation"`
+		WeatherCode         int     `json:"weather_code"`
+		CloudCover          float64 `json:"cloud_cover"`
+		Pressure            float64 `json:"pressure_msl"`
+		WindSpeed           float64 `json:"wind_speed_10m"`
+		WindDirection       float64 `json:"wind_direction_10m"`
+		WindGusts           float64 `json:"wind_gusts_10m"`
+		IsDay               int     `json:"is_day"`
+	} `json:"current"`
+}
+
+// FetchCurrent requests the current conditions at lat/lon from Open-Meteo and normalizes them.
+func FetchCurrent(ctx context.Context, lat, lon string) (*Current, error) {
+	params := url.Values{
+		"latitude":  {lat},
+		"longitude": {lon},
+		"current":   {strings.Join(currentVariables, ",")},
+		"timezone":  {"auto"},
+	}
+	body, err := api.Get(ctx, ForecastURL+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	var upstream openMeteoCurrent
+	if err := json.Unmarshal([]byte(body), &upstream); err != nil {
+		return nil, err
+	}
+
+	c := upstream.Current
+	return &Current{
+		Provider:    "open-meteo",
+		Attribution: Attribution,
+		Lat:         upstream.Latitude,
+		Lon:         upstream.Longitude,
+		Timezone:    upstream.Timezone,
+		Units: Units{
+			Temperature:   upstream.CurrentUnits.Temperature,
+			Precipitation: upstream.CurrentUnits.Precipitation,
+			WindSpeed:     upstream.CurrentUnits.WindSpeed,
+			Pressure:      upstream.CurrentUnits.Pressure,
+		},
+		Current: Conditions{
+			Time:                c.Time,
+			Temperature:         c.Temperature,
+			ApparentTemperature: c.ApparentTemperature,
+			Humidity:            c.Humidity,
+			Precipitation:       c.Precipitation,
+			WeatherCode:         c.WeatherCode,
+			Condition:           Condition(c.WeatherCode),
+			CloudCover:          c.CloudCover,
+			Pressure:            c.Pressure,
+			WindSpeed:           c.WindSpeed,
+			WindDirection:       c.WindDirection,
+			WindGusts:           c.WindGusts,
+			IsDay:               c.IsDay == 1,
+		},
+	}, nil
+}
+
+// CachedCurrent returns the metric current conditions at a rounded location as JSON from the cache
+// entry of the weather function, fetching and caching them when it is missing, and refreshing them
+// in the background once older than CurrentCacheTTL.
+func CachedCurrent(ctx context.Context, c cache.Cache, lat, lon string) (string, error) {
+	return cache.Revalidate(ctx, c, CurrentKey(lat, lon), CurrentCacheTTL, func(ctx context.Context) (string, error) {
+		result, err := FetchCurrent(ctx, lat, lon)
+		if err != nil {
+			return "", err
+		}
+
+		body, err := json.Marshal(result)
+		return string(body), err
+	})
+}
@@ -0,0 +1,45 @@
+package webhooks
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrLocalAddress is returned when a webhook URL names or resolves to a local or private address.
+var ErrLocalAddress = errors.New("webhook address is local or private")
+
+// Public reports whether addr may be called by deliveries: anything but the loopback, private,
+// link-local and unspecified addresses of the deployment.
+func Public(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return !addr.IsLoopback() && !addr.IsPrivate() && !addr.IsLinkLocalUnicast() && !addr.IsUnspecified()
+}
+
+// Client delivers webhooks. Registered URLs are only checked when they name an address directly, so
+// the addresses their hosts resolve to are checked again when dialing, and redirects are not followed
+// since they could lead anywhere.
+var Client = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: func(_, address string, _ syscall.RawConn) error {
+				addrPort, err := netip.ParseAddrPort(address)
+				if err != nil || !Public(addrPort.Addr()) {
+					return ErrLocalAddress
+				}
+
+				return nil
+			},
+		}).DialContext,
+		MaxIdleConns:    20,
+		IdleConnTimeout: time.Minute,
+	},
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
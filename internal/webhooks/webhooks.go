@@ -0,0 +1,46 @@
+// Package webhooks stores the alert webhooks registered with the webhooks function, which the
+// webhook-alerts function posts new alerts to. Webhooks are encrypted at rest since their secret
+// allows forging deliveries.
+package webhooks
+
+import (
+	"nawa-functions/internal"
+)
+
+// Key is the Redis hash holding the sealed records, keyed by webhook id.
+const Key = "webhooks"
+
+// SentKey returns the Redis set of the alert ids already delivered to the webhook with the given id.
+func SentKey(id string) string {
+	return "webhooks:sent:" + id
+}
+
+var encryptionKey, encryptionKeyErr = internal.SecretFromEnv("webhooks_key")
+
+// Record is a registered webhook. Deliveries are signed with Secret and include the alerts of the
+// location that are at least as severe as MinSeverity.
+type Record struct {
+	URL         string  `json:"url"`
+	Lat         float64 `json:"lat"`
+	Lon         float64 `json:"lon"`
+	MinSeverity string  `json:"minSeverity"`
+	Secret      string  `json:"secret"`
+}
+
+// Seal encrypts record for storage.
+func Seal(record Record) (string, error) {
+	if encryptionKeyErr != nil {
+		return "", encryptionKeyErr
+	}
+
+	return internal.EncryptJSON(record, encryptionKey)
+}
+
+// Open decrypts a record sealed by Seal.
+func Open(sealed string) (Record, error) {
+	if encryptionKeyErr != nil {
+		return Record{}, encryptionKeyErr
+	}
+
+	return internal.DecryptJSON[Record](sealed, encryptionKey)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"nawa-functions/internal/alerts"
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/cache"
+	"nawa-functions/internal/geo"
+	"nawa-functions/internal/metrics"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	redisClient = redis.NewClient(&redis.Options{
+		Addr:     os.Getenv("db_address"),
+		Username: os.Getenv("db_username"),
+		Password: os.Getenv("db_password"),
+		DB:       0,
+	})
+	// responses caches upstream responses, in memory when no Redis server is configured.
+	responses = cache.New(redisClient)
+	logger    = api.Logger
+)
+
+// alertsCacheTTL is short so that new warnings reach the dashboard within minutes. It can be set
+// with cache_ttl_alerts.
+var alertsCacheTTL = cache.TTLFor("alerts", 2*time.Minute)
+
+// The keyspaces of the alerts cached by zone and by point.
+var (
+	zoneKeys  = cache.Keyspace{Namespace: "alerts", Kind: "zone", Version: 2}
+	pointKeys = cache.Keyspace{Namespace: "alerts", Kind: "point", Version: 2}
+)
+
+// activeAlerts returns the active alerts for a point given by lat/lon, or for an NWS zone.
+func activeAlerts(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	params := url.Values{}
+	var key string
+	if zone := strings.ToUpper(req.QueryStringParameters["zone"]); zone != "" {
+		if !alerts.ZonePattern.MatchString(zone) {
+			return api.Error(req, http.StatusBadRequest, "invalid_zone", "zone must be an NWS zone id such as NYZ072")
+		}
+		params.Set("zone", zone)
+		key = zoneKeys.Key(zone)
+	} else {
+		latitude, longitude, err := geo.ParseCoordinates(req.QueryStringParameters["lat"], req.QueryStringParameters["lon"])
+		if err != nil {
+			return api.Error(req, http.StatusBadRequest, "invalid_coordinates", err.Error())
+		}
+
+		point := alerts.Point(latitude, longitude)
+		params.Set("point", point)
+		key = pointKeys.Key(point)
+	}
+
+	if cached := cache.Lookup(ctx, responses, key); cached != "" {
+		logger.InfoContext(ctx, "retrieved result from cache", slog.String("key", key))
+		return api.Respond(req, http.StatusOK, cached)
+	}
+
+	result, err := alerts.Fetch(ctx, params)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to fetch alerts", slog.Any("error", err))
+		return api.UpstreamError(req)
+	}
+
+	normalized, err := json.Marshal(result)
+	if err != nil {
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to encode alerts")
+	}
+
+	cache.Store(ctx, responses, key, string(normalized), alertsCacheTTL)
+	return api.Respond(req, http.StatusOK, string(normalized))
+}
+
+var routes = api.NewMux(strings.Split(api.EnvOr("alerts_route_prefixes", "/.netlify/functions/alerts"), ",")...).
+	Handle(http.MethodGet, "/", api.Authorized(activeAlerts))
+
+func main() {
+	lambda.Start(metrics.Instrument(redisClient, "alerts", api.Handler(routes)))
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/metrics"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	redisClient = redis.NewClient(&redis.Options{
+		Addr:     os.Getenv("db_address"),
+		Username: os.Getenv("db_username"),
+		Password: os.Getenv("db_password"),
+		DB:       0,
+	})
+	logger = api.Logger
+)
+
+const (
+	// streamKey is the Redis stream events are appended to for later aggregation.
+	streamKey = "events"
+	// maxStreamLength caps the stream, trimming the oldest events, so that it cannot outgrow Redis
+	// when nothing aggregates it.
+	maxStreamLength = 100_000
+	maxValueLength  = 64
+)
+
+// eventTypes is the allowlist of event types and the properties each of them may carry. Events are
+// anonymous: nothing identifying the client, such as its address or user agent, is stored, and
+// properties outside the list are rejected rather than stored.
+var eventTypes = map[string][]string{
+	"search_performed": {"kind", "results"},
+	"location_saved":   {"source"},
+	"location_removed": {},
+}
+
+type event struct {
+	Type       string            `json:"type"`
+	Properties map[string]string `json:"properties"`
+}
+
+func (e event) validate() error {
+	allowed, ok := eventTypes[e.Type]
+	if !ok {
+		return fmt.Errorf("unknown event type %q", e.Type)
+	}
+
+	for name, value := range e.Properties {
+		if !slices.Contains(allowed, name) {
+			return fmt.Errorf("event type %q does not allow property %q", e.Type, name)
+		}
+
+		if len(value) > maxValueLength {
+			return fmt.Errorf("property %q must be at most %d bytes long", name, maxValueLength)
+		}
+	}
+
+	return nil
+}
+
+// record appends the event in the body to the events stream. Only the event type, its properties and
+// the time it was received are stored.
+func record(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	body, err := api.RequestBody(req)
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_body", "invalid request body")
+	}
+
+	var e event
+	if err := json.Unmarshal(body, &e); err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_body", "request body must be a JSON object")
+	}
+
+	if err := e.validate(); err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_event", err.Error())
+	}
+
+	values := map[string]any{"type": e.Type, "time": time.Now().UTC().Format(time.RFC3339)}
+	for name, value := range e.Properties {
+		values["p:"+name] = value
+	}
+
+	err = redisClient.XAdd(ctx, &redis.XAddArgs{
+		Stream: streamKey,
+		MaxLen: maxStreamLength,
+		Approx: true,
+		Values: values,
+	}).Err()
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to record event", slog.String("type", e.Type), slog.Any("error", err))
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to record event")
+	}
+
+	return api.Respond(req, http.StatusAccepted, "")
+}
+
+var routes = api.NewMux(strings.Split(api.EnvOr("events_route_prefixes", "/.netlify/functions/events"), ",")...).
+	Handle(http.MethodPost, "/", api.Authorized(record))
+
+func main() {
+	lambda.Start(metrics.Instrument(redisClient, "events", api.Handler(routes)))
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/metrics"
+	"nawa-functions/internal/ratelimit"
+	"net/http"
+	"net/mail"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	redisClient = redis.NewClient(&redis.Options{
+		Addr:     os.Getenv("db_address"),
+		Username: os.Getenv("db_username"),
+		Password: os.Getenv("db_password"),
+		DB:       0,
+	})
+	logger = api.Logger
+	// Submissions are emailed through the Resend API when an API key is configured and stored in
+	// Redis otherwise.
+	emailURL    = api.EnvOr("feedback_email_api_url", "https://api.resend.com/emails")
+	emailAPIKey = os.Getenv("feedback_email_api_key")
+	emailFrom   = os.Getenv("feedback_email_from")
+	emailTo     = os.Getenv("feedback_email_to")
+)
+
+const (
+	feedbackKey      = "feedback"
+	maxStoredEntries = 1000
+	maxMessageLength = 5000
+	maxFieldLength   = 200
+)
+
+var submitLimit = ratelimit.Limit{Name: "feedback", Requests: 5, Window: time.Hour}
+
+type submission struct {
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	Message string `json:"message"`
+	// Website is a honeypot. The form hides it from people, so only bots fill it in.
+	Website string    `json:"website"`
+	Time    time.Time `json:"time"`
+}
+
+func (s *submission) validate() error {
+	s.Name, s.Email, s.Message = strings.TrimSpace(s.Name), strings.TrimSpace(s.Email), strings.TrimSpace(s.Message)
+	if s.Message == "" {
+		return errors.New("message is required")
+	}
+
+	if utf8.RuneCountInString(s.Message) > maxMessageLength {
+		return fmt.Errorf("message must be at most %d characters long", maxMessageLength)
+	}
+
+	if utf8.RuneCountInString(s.Name) > maxFieldLength || len(s.Email) > maxFieldLength {
+		return fmt.Errorf("name and email must be at most %d characters long", maxFieldLength)
+	}
+
+	if s.Email != "" {
+		if _, err := mail.ParseAddress(s.Email); err != nil {
+			return errors.New("email must be a valid email address")
+		}
+	}
+
+	return nil
+}
+
+// sendEmail forwards the submission to emailTo through the email provider.
+func sendEmail(ctx context.Context, s submission) error {
+	payload := map[string]any{
+		"from":    emailFrom,
+		"to":      []string{emailTo},
+		"subject": "nawa feedback",
+		"text":    fmt.Sprintf("Name: %s\nEmail: %s\n\n%s", s.Name, s.Email, s.Message),
+	}
+	if s.Email != "" {
+		payload["reply_to"] = s.Email
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, emailURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+emailAPIKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := api.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	if res.StatusCode >= http.StatusMultipleChoices {
+		return &api.StatusError{StatusCode: res.StatusCode}
+	}
+
+	return nil
+}
+
+// store keeps the submission in Redis, dropping the oldest ones beyond maxStoredEntries.
+func store(ctx context.Context, s submission) error {
+	encoded, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	pipe := redisClient.TxPipeline()
+	pipe.LPush(ctx, feedbackKey, encoded)
+	pipe.LTrim(ctx, feedbackKey, 0, maxStoredEntries-1)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// submit accepts a feedback form submission, which clients can seal to the key returned by
+// api.SealingKey so that it stays private end to end. Submissions with the honeypot filled in are
+// acknowledged but dropped. Callers are limited to submitLimit by the route.
+func submit(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	body, err := api.RequestBody(req)
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_body", "invalid request body")
+	}
+
+	var s submission
+	if err := json.Unmarshal(body, &s); err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_body", "request body must be a JSON object")
+	}
+
+	if s.Website != "" {
+		logger.WarnContext(ctx, "dropped feedback with honeypot field")
+		return api.Respond(req, http.StatusAccepted, "")
+	}
+
+	if err := s.validate(); err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_feedback", err.Error())
+	}
+	s.Time = time.Now().UTC()
+
+	if emailAPIKey != "" {
+		err = sendEmail(ctx, s)
+	} else {
+		err = store(ctx, s)
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to deliver feedback", slog.Any("error", err))
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to submit feedback")
+	}
+
+	return api.Respond(req, http.StatusAccepted, "")
+}
+
+var routes = api.NewMux(strings.Split(api.EnvOr("feedback_route_prefixes", "/.netlify/functions/feedback"), ",")...).
+	Handle(http.MethodGet, "/key", api.SealingKey).
+	Handle(http.MethodPost, "/", ratelimit.Limited(redisClient, submitLimit, api.Authorized(submit)))
+
+func main() {
+	lambda.Start(metrics.Instrument(redisClient, "feedback", api.Handler(routes)))
+}
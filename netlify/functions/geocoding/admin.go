@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/cache"
+	"nawa-functions/internal/metrics"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultScanCount = 100
+	maxScanCount     = 1000
+	// maxPeekLength truncates large values, such as cached static maps, in cache entry responses.
+	maxPeekLength = 64 << 10
+)
+
+// globEscaper escapes the characters that SCAN MATCH patterns treat specially.
+var globEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+type cacheKeys struct {
+	Keys   []string `json:"keys"`
+	Cursor uint64   `json:"cursor"`
+}
+
+// cacheEntry describes a cache entry. TTL is in seconds, -1 for entries that do not expire. Value is
+// only set for string entries and Truncated reports whether it was cut at maxPeekLength bytes. Size
+// is the stored size, so that of the gzipped value for Compressed entries, whose Value is decompressed.
+type cacheEntry struct {
+	Key        string  `json:"key"`
+	Type       string  `json:"type"`
+	TTL        float64 `json:"ttl"`
+	Size       int64   `json:"size,omitempty"`
+	Value      *string `json:"value,omitempty"`
+	Truncated  bool    `json:"truncated,omitempty"`
+	Compressed bool    `json:"compressed,omitempty"`
+}
+
+// listCacheKeys returns a page of the keys starting with the prefix parameter, leaving out the keys
+// outside cache.Namespaces. Pass the returned cursor back to get the next page, until it is 0. Pages
+// can be empty, as with SCAN.
+func listCacheKeys(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	params := req.QueryStringParameters
+	count, err := intParam(params, "count", defaultScanCount, 1, maxScanCount)
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_options", err.Error())
+	}
+
+	var cursor uint64
+	if value, ok := params["cursor"]; ok {
+		if cursor, err = strconv.ParseUint(value, 10, 64); err != nil {
+			return api.Error(req, http.StatusBadRequest, "invalid_options", fmt.Sprintf("cursor must be a cursor returned by a previous page, got %q", value))
+		}
+	}
+
+	keys, next, err := redisClient.Scan(ctx, cursor, globEscaper.Replace(params["prefix"])+"*", int64(count)).Result()
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to scan cache keys", slog.Any("error", err))
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to list cache keys")
+	}
+
+	keys = slices.DeleteFunc(keys, func(key string) bool { return !cache.InNamespace(key) })
+	if keys == nil {
+		keys = []string{}
+	}
+	body, _ := json.Marshal(cacheKeys{Keys: keys, Cursor: next})
+	return api.Respond(req, http.StatusOK, string(body))
+}
+
+// cacheKey returns the key parameter of req, or the response to send back when it is not the key of
+// a cache entry. The other keys of Redis hold data that the cache routes must neither read nor delete.
+func cacheKey(req *events.APIGatewayProxyRequest) (string, *events.APIGatewayProxyResponse) {
+	key := req.QueryStringParameters["key"]
+	if key == "" {
+		return "", api.Error(req, http.StatusBadRequest, "invalid_key", "key is required")
+	}
+
+	if !cache.InNamespace(key) {
+		return "", api.Error(req, http.StatusBadRequest, "invalid_key",
+			fmt.Sprintf("key must be in one of the cache namespaces %s", strings.Join(cache.Namespaces, ", ")))
+	}
+
+	return key, nil
+}
+
+// peekCacheEntry returns the type, TTL and, for strings, the value of the key parameter.
+func peekCacheEntry(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	key, res := cacheKey(req)
+	if res != nil {
+		return res
+	}
+
+	pipe := redisClient.Pipeline()
+	keyType := pipe.Type(ctx, key)
+	ttl := pipe.TTL(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		logger.ErrorContext(ctx, "failed to inspect cache entry", slog.String("key", key), slog.Any("error", err))
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to inspect cache entry")
+	}
+
+	if keyType.Val() == "none" {
+		return api.Error(req, http.StatusNotFound, "not_found", "cache entry not found")
+	}
+
+	entry := cacheEntry{Key: key, Type: keyType.Val(), TTL: -1}
+	if ttl.Val() > 0 {
+		entry.TTL = ttl.Val().Seconds()
+	}
+
+	if entry.Type == "string" {
+		value, err := redisClient.GetRange(ctx, key, 0, maxPeekLength-1).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			logger.ErrorContext(ctx, "failed to read cache entry", slog.String("key", key), slog.Any("error", err))
+			return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to read cache entry")
+		}
+
+		entry.Size, _ = redisClient.StrLen(ctx, key).Result()
+		entry.Truncated = entry.Size > int64(len(value))
+		if cache.IsCompressed(value) {
+			if value, err = peekCompressed(ctx, key); err != nil {
+				logger.ErrorContext(ctx, "failed to decompress cache entry", slog.String("key", key), slog.Any("error", err))
+				return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to decompress cache entry")
+			}
+			entry.Compressed = true
+			entry.Truncated = len(value) > maxPeekLength
+			value = value[:min(len(value), maxPeekLength)]
+		}
+		entry.Value = &value
+	}
+
+	body, _ := json.Marshal(entry)
+	return api.Respond(req, http.StatusOK, string(body))
+}
+
+// peekCompressed reads and decompresses the whole gzipped value of key, since a prefix of it cannot
+// be decompressed on its own.
+func peekCompressed(ctx context.Context, key string) (string, error) {
+	value, err := redisClient.Get(ctx, key).Result()
+	if err != nil {
+		return "", err
+	}
+
+	return cache.Decompress(value)
+}
+
+// deleteCacheEntry deletes the key parameter, so that the next request fetches it from upstream.
+func deleteCacheEntry(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	key, res := cacheKey(req)
+	if res != nil {
+		return res
+	}
+
+	deleted, err := redisClient.Del(ctx, key).Result()
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to delete cache entry", slog.String("key", key), slog.Any("error", err))
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to delete cache entry")
+	}
+
+	if deleted == 0 {
+		return api.Error(req, http.StatusNotFound, "not_found", "cache entry not found")
+	}
+
+	logger.InfoContext(ctx, "deleted cache entry", slog.String("key", key))
+	return api.Respond(req, http.StatusNoContent, "")
+}
+
+// exportMetrics returns the metrics accumulated by every function in the Prometheus text format.
+func exportMetrics(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	body, err := metrics.Export(ctx, redisClient)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to export metrics", slog.Any("error", err))
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to export metrics")
+	}
+
+	return api.Binary(req, "text/plain; version=0.0.4; charset=utf-8", []byte(body))
+}
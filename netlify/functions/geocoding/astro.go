@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/cache"
+	"nawa-functions/internal/geo"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const (
+	// j2000 is the Julian date of 2000-01-01 12:00 UTC, the epoch of the solar position formulas.
+	j2000 = 2451545.0
+	// unixEpochJulian is the Julian date of 1970-01-01 00:00 UTC.
+	unixEpochJulian = 2440587.5
+	degrees         = math.Pi / 180
+	// sunriseAltitude is the altitude of the center of the Sun at sunrise and sunset, lower than the
+	// horizon because of atmospheric refraction and the radius of the solar disc.
+	sunriseAltitude = -0.833
+	// civilTwilightAltitude is the altitude of the Sun at civil dawn and dusk.
+	civilTwilightAltitude = -6.0
+	// goldenHourAltitude and blueHourAltitude bound the photographers' golden hour, with the Sun
+	// between -4 and 6 degrees, and blue hour, with the Sun between -6 and -4 degrees.
+	goldenHourAltitude = 6.0
+	blueHourAltitude   = -4.0
+	// obliquity is the axial tilt of the Earth.
+	obliquity = 23.4397 * degrees
+	// astroCacheTTL keeps the events of a day for as long as clients are likely to ask for it. They
+	// never change, but each location and date needs its own entry.
+	astroCacheTTL = 72 * time.Hour
+)
+
+// solarDay holds the solar transit and declination of the Sun at a location on a given day, from
+// which the time of any solar altitude can be derived.
+type solarDay struct {
+	transit     float64 // Julian date of solar noon
+	declination float64 // radians
+	latitude    float64 // radians
+}
+
+// newSolarDay applies the sunrise equation to the day starting at date, which only needs to be
+// accurate to the calendar day, for a location at lat/lon in degrees.
+func newSolarDay(date time.Time, lat, lon float64) solarDay {
+	noon := time.Date(date.Year(), date.Month(), date.Day(), 12, 0, 0, 0, time.UTC)
+	n := math.Round(float64(noon.Unix())/86400+unixEpochJulian-j2000) + 0.0008
+
+	meanSolarTime := n - lon/360
+	anomaly := math.Mod(357.5291+0.98560028*meanSolarTime, 360) * degrees
+	center := 1.9148*math.Sin(anomaly) + 0.02*math.Sin(2*anomaly) + 0.0003*math.Sin(3*anomaly)
+	longitude := math.Mod(anomaly/degrees+center+180+102.9372, 360) * degrees
+
+	return solarDay{
+		transit:     j2000 + meanSolarTime + 0.0053*math.Sin(anomaly) - 0.0069*math.Sin(2*longitude),
+		declination: math.Asin(math.Sin(longitude) * math.Sin(obliquity)),
+		latitude:    lat * degrees,
+	}
+}
+
+// hourAngle returns the hour angle in degrees at which the Sun crosses altitude, and false when it
+// stays above or below that altitude all day.
+func (d solarDay) hourAngle(altitude float64) (float64, bool) {
+	cos := (math.Sin(altitude*degrees) - math.Sin(d.latitude)*math.Sin(d.declination)) /
+		(math.Cos(d.latitude) * math.Cos(d.declination))
+	if cos < -1 || cos > 1 {
+		return 0, false
+	}
+
+	return math.Acos(cos) / degrees, true
+}
+
+// crossings returns the times at which the Sun rises above and sets below altitude.
+func (d solarDay) crossings(altitude float64) (rise, set time.Time, ok bool) {
+	angle, ok := d.hourAngle(altitude)
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+
+	return julianTime(d.transit - angle/360), julianTime(d.transit + angle/360), true
+}
+
+// polarDay reports whether the Sun stays above the horizon all day, by comparing its altitude at solar
+// noon with the sunrise altitude.
+func (d solarDay) polarDay() bool {
+	return d.noonAltitude() > sunriseAltitude
+}
+
+// windows returns the periods of the day during which the Sun is between the lower and upper
+// altitudes, in chronological order. There are usually two, in the morning and the evening, but
+// there is a single one when the Sun never climbs above upper, and the periods extend to solar
+// midnight when it never sinks below lower.
+func (d solarDay) windows(lower, upper float64) []period {
+	lowRise, lowSet, lowOK := d.crossings(lower)
+	highRise, highSet, highOK := d.crossings(upper)
+	midnightBefore, midnightAfter := julianTime(d.transit-0.5), julianTime(d.transit+0.5)
+
+	switch {
+	case lowOK && highOK:
+		return []period{{lowRise, highRise}, {highSet, lowSet}}
+	case lowOK:
+		if d.noonAltitude() < lower {
+			return nil
+		}
+		return []period{{lowRise, lowSet}}
+	case highOK:
+		if d.midnightAltitude() < lower {
+			return nil
+		}
+		return []period{{midnightBefore, highRise}, {highSet, midnightAfter}}
+	}
+
+	// The Sun crosses neither altitude, it spends the whole day between them or outside of them.
+	if noon := d.noonAltitude(); noon > lower && noon < upper {
+		return []period{{midnightBefore, midnightAfter}}
+	}
+
+	return nil
+}
+
+// noonAltitude and midnightAltitude return the altitude of the Sun in degrees at solar noon and
+// solar midnight.
+func (d solarDay) noonAltitude() float64 {
+	return 90 - math.Abs(d.latitude-d.declination)/degrees
+}
+
+func (d solarDay) midnightAltitude() float64 {
+	return math.Abs(d.latitude+d.declination)/degrees - 90
+}
+
+func julianTime(julian float64) time.Time {
+	return time.UnixMilli(int64(math.Round((julian - unixEpochJulian) * 86400000))).UTC()
+}
+
+// solarPosition returns the elevation above the horizon and the azimuth, clockwise from north, of
+// the Sun at t as seen from lat/lon, both in degrees and without correction for refraction.
+func solarPosition(t time.Time, lat, lon float64) (elevation, azimuth float64) {
+	days := float64(t.UnixMilli())/86400000 + unixEpochJulian - j2000
+
+	anomaly := math.Mod(357.5291+0.98560028*days, 360) * degrees
+	center := 1.9148*math.Sin(anomaly) + 0.02*math.Sin(2*anomaly) + 0.0003*math.Sin(3*anomaly)
+	longitude := math.Mod(anomaly/degrees+center+180+102.9372, 360) * degrees
+
+	declination := math.Asin(math.Sin(longitude) * math.Sin(obliquity))
+	rightAscension := math.Atan2(math.Sin(longitude)*math.Cos(obliquity), math.Cos(longitude))
+	hourAngle := math.Mod(280.16+360.9856235*days+lon, 360)*degrees - rightAscension
+
+	latitude := lat * degrees
+	elevation = math.Asin(math.Sin(latitude)*math.Sin(declination) + math.Cos(latitude)*math.Cos(declination)*math.Cos(hourAngle))
+	azimuth = math.Atan2(math.Sin(hourAngle), math.Cos(hourAngle)*math.Sin(latitude)-math.Tan(declination)*math.Cos(latitude))
+
+	return elevation / degrees, math.Mod(azimuth/degrees+540, 360)
+}
+
+// period is a span of time during a day.
+type period struct {
+	start, end time.Time
+}
+
+// window is a period formatted in the local time of a location.
+type window struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// position is the place of the Sun in the sky at a given time.
+type position struct {
+	Time      string  `json:"time"`
+	Elevation float64 `json:"elevation"`
+	Azimuth   float64 `json:"azimuth"`
+}
+
+type astroResult struct {
+	Date             string `json:"date"`
+	Timezone         string `json:"timezone"`
+	Sunrise          string `json:"sunrise,omitempty"`
+	Sunset           string `json:"sunset,omitempty"`
+	SolarNoon        string `json:"solarNoon"`
+	CivilDawn        string `json:"civilDawn,omitempty"`
+	CivilDusk        string `json:"civilDusk,omitempty"`
+	DayLengthSeconds int64  `json:"dayLengthSeconds"`
+	// Polar is "day" or "night" when the Sun does not rise or set at all that day.
+	Polar      string   `json:"polar,omitempty"`
+	GoldenHour []window `json:"goldenHour"`
+	BlueHour   []window `json:"blueHour"`
+	// SolarPosition is computed for every request, it is not part of the cached events of the day.
+	SolarPosition *position `json:"solarPosition,omitempty"`
+}
+
+// parseDate reads the date query parameter in location, defaulting to the current local date.
+func parseDate(params map[string]string, location *time.Location) (time.Time, error) {
+	value, ok := params["date"]
+	if !ok {
+		now := time.Now().In(location)
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location), nil
+	}
+
+	date, err := time.ParseInLocation(time.DateOnly, value, location)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("date must be formatted as YYYY-MM-DD, got %q", value)
+	}
+
+	return date, nil
+}
+
+// parseTime reads the time query parameter as an RFC 3339 timestamp, defaulting to now.
+func parseTime(params map[string]string) (time.Time, error) {
+	value, ok := params["time"]
+	if !ok {
+		return time.Now(), nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("time must be an RFC 3339 timestamp, got %q", value)
+	}
+
+	return t, nil
+}
+
+// sunEvents computes the sunrise, sunset, civil twilight and golden and blue hours at lat/lon on
+// date, formatted in the timezone of date.
+func sunEvents(date time.Time, lat, lon float64) astroResult {
+	location := date.Location()
+	day := newSolarDay(date, lat, lon)
+	result := astroResult{
+		Date:      date.Format(time.DateOnly),
+		Timezone:  location.String(),
+		SolarNoon: julianTime(day.transit).In(location).Format(time.RFC3339),
+	}
+
+	if sunrise, sunset, ok := day.crossings(sunriseAltitude); ok {
+		result.Sunrise = sunrise.In(location).Format(time.RFC3339)
+		result.Sunset = sunset.In(location).Format(time.RFC3339)
+		result.DayLengthSeconds = int64(sunset.Sub(sunrise).Seconds())
+	} else if day.polarDay() {
+		result.Polar = "day"
+		result.DayLengthSeconds = 24 * 60 * 60
+	} else {
+		result.Polar = "night"
+	}
+
+	if dawn, dusk, ok := day.crossings(civilTwilightAltitude); ok {
+		result.CivilDawn = dawn.In(location).Format(time.RFC3339)
+		result.CivilDusk = dusk.In(location).Format(time.RFC3339)
+	}
+
+	result.GoldenHour = localWindows(day.windows(blueHourAltitude, goldenHourAltitude), location)
+	result.BlueHour = localWindows(day.windows(civilTwilightAltitude, blueHourAltitude), location)
+
+	return result
+}
+
+// localWindows formats periods in location, returning an empty list rather than nil so that days
+// without any window are encoded as [].
+func localWindows(periods []period, location *time.Location) []window {
+	windows := make([]window, 0, len(periods))
+	for _, p := range periods {
+		windows = append(windows, window{
+			Start: p.start.In(location).Format(time.RFC3339),
+			End:   p.end.In(location).Format(time.RFC3339),
+		})
+	}
+
+	return windows
+}
+
+// dayEvents returns the sunEvents of date at lat/lon, from cache when possible.
+func dayEvents(ctx context.Context, date time.Time, lat, lon float64) astroResult {
+	key := astroKeys.Key(formatCoordinate(lat)+","+formatCoordinate(lon), date.Format(time.DateOnly))
+
+	var result astroResult
+	if cached := cache.Lookup(ctx, responses, key); cached != "" {
+		if err := json.Unmarshal([]byte(cached), &result); err == nil {
+			logger.InfoContext(ctx, "retrieved result from cache", slog.String("key", key))
+			return result
+		}
+	}
+
+	result = sunEvents(date, lat, lon)
+	if encoded, err := json.Marshal(result); err == nil {
+		cache.Store(ctx, responses, key, string(encoded), astroCacheTTL)
+	}
+
+	return result
+}
+
+// astro computes the sunrise, sunset, civil twilight, golden and blue hours and day length at lat/lon
+// for the date query parameter, along with the position of the Sun at the time query parameter.
+// Everything is computed locally, only the timezone used to format local times and to pick the
+// default date is looked up. The events of the day are cached per location and date.
+func astro(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	latitude, longitude, err := geo.ParseCoordinates(req.QueryStringParameters["lat"], req.QueryStringParameters["lon"])
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_coordinates", err.Error())
+	}
+
+	name, err := lookupTimezone(ctx, formatCoordinate(latitude), formatCoordinate(longitude))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to look up timezone", slog.Any("error", err))
+		return api.UpstreamError(req)
+	}
+	location, _ := time.LoadLocation(name)
+
+	date, err := parseDate(req.QueryStringParameters, location)
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_date", err.Error())
+	}
+
+	at, err := parseTime(req.QueryStringParameters)
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_time", err.Error())
+	}
+
+	result := dayEvents(ctx, date, latitude, longitude)
+	elevation, azimuth := solarPosition(at, latitude, longitude)
+	result.SolarPosition = &position{
+		Time:      at.In(location).Format(time.RFC3339),
+		Elevation: math.Round(elevation*100) / 100,
+		Azimuth:   math.Round(azimuth*100) / 100,
+	}
+
+	body, err := json.Marshal(result)
+	if err != nil {
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to encode astronomical data")
+	}
+
+	return api.Respond(req, http.StatusOK, string(body))
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/cache"
+	"nawa-functions/internal/geo"
+	"net/http"
+	"sync"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const (
+	maxBatchSize     = 25
+	batchConcurrency = 5
+)
+
+// coordinatePair is an entry of a /reverse/batch request. The coordinates are pointers so that
+// missing ones are told apart from 0.
+type coordinatePair struct {
+	Lat *float64 `json:"lat"`
+	Lon *float64 `json:"lon"`
+}
+
+// coordinates returns the coordinates of p once validated.
+func (p coordinatePair) coordinates() (lat, lon float64, err error) {
+	if p.Lat == nil || p.Lon == nil {
+		return 0, 0, errors.New("lat and lon are required")
+	}
+
+	return *p.Lat, *p.Lon, geo.ValidateCoordinates(*p.Lat, *p.Lon)
+}
+
+// batchResult is a single entry of the /reverse/batch response. Exactly one of Result and Error is set.
+type batchResult struct {
+	Lat    *float64        `json:"lat"`
+	Lon    *float64        `json:"lon"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *api.ErrorBody  `json:"error,omitempty"`
+}
+
+// reverseBatch reverse geocodes every coordinate pair in the JSON array body and returns the results
+// in the same order. Lookups run concurrently and each pair is cached on its own, the cached pairs being
+// read in one round trip before the lookups start.
+func reverseBatch(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	body, err := api.RequestBody(req)
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_body", "invalid request body")
+	}
+
+	var pairs []coordinatePair
+	if err := json.Unmarshal(body, &pairs); err != nil {
+		logger.WarnContext(ctx, "failed to decode batch request", slog.Any("error", err))
+		return api.Error(req, http.StatusBadRequest, "invalid_body", "request body must be an array of {lat, lon} objects")
+	}
+
+	if len(pairs) == 0 || len(pairs) > maxBatchSize {
+		return api.Error(req, http.StatusBadRequest, "invalid_batch_size", fmt.Sprintf("batch must contain between 1 and %d coordinates", maxBatchSize))
+	}
+
+	opts, err := parseSearchOptions(req.QueryStringParameters)
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_options", err.Error())
+	}
+
+	results := make([]batchResult, len(pairs))
+	keys := make([]string, 0, len(pairs))
+	for _, pair := range pairs {
+		if lat, lon, err := pair.coordinates(); err == nil {
+			keys = append(keys, reverseKey(formatCoordinate(lat), formatCoordinate(lon), opts))
+		}
+	}
+	ctx = cache.Prefetch(ctx, responses, keys)
+
+	sem := make(chan struct{}, batchConcurrency)
+	var wg sync.WaitGroup
+	for i, pair := range pairs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			results[i] = batchResult{Lat: pair.Lat, Lon: pair.Lon}
+			latitude, longitude, err := pair.coordinates()
+			if err != nil {
+				results[i].Error = &api.ErrorBody{Code: "invalid_coordinates", Message: err.Error()}
+				return
+			}
+
+			result, err := reverseGeocode(ctx, formatCoordinate(latitude), formatCoordinate(longitude), opts)
+			if err != nil {
+				results[i].Error = &api.ErrorBody{Code: "upstream_error", Message: "upstream request failed"}
+				return
+			}
+
+			results[i].Result = json.RawMessage(result)
+		}()
+	}
+	wg.Wait()
+
+	encoded, err := json.Marshal(results)
+	if err != nil {
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to encode batch results")
+	}
+
+	logger.InfoContext(ctx, "completed reverse batch", slog.Int("size", len(pairs)))
+	return api.Respond(req, http.StatusOK, string(encoded))
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/geo"
+	"nawa-functions/internal/weather"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+type conditionsResult struct {
+	Place   place           `json:"place"`
+	Weather json.RawMessage `json:"weather"`
+}
+
+// currentWeather returns the current conditions at lat/lon as served by the weather function,
+// sharing its cache entries.
+func currentWeather(ctx context.Context, latitude, longitude float64) (string, error) {
+	lat := geo.FormatCoordinate(latitude, weather.CoordinatePrecision)
+	lon := geo.FormatCoordinate(longitude, weather.CoordinatePrecision)
+	return weather.CachedCurrent(ctx, responses, lat, lon)
+}
+
+// conditions geocodes the q query parameter and returns its top result together with the current
+// weather there, sparing the search flow of the app a second sequential request.
+func conditions(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	query := req.QueryStringParameters["q"]
+	if err := validateQuery(query); err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_query", err.Error())
+	}
+	query = normalizeQuery(query)
+
+	opts, err := parseSearchOptions(req.QueryStringParameters)
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_options", err.Error())
+	}
+	// The top result is needed in full to look up its weather.
+	opts.fields = nil
+
+	geocoded, err := forwardGeocode(ctx, query, opts)
+	if err != nil {
+		return api.UpstreamError(req)
+	}
+
+	var result searchResult
+	if err := json.Unmarshal([]byte(geocoded), &result); err != nil {
+		logger.ErrorContext(ctx, "failed to decode geocoding result", slog.Any("error", err))
+		return api.UpstreamError(req)
+	}
+
+	if len(result.Places) == 0 {
+		return api.Error(req, http.StatusNotFound, "no_results", "no place matches the query")
+	}
+
+	top := result.Places[0]
+	current, err := currentWeather(ctx, top.Lat, top.Lon)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to fetch current conditions", slog.Any("error", err))
+		return api.UpstreamError(req)
+	}
+
+	body, err := json.Marshal(conditionsResult{Place: top, Weather: json.RawMessage(current)})
+	if err != nil {
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to encode conditions")
+	}
+
+	return api.Respond(req, http.StatusOK, string(body))
+}
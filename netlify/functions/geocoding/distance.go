@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/geo"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// earthRadius is the mean radius of the Earth in each supported unit.
+var earthRadius = map[string]float64{
+	"km": 6371.0088,
+	"mi": 3958.7613,
+}
+
+type distanceResult struct {
+	Distance float64 `json:"distance"`
+	Unit     string  `json:"unit"`
+}
+
+// haversine returns the great circle distance between two points on a sphere of the given radius.
+func haversine(lat1, lon1, lat2, lon2, radius float64) float64 {
+	toRadians := func(degrees float64) float64 { return degrees * math.Pi / 180 }
+
+	dLat := toRadians(lat2 - lat1)
+	dLon := toRadians(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * radius * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// distance computes the distance between lat1/lon1 and lat2/lon2 in the requested unit, miles by
+// default. It is computed locally and never calls an upstream.
+func distance(req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	params := req.QueryStringParameters
+
+	lat1, lon1, err := geo.ParseCoordinates(params["lat1"], params["lon1"])
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_coordinates", err.Error())
+	}
+
+	lat2, lon2, err := geo.ParseCoordinates(params["lat2"], params["lon2"])
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_coordinates", err.Error())
+	}
+
+	unit := params["unit"]
+	if unit == "" {
+		unit = "mi"
+	}
+
+	radius, ok := earthRadius[unit]
+	if !ok {
+		return api.Error(req, http.StatusBadRequest, "invalid_unit", fmt.Sprintf("unit must be km or mi, got %q", unit))
+	}
+
+	result := distanceResult{
+		Distance: math.Round(haversine(lat1, lon1, lat2, lon2, radius)*100) / 100,
+		Unit:     unit,
+	}
+
+	body, err := json.Marshal(result)
+	if err != nil {
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to encode distance")
+	}
+
+	return api.Respond(req, http.StatusOK, string(body))
+}
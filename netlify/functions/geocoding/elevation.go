@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/cache"
+	"nawa-functions/internal/geo"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const feetPerMeter = 3.28084
+
+var elevationURL = api.EnvOr("elevation_api_url", "https://api.open-meteo.com/v1/elevation")
+
+type elevationResult struct {
+	Meters float64 `json:"meters"`
+	Feet   float64 `json:"feet"`
+}
+
+// lookupElevation returns the elevation of lat/lon in meters, as cached or from the elevation API.
+func lookupElevation(ctx context.Context, lat, lon string) (float64, error) {
+	key := elevationKeys.Key(lat + "," + lon)
+	if cached := cache.Lookup(ctx, responses, key); cached != "" {
+		if meters, err := strconv.ParseFloat(cached, 64); err == nil {
+			logger.InfoContext(ctx, "retrieved result from cache", slog.String("key", key))
+			return meters, nil
+		}
+	}
+
+	params := url.Values{"latitude": {lat}, "longitude": {lon}}
+	body, err := api.Get(ctx, elevationURL+"?"+params.Encode())
+	if err != nil {
+		return 0, err
+	}
+
+	var result struct {
+		Elevation []float64 `json:"elevation"`
+	}
+	if err := json.Unmarshal([]byte(body), &result); err != nil {
+		return 0, err
+	}
+
+	if len(result.Elevation) == 0 {
+		return 0, errors.New("upstream returned no elevation")
+	}
+
+	meters := result.Elevation[0]
+	cache.Store(ctx, responses, key, strconv.FormatFloat(meters, 'f', -1, 64), defaultCacheTTL)
+	return meters, nil
+}
+
+func elevation(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	latitude, longitude, err := geo.ParseCoordinates(req.QueryStringParameters["lat"], req.QueryStringParameters["lon"])
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_coordinates", err.Error())
+	}
+
+	meters, err := lookupElevation(ctx, formatCoordinate(latitude), formatCoordinate(longitude))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to look up elevation", slog.Any("error", err))
+		return api.UpstreamError(req)
+	}
+
+	body, err := json.Marshal(elevationResult{
+		Meters: meters,
+		Feet:   math.Round(meters*feetPerMeter*10) / 10,
+	})
+	if err != nil {
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to encode elevation")
+	}
+
+	return api.Respond(req, http.StatusOK, string(body))
+}
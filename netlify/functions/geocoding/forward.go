@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"nawa-functions/internal/api"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// forwardRequest is the JSON body accepted by POST /forward, for queries that are awkward to pass in
+// a query string.
+type forwardRequest struct {
+	Query     string          `json:"query"`
+	Types     []string        `json:"types"`
+	Limit     *int            `json:"limit"`
+	Proximity *coordinatePair `json:"proximity"`
+}
+
+// forwardSearchBody runs a forward search described by a JSON body. The body fields are converted to
+// their query string equivalents, so that both forms share validation and cache entries.
+func forwardSearchBody(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	body, err := api.RequestBody(req)
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_body", "invalid request body")
+	}
+
+	var forward forwardRequest
+	if err := json.Unmarshal(body, &forward); err != nil {
+		logger.WarnContext(ctx, "failed to decode forward request", slog.Any("error", err))
+		return api.Error(req, http.StatusBadRequest, "invalid_body", "request body must be a JSON object")
+	}
+
+	params := maps.Clone(req.QueryStringParameters)
+	if params == nil {
+		params = map[string]string{}
+	}
+
+	if len(forward.Types) > 0 {
+		params["types"] = strings.Join(forward.Types, ",")
+	}
+
+	if forward.Limit != nil {
+		params["limit"] = strconv.Itoa(*forward.Limit)
+	}
+
+	if forward.Proximity != nil {
+		lat, lon, err := forward.Proximity.coordinates()
+		if err != nil {
+			return api.Error(req, http.StatusBadRequest, "invalid_options", fmt.Sprintf("proximity must be a lat,lon pair: %v", err))
+		}
+
+		params["proximity"] = strconv.FormatFloat(lat, 'f', -1, 64) + "," + strconv.FormatFloat(lon, 'f', -1, 64)
+	}
+
+	return forwardSearch(ctx, req, forward.Query, params)
+}
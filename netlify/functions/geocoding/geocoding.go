@@ -2,199 +2,288 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"io"
 	"log/slog"
-	"nawa-functions/internal"
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/cache"
+	"nawa-functions/internal/geo"
+	"nawa-functions/internal/metrics"
+	"nawa-functions/internal/prewarm"
 	"net/http"
 	"os"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/redis/go-redis/v9"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
 )
 
 var (
-	httpClient = &http.Client{
-		Timeout: 10 * time.Second,
-		Transport: &http.Transport{
-			MaxIdleConns:        100,              // Max total idle connections
-			MaxIdleConnsPerHost: 20,               // Max idle connections per host
-			IdleConnTimeout:     15 * time.Minute, // How long an idle connection stays open
-		},
-	}
 	redisClient = redis.NewClient(&redis.Options{
 		Addr:     os.Getenv("db_address"),
 		Username: os.Getenv("db_username"),
 		Password: os.Getenv("db_password"),
 		DB:       0,
 	})
-	corsHeaders = map[string]string{
-		"Access-Control-Allow-Origin":  "",
-		"Access-Control-Allow-Headers": "X-Nawa-Token,x-nawa-token",
-		"Access-Control-Allow-Methods": "*",
-	}
-	logger               = slog.New(slog.NewTextHandler(os.Stdout, nil))
-	searchURL            = "https://api.mapbox.com/search/geocode/v6"
-	forwardSearchURL     = searchURL + "/forward?country=us&types=place&access_token=" + os.Getenv("mapbox_access_token")
-	reverseSearchURL     = searchURL + "/reverse?country=us&types=place&access_token=" + os.Getenv("mapbox_access_token")
-	nawaToken            = os.Getenv("nawa_token")
-	nawaKey              = os.Getenv("nawa_key")
-	requireToken, _      = strconv.ParseBool(os.Getenv("require_token"))
-	validatedClientToken = ""
+	// responses caches upstream responses, in memory when no Redis server is configured.
+	responses           = cache.New(redisClient)
+	logger              = api.Logger
+	searchURL           = "https://api.mapbox.com/search/geocode/v6"
+	mapboxAccessToken   = os.Getenv("mapbox_access_token")
+	coordinatePrecision = parsePrecision(os.Getenv("reverse_cache_precision"))
 )
 
 const (
-	localhostOrigin = "http://localhost:3000"
-	githubOrigin    = "https://tshrestha.github.io"
+	maxQueryLength             = 256
+	defaultCoordinatePrecision = 3
 )
 
-func createResponse(req *events.APIGatewayProxyRequest, statusCode int, body string) *events.APIGatewayProxyResponse {
-	origin := req.Headers["origin"]
-	if origin == localhostOrigin || origin == githubOrigin {
-		corsHeaders["Access-Control-Allow-Origin"] = origin
-	}
+// Geocodes rarely change, so they are cached for long. The TTLs can be set with the
+// cache_ttl_<class> environment variables, see cache.TTLFor.
+var (
+	defaultCacheTTL = cache.TTLFor("geocoding", 200*time.Hour)
+	forwardCacheTTL = cache.TTLFor("forward", defaultCacheTTL)
+	reverseCacheTTL = cache.TTLFor("reverse", defaultCacheTTL)
+)
 
-	return &events.APIGatewayProxyResponse{
-		StatusCode: statusCode,
-		Body:       body,
-		Headers:    corsHeaders,
-	}
-}
+// The keyspaces of the entries cached by the geocoding function.
+var (
+	forwardKeys   = cache.Keyspace{Namespace: "geo", Kind: "fwd", Version: 2}
+	reverseKeys   = cache.Keyspace{Namespace: "geo", Kind: "rev", Version: 2}
+	astroKeys     = cache.Keyspace{Namespace: "geo", Kind: "astro", Version: 2}
+	routeKeys     = cache.Keyspace{Namespace: "geo", Kind: "route", Version: 2}
+	timezoneKeys  = cache.Keyspace{Namespace: "geo", Kind: "tz", Version: 2}
+	elevationKeys = cache.Keyspace{Namespace: "geo", Kind: "elev", Version: 2}
+	isochroneKeys = cache.Keyspace{Namespace: "geo", Kind: "iso", Version: 2}
+	nearbyKeys    = cache.Keyspace{Namespace: "geo", Kind: "nearby", Version: 2}
+	staticMapKeys = cache.Keyspace{Namespace: "geo", Kind: "staticmap", Version: 2}
+)
 
-func getCached(ctx context.Context, key string) string {
-	cached, err := redisClient.Get(ctx, key).Result()
-	if err != nil {
-		logger.WarnContext(ctx, "failed to retrieve query result from cache", slog.String("query", key), slog.Any("error", err))
-		logger.InfoContext(ctx, "HTTP request is required to fetch query results", slog.String("query", key))
-		return ""
-	}
+// geocode sends the request built by reqURL to each geocoder in turn and returns the normalized
+// result, filtered by opts, as JSON, and whether it has any places. The next provider is only tried
+// when shouldFallback allows it.
+func geocode(ctx context.Context, opts searchOptions, reqURL func(g geocoder) string) (string, bool, error) {
+	var err error
+	for _, g := range geocoders {
+		get := api.Get
+		if _, ok := g.(mapbox); ok {
+			get = mapboxGet
+		}
 
-	return cached
-}
+		var body string
+		body, err = get(ctx, reqURL(g))
+		if err != nil {
+			if !shouldFallback(err) {
+				return "", false, err
+			}
 
-func setCache(ctx context.Context, key, value string) {
-	err := redisClient.Set(ctx, key, value, 200*time.Hour).Err()
-	if err != nil {
-		logger.ErrorContext(ctx, "failed to JSONSet forwardSearch result", slog.Any("error", err))
+			logger.WarnContext(ctx, "geocoder request failed", slog.String("provider", g.name()), slog.Any("error", err))
+			continue
+		}
+
+		result, err := g.parse([]byte(body))
+		if err != nil {
+			logger.ErrorContext(ctx, "failed to parse geocoder response", slog.String("provider", g.name()), slog.Any("error", err))
+			return "", false, err
+		}
+
+		result.Places = opts.filter(result.Places)
+		normalized, err := opts.encode(result)
+		if err != nil {
+			return "", false, err
+		}
+
+		return string(normalized), len(result.Places) > 0, nil
 	}
+
+	return "", false, err
 }
 
-func search(ctx context.Context, reqURL string) (string, error) {
-	req, _ := http.NewRequest(http.MethodGet, reqURL, nil)
-	req.Header.Set("Origin", "https://tshrestha.github.io")
-	req.Header.Set("Referer", "https://tshrestha.github.io/nawa")
+// cachedGeocode returns the result of geocode for the cache key, from cache when possible, caching it
+// for ttl. Results without places and requests rejected by the geocoder are only cached for
+// cache.NegativeTTL, so that repeated queries that find nothing do not each cost a request.
+// Concurrent misses for the same key share one upstream request.
+func cachedGeocode(ctx context.Context, key string, ttl time.Duration, opts searchOptions, reqURL func(g geocoder) string) (string, error) {
+	if cached := cache.Lookup(ctx, responses, key); cached != "" {
+		if err := cache.NegativeError(cached); err != nil {
+			logger.InfoContext(ctx, "retrieved rejected query from cache", slog.String("key", key))
+			return "", err
+		}
 
-	res, err := httpClient.Do(req)
-	if err != nil {
-		logger.ErrorContext(ctx, "request failed", slog.String("reqURL", reqURL), slog.Any("error", err))
-		return "", err
+		logger.InfoContext(ctx, "retrieved result from cache", slog.String("key", key))
+		return cached, nil
 	}
-	defer res.Body.Close()
 
-	if res.StatusCode == http.StatusOK {
-		body, err := io.ReadAll(res.Body)
+	return cache.Coalesce(key, func() (string, error) {
+		result, found, err := geocode(ctx, opts, reqURL)
 		if err != nil {
-			logger.ErrorContext(ctx, "failed to read response body", slog.String("reqURL", reqURL), slog.Any("error", err))
+			cache.StoreNegative(ctx, responses, key, err)
 			return "", err
 		}
 
-		return string(body), nil
-	}
+		if !found {
+			ttl = cache.NegativeTTL
+		}
+		cache.Store(ctx, responses, key, result, ttl)
 
-	err = fmt.Errorf("received unexpected status code %d", res.StatusCode)
-	logger.ErrorContext(ctx, "received unexpected status code", slog.String("reqURL", reqURL), slog.Int("statusCode", res.StatusCode))
-	return "", err
+		return result, nil
+	})
 }
 
-func forwardSearch(ctx context.Context, req *events.APIGatewayProxyRequest, query string) *events.APIGatewayProxyResponse {
-	cached := getCached(ctx, query)
+// validateQuery rejects forward search queries that are empty, too long or contain control characters.
+func validateQuery(query string) error {
+	if strings.TrimSpace(query) == "" {
+		return errors.New("q is required")
+	}
 
-	if cached == "" {
-		reqURL := forwardSearchURL + "&q=" + query
-		result, err := search(ctx, reqURL)
-		if err != nil {
-			return createResponse(req, http.StatusInternalServerError, err.Error())
-		}
+	if len(query) > maxQueryLength {
+		return fmt.Errorf("q must be at most %d bytes long", maxQueryLength)
+	}
 
-		setCache(ctx, query, result)
-		return createResponse(req, http.StatusOK, result)
+	if !utf8.ValidString(query) || strings.ContainsFunc(query, unicode.IsControl) {
+		return errors.New("q must not contain control characters")
 	}
 
-	logger.InfoContext(ctx, "retrieved result from cache", slog.String("key", query))
-	return createResponse(req, http.StatusOK, cached)
+	return nil
 }
 
-func reverseSearch(ctx context.Context, req *events.APIGatewayProxyRequest, lat, lon string) *events.APIGatewayProxyResponse {
-	key := lat + lon
-	cached := getCached(ctx, key)
+// normalizeQuery folds the spelling variations of a query that Mapbox treats the same way, so that they
+// share a cache entry: surrounding and repeated whitespace, letter case and diacritics.
+func normalizeQuery(query string) string {
+	stripDiacritics := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	if stripped, _, err := transform.String(stripDiacritics, query); err == nil {
+		query = stripped
+	}
 
-	if cached == "" {
-		reqURL := reverseSearchURL + "&latitude=" + lat + "&longitude=" + lon
-		result, err := search(ctx, reqURL)
-		if err != nil {
-			return createResponse(req, http.StatusInternalServerError, err.Error())
-		}
+	return strings.Join(strings.Fields(strings.ToLower(query)), " ")
+}
 
-		setCache(ctx, key, result)
-		return createResponse(req, http.StatusOK, result)
+// forwardSearch geocodes query with the search options in params, which come from the query string
+// or the body of a POST request.
+func forwardSearch(ctx context.Context, req *events.APIGatewayProxyRequest, query string, params map[string]string) *events.APIGatewayProxyResponse {
+	if err := validateQuery(query); err != nil {
+		logger.WarnContext(ctx, "rejected invalid query", slog.String("query", query))
+		return api.Error(req, http.StatusBadRequest, "invalid_query", err.Error())
 	}
+	query = normalizeQuery(query)
 
-	logger.InfoContext(ctx, "retrieved result from cache", slog.String("key", key))
-	return createResponse(req, http.StatusOK, cached)
-}
+	opts, err := parseSearchOptions(params)
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_options", err.Error())
+	}
 
-func handler(ctx context.Context, request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
-	logger.InfoContext(ctx, "received request", slog.String("method", request.HTTPMethod), slog.String("path", request.Path))
+	// Only queries with the default options are prewarmed, the others are too rare to be worth it.
+	if opts.cacheKey() == defaultSearchOptions.cacheKey() {
+		prewarm.Track(ctx, redisClient, prewarm.ForwardKey, query)
+	}
 
-	origin := request.Headers["origin"]
-	if request.HTTPMethod == http.MethodOptions {
-		logger.Info("received OPTIONS request", slog.String("origin", origin))
-		return createResponse(&request, http.StatusOK, ""), nil
+	result, err := forwardGeocode(ctx, query, opts)
+	if err != nil {
+		return api.UpstreamError(req)
 	}
 
-	if request.HTTPMethod == http.MethodGet {
-		if requireToken {
-			logger.Info("client token is required")
+	return api.Respond(req, http.StatusOK, result)
+}
 
-			token := request.Headers["x-nawa-token"]
-			if token == "" {
-				return createResponse(&request, http.StatusUnauthorized, "invalid token"), nil
-			}
-			logger.Info("token header is present", slog.String("token", token))
+// forwardGeocode returns the normalized forward geocoding result for a normalized query, from cache
+// when possible.
+func forwardGeocode(ctx context.Context, query string, opts searchOptions) (string, error) {
+	key := forwardKeys.Key(query, opts.cacheKey())
+	return cachedGeocode(ctx, key, forwardCacheTTL, opts, func(g geocoder) string { return g.forwardURL(query, opts) })
+}
 
-			decrypted, err := internal.Decrypt(token, []byte(nawaKey))
-			if err != nil {
-				logger.Error("failed to decrypt token", slog.Any("error", err))
-				return createResponse(&request, http.StatusInternalServerError, ""), nil
-			}
+// reverseKey is the cache key of the reverse geocoding of lat/lon with opts.
+func reverseKey(lat, lon string, opts searchOptions) string {
+	return reverseKeys.Key(lat+","+lon, opts.cacheKey())
+}
 
-			if string(decrypted) != nawaToken {
-				logger.Error("decrypted client token does not match server token")
-				return createResponse(&request, http.StatusUnauthorized, "invalid token"), nil
-			}
+// reverseGeocode returns the normalized reverse geocoding result for lat/lon, from cache when possible.
+func reverseGeocode(ctx context.Context, lat, lon string, opts searchOptions) (string, error) {
+	return cachedGeocode(ctx, reverseKey(lat, lon, opts), reverseCacheTTL, opts, func(g geocoder) string { return g.reverseURL(lat, lon, opts) })
+}
 
-			logger.Info("client token validated successfully")
-		}
+// parsePrecision parses the number of decimal places reverse lookups are rounded to. Three decimal
+// places are roughly 100m, which is far below the size of the places being looked up.
+func parsePrecision(value string) int {
+	precision, err := strconv.Atoi(value)
+	if err != nil || precision < 0 || precision > 8 {
+		return defaultCoordinatePrecision
+	}
 
-		pathSegments := strings.Split(request.Path, "/")
-		requestType := pathSegments[len(pathSegments)-1]
+	return precision
+}
 
-		if requestType == "forward" {
-			return forwardSearch(ctx, &request, request.QueryStringParameters["q"]), nil
-		} else if requestType == "reverse" {
-			return reverseSearch(ctx, &request, request.QueryStringParameters["lat"], request.QueryStringParameters["lon"]), nil
-		}
+// formatCoordinate snaps a coordinate to coordinatePrecision decimal places so that nearby lookups
+// share the same upstream request and cache entry.
+func formatCoordinate(value float64) string {
+	return geo.FormatCoordinate(value, coordinatePrecision)
+}
+
+func reverseSearch(ctx context.Context, req *events.APIGatewayProxyRequest, lat, lon string) *events.APIGatewayProxyResponse {
+	latitude, longitude, err := geo.ParseCoordinates(lat, lon)
+	if err != nil {
+		logger.WarnContext(ctx, "rejected invalid coordinates", slog.String("lat", lat), slog.String("lon", lon))
+		return api.Error(req, http.StatusBadRequest, "invalid_coordinates", err.Error())
+	}
+
+	opts, err := parseSearchOptions(req.QueryStringParameters)
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_options", err.Error())
+	}
 
-		return createResponse(&request, http.StatusNotFound, ""), nil
+	lat = formatCoordinate(latitude)
+	lon = formatCoordinate(longitude)
+	result, err := reverseGeocode(ctx, lat, lon, opts)
+	if err != nil {
+		return api.UpstreamError(req)
 	}
 
-	return createResponse(&request, http.StatusMethodNotAllowed, ""), nil
+	return api.Respond(req, http.StatusOK, result)
 }
 
+// routes is the route table of the function. Health and version checks are used by monitoring and
+// deploy tooling, which cannot present a client token. Quota checks do not count towards the budget
+// they report, and admin routes are protected by the admin token instead.
+var routes = api.NewMux(strings.Split(api.EnvOr("route_prefixes", "/.netlify/functions/geocoding"), ",")...).
+	Handle(http.MethodGet, "/health", health).
+	Handle(http.MethodGet, "/version", func(_ context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+		return versionInfo(req)
+	}).
+	Handle(http.MethodGet, "/quota", api.Authorized(quota)).
+	Handle(http.MethodGet, "/metrics", api.AdminOnly(exportMetrics)).
+	Handle(http.MethodGet, "/admin/cache", api.AdminOnly(listCacheKeys)).
+	Handle(http.MethodGet, "/admin/cache/entry", api.AdminOnly(peekCacheEntry)).
+	Handle(http.MethodDelete, "/admin/cache/entry", api.AdminOnly(deleteCacheEntry)).
+	Handle(http.MethodGet, "/forward", limited(func(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+		return forwardSearch(ctx, req, req.QueryStringParameters["q"], req.QueryStringParameters)
+	})).
+	Handle(http.MethodPost, "/forward", limited(forwardSearchBody)).
+	Handle(http.MethodGet, "/conditions", limited(conditions)).
+	Handle(http.MethodGet, "/reverse", limited(func(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+		return reverseSearch(ctx, req, req.QueryStringParameters["lat"], req.QueryStringParameters["lon"])
+	})).
+	Handle(http.MethodPost, "/reverse/batch", limited(reverseBatch)).
+	Handle(http.MethodGet, "/locate", limited(locate)).
+	Handle(http.MethodGet, "/distance", limited(func(_ context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+		return distance(req)
+	})).
+	Handle(http.MethodGet, "/timezone", limited(timezone)).
+	Handle(http.MethodGet, "/elevation", limited(elevation)).
+	Handle(http.MethodGet, "/astro", limited(astro)).
+	Handle(http.MethodGet, "/astro/moon", limited(moon)).
+	Handle(http.MethodGet, "/staticmap", limited(staticMap)).
+	Handle(http.MethodGet, "/isochrone", limited(isochrone)).
+	Handle(http.MethodGet, "/route", limited(route)).
+	Handle(http.MethodGet, "/nearby", limited(nearby))
+
 func main() {
-	lambda.Start(handler)
+	lambda.Start(metrics.Instrument(redisClient, "geocoding", api.Handler(routes)))
 }
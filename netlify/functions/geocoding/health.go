@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"nawa-functions/internal/api"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const (
+	healthCheckTimeout = 3 * time.Second
+	// mapboxHealthURL is requested without an access token, so the check proves reachability without
+	// consuming any geocoding quota.
+	mapboxHealthURL = "https://api.mapbox.com/"
+)
+
+type componentStatus struct {
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latencyMs"`
+}
+
+type healthStatus struct {
+	Status     string                     `json:"status"`
+	Components map[string]componentStatus `json:"components"`
+}
+
+// checkComponent runs the check of the named component with a timeout and reports its outcome and
+// latency. Errors are only logged, since they can name internal hosts and addresses.
+func checkComponent(ctx context.Context, name string, check func(ctx context.Context) error) componentStatus {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	status := componentStatus{Status: "ok", LatencyMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		logger.WarnContext(ctx, "health check failed", slog.String("component", name), slog.Any("error", err))
+		status.Status = "down"
+	}
+
+	return status
+}
+
+func pingRedis(ctx context.Context) error {
+	return redisClient.Ping(ctx).Err()
+}
+
+// pingMapbox only checks that Mapbox answers at all, any non 5xx status counts as reachable.
+func pingMapbox(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, mapboxHealthURL, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := api.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	if res.StatusCode >= http.StatusInternalServerError {
+		return &api.StatusError{StatusCode: res.StatusCode}
+	}
+
+	return nil
+}
+
+// health reports the status of the function's dependencies. Redis is always checked, Mapbox only when
+// the upstream query parameter is true. It answers 503 when any component is down.
+func health(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	result := healthStatus{Status: "ok", Components: map[string]componentStatus{}}
+	result.Components["redis"] = checkComponent(ctx, "redis", pingRedis)
+
+	if upstream, _ := strconv.ParseBool(req.QueryStringParameters["upstream"]); upstream {
+		result.Components["mapbox"] = checkComponent(ctx, "mapbox", pingMapbox)
+	}
+
+	statusCode := http.StatusOK
+	for _, component := range result.Components {
+		if component.Status != "ok" {
+			result.Status = "degraded"
+			statusCode = http.StatusServiceUnavailable
+		}
+	}
+
+	body, err := json.Marshal(result)
+	if err != nil {
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to encode health status")
+	}
+
+	return api.Respond(req, statusCode, string(body))
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/cache"
+	"nawa-functions/internal/geo"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const (
+	isochroneURL       = "https://api.mapbox.com/isochrone/v1/mapbox"
+	maxContours        = 4
+	maxContourMinutes  = 60
+	defaultContourTime = "30"
+)
+
+// isochroneProfiles excludes driving-traffic, whose contours change too often to be cached.
+var isochroneProfiles = []string{"driving", "walking", "cycling"}
+
+// parseContours validates a comma separated list of up to four strictly increasing contour times
+// between 1 and 60 minutes, the limits of the Mapbox Isochrone API, and returns it normalized.
+func parseContours(value string) (string, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) > maxContours {
+		return "", fmt.Errorf("contours_minutes accepts at most %d values", maxContours)
+	}
+
+	contours := make([]string, 0, len(parts))
+	previous := 0
+	for _, part := range parts {
+		minutes, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || minutes < 1 || minutes > maxContourMinutes {
+			return "", fmt.Errorf("contours_minutes must be integers between 1 and %d, got %q", maxContourMinutes, part)
+		}
+
+		if minutes <= previous {
+			return "", fmt.Errorf("contours_minutes must be in increasing order, got %q", value)
+		}
+		previous = minutes
+		contours = append(contours, strconv.Itoa(minutes))
+	}
+
+	return strings.Join(contours, ","), nil
+}
+
+// isochrone proxies the Mapbox Isochrone API and returns its GeoJSON contours of the areas reachable
+// from lat/lon within the requested minutes.
+func isochrone(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	params := req.QueryStringParameters
+
+	latitude, longitude, err := geo.ParseCoordinates(params["lat"], params["lon"])
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_coordinates", err.Error())
+	}
+
+	profile := params["profile"]
+	if profile == "" {
+		profile = isochroneProfiles[0]
+	} else if !slices.Contains(isochroneProfiles, profile) {
+		return api.Error(req, http.StatusBadRequest, "invalid_profile", fmt.Sprintf("profile must be one of %v, got %q", isochroneProfiles, profile))
+	}
+
+	minutes := params["contours_minutes"]
+	if minutes == "" {
+		minutes = defaultContourTime
+	}
+
+	contourList, err := parseContours(minutes)
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_contours", err.Error())
+	}
+
+	lat, lon := formatCoordinate(latitude), formatCoordinate(longitude)
+	key := isochroneKeys.Key(profile, lat+","+lon, contourList)
+
+	if cached := cache.Lookup(ctx, responses, key); cached != "" {
+		logger.InfoContext(ctx, "retrieved result from cache", slog.String("key", key))
+		return api.Respond(req, http.StatusOK, cached)
+	}
+
+	query := url.Values{
+		"contours_minutes": {contourList},
+		"polygons":         {"true"},
+		"access_token":     {mapboxAccessToken},
+	}
+	result, err := mapboxGet(ctx, isochroneURL+"/"+profile+"/"+lon+","+lat+"?"+query.Encode())
+	if err != nil {
+		return api.UpstreamError(req)
+	}
+
+	cache.Store(ctx, responses, key, result, defaultCacheTTL)
+	return api.Respond(req, http.StatusOK, result)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/metrics"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+var ipLookupURL = strings.TrimSuffix(api.EnvOr("ip_lookup_url", "https://ipapi.co"), "/")
+
+// coordinates is the subset of the Netlify geo header and the ipapi.co response that locate needs.
+type coordinates struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// netlifyGeo decodes the base64 encoded JSON that Netlify attaches to every request in x-nf-geo.
+func netlifyGeo(req *events.APIGatewayProxyRequest) (*coordinates, bool) {
+	header := req.Headers["x-nf-geo"]
+	if header == "" {
+		return nil, false
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(header)
+	if err != nil {
+		return nil, false
+	}
+
+	var geo coordinates
+	if err := json.Unmarshal(decoded, &geo); err != nil || (geo.Latitude == 0 && geo.Longitude == 0) {
+		return nil, false
+	}
+
+	return &geo, true
+}
+
+// lookupIP resolves the approximate coordinates of ip with the upstream IP geolocation service. It
+// does not use api.Get, which logs the request URL, since the URL holds the IP of the client, and
+// errors leave the URL out for the same reason.
+func lookupIP(ctx context.Context, ip string) (*coordinates, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ipLookupURL+"/"+url.PathEscape(ip)+"/json/", nil)
+	if err != nil {
+		return nil, errors.New("invalid IP lookup URL")
+	}
+	req.Header.Set("User-Agent", "nawa-functions (https://tshrestha.github.io/nawa)")
+
+	start := time.Now()
+	res, err := api.HTTPClient.Do(req)
+	if err != nil {
+		metrics.ObserveUpstream(ctx, req.URL.Host, time.Since(start), true)
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return nil, urlErr.Err
+		}
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	metrics.ObserveUpstream(ctx, req.URL.Host, time.Since(start), res.StatusCode != http.StatusOK)
+	if res.StatusCode != http.StatusOK {
+		return nil, &api.StatusError{StatusCode: res.StatusCode}
+	}
+
+	var result struct {
+		coordinates
+		Error  bool   `json:"error"`
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	if result.Error {
+		return nil, errors.New(result.Reason)
+	}
+
+	return &result.coordinates, nil
+}
+
+// locate resolves the approximate location of the caller from its IP address and returns the
+// reverse geocoding result for it, for clients that cannot use the browser geolocation API.
+func locate(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	geo, ok := netlifyGeo(req)
+	if !ok {
+		ip := api.ClientIP(req)
+		if ip == "" {
+			logger.WarnContext(ctx, "unable to determine client IP")
+			return api.Error(req, http.StatusUnprocessableEntity, "location_unavailable", "unable to determine client location")
+		}
+
+		var err error
+		geo, err = lookupIP(ctx, ip)
+		if err != nil {
+			logger.ErrorContext(ctx, "failed to look up client IP", slog.Any("error", err))
+			return api.UpstreamError(req)
+		}
+	}
+
+	lat := strconv.FormatFloat(geo.Latitude, 'f', -1, 64)
+	lon := strconv.FormatFloat(geo.Longitude, 'f', -1, 64)
+	logger.InfoContext(ctx, "located client", slog.String("lat", lat), slog.String("lon", lon))
+
+	return reverseSearch(ctx, req, lat, lon)
+}
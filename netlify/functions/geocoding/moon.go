@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"math"
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/geo"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// synodicMonth is the mean number of days between two new moons.
+const synodicMonth = 29.530588853
+
+// moonPhases names the eight phases of the Moon, each centered on a multiple of 45 degrees of
+// elongation, starting from the new moon.
+var moonPhases = []string{
+	"new_moon", "waxing_crescent", "first_quarter", "waxing_gibbous",
+	"full_moon", "waning_gibbous", "last_quarter", "waning_crescent",
+}
+
+type moonResult struct {
+	Date         string  `json:"date"`
+	Timezone     string  `json:"timezone"`
+	Phase        string  `json:"phase"`
+	Illumination float64 `json:"illumination"`
+	AgeDays      float64 `json:"ageDays"`
+	Waxing       bool    `json:"waxing"`
+}
+
+// moonPosition returns the elongation of the Moon from the Sun and its illuminated fraction at t,
+// using the low precision formulas of Meeus, Astronomical Algorithms, chapter 48. Both are accurate
+// to well within what a phase name or a percentage can show.
+func moonPosition(t time.Time) (elongation, illuminated float64) {
+	julian := float64(t.UnixMilli())/86400000 + unixEpochJulian
+	centuries := (julian - j2000) / 36525
+
+	d := math.Mod(297.8501921+445267.1114034*centuries, 360) * degrees
+	m := math.Mod(357.5291092+35999.0502909*centuries, 360) * degrees
+	mp := math.Mod(134.9633964+477198.8675055*centuries, 360) * degrees
+
+	phaseAngle := 180 - d/degrees -
+		6.289*math.Sin(mp) +
+		2.100*math.Sin(m) -
+		1.274*math.Sin(2*d-mp) -
+		0.658*math.Sin(2*d) -
+		0.214*math.Sin(2*mp) -
+		0.110*math.Sin(d)
+
+	elongation = math.Mod(math.Mod(d/degrees, 360)+360, 360)
+	return elongation, (1 + math.Cos(phaseAngle*degrees)) / 2
+}
+
+// moonPhase describes the Moon at local noon of date.
+func moonPhase(date time.Time) moonResult {
+	noon := time.Date(date.Year(), date.Month(), date.Day(), 12, 0, 0, 0, date.Location())
+	elongation, illuminated := moonPosition(noon)
+
+	return moonResult{
+		Date:         date.Format(time.DateOnly),
+		Timezone:     date.Location().String(),
+		Phase:        moonPhases[int(math.Round(elongation/45))%len(moonPhases)],
+		Illumination: math.Round(illuminated*1000) / 10,
+		AgeDays:      math.Round(elongation/360*synodicMonth*10) / 10,
+		Waxing:       elongation < 180,
+	}
+}
+
+// moon returns the phase and illumination percentage of the Moon for the date query parameter, as
+// seen at local noon in the timezone of lat/lon.
+func moon(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	latitude, longitude, err := geo.ParseCoordinates(req.QueryStringParameters["lat"], req.QueryStringParameters["lon"])
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_coordinates", err.Error())
+	}
+
+	name, err := lookupTimezone(ctx, formatCoordinate(latitude), formatCoordinate(longitude))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to look up timezone", slog.Any("error", err))
+		return api.UpstreamError(req)
+	}
+	location, _ := time.LoadLocation(name)
+
+	date, err := parseDate(req.QueryStringParameters, location)
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_date", err.Error())
+	}
+
+	body, err := json.Marshal(moonPhase(date))
+	if err != nil {
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to encode moon phase")
+	}
+
+	return api.Respond(req, http.StatusOK, string(body))
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/cache"
+	"nawa-functions/internal/geo"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const (
+	categorySearchURL    = "https://api.mapbox.com/search/searchbox/v1/category"
+	defaultNearbyRadius  = 5000
+	maxNearbyRadius      = 50000
+	defaultNearbyLimit   = 10
+	maxNearbyLimit       = 25
+	metersPerDegreeOfLat = 111320
+)
+
+// categoryPattern matches Mapbox canonical category ids such as park or weather_station.
+var categoryPattern = regexp.MustCompile(`^[a-z0-9_]{1,64}$`)
+
+// boundingBox returns the minLon,minLat,maxLon,maxLat box enclosing the circle of radius meters
+// around lat/lon, so that the category search does not return places far outside of it.
+func boundingBox(lat, lon, radius float64) string {
+	dLat := radius / metersPerDegreeOfLat
+	dLon := radius / (metersPerDegreeOfLat * math.Max(math.Cos(lat*math.Pi/180), 0.01))
+
+	corners := []string{}
+	for _, c := range []float64{
+		math.Max(lon-dLon, -180), math.Max(lat-dLat, -90),
+		math.Min(lon+dLon, 180), math.Min(lat+dLat, 90),
+	} {
+		corners = append(corners, strconv.FormatFloat(c, 'f', 6, 64))
+	}
+
+	return strings.Join(corners, ",")
+}
+
+// nearby searches for points of interest of a category within radius meters of lat/lon and returns
+// them as places ordered by distance.
+func nearby(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	params := req.QueryStringParameters
+
+	latitude, longitude, err := geo.ParseCoordinates(params["lat"], params["lon"])
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_coordinates", err.Error())
+	}
+
+	category := params["category"]
+	if !categoryPattern.MatchString(category) {
+		return api.Error(req, http.StatusBadRequest, "invalid_category", fmt.Sprintf("category must be a Mapbox category id, got %q", category))
+	}
+
+	radius, err := intParam(params, "radius", defaultNearbyRadius, 1, maxNearbyRadius)
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_options", err.Error())
+	}
+
+	limit, err := intParam(params, "limit", defaultNearbyLimit, 1, maxNearbyLimit)
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_options", err.Error())
+	}
+
+	lat, lon := formatCoordinate(latitude), formatCoordinate(longitude)
+	key := nearbyKeys.Key(category, lat+","+lon, strconv.Itoa(radius), strconv.Itoa(limit))
+	if cached := cache.Lookup(ctx, responses, key); cached != "" {
+		logger.InfoContext(ctx, "retrieved result from cache", slog.String("key", key))
+		return api.Respond(req, http.StatusOK, cached)
+	}
+
+	// The snapped coordinates are used as the search center so that the cached result does not
+	// depend on which caller populated it.
+	latitude, _ = strconv.ParseFloat(lat, 64)
+	longitude, _ = strconv.ParseFloat(lon, 64)
+	query := url.Values{
+		"proximity":    {lon + "," + lat},
+		"bbox":         {boundingBox(latitude, longitude, float64(radius))},
+		"limit":        {strconv.Itoa(limit)},
+		"access_token": {mapboxAccessToken},
+	}
+	body, err := mapboxGet(ctx, categorySearchURL+"/"+category+"?"+query.Encode())
+	if err != nil {
+		return api.UpstreamError(req)
+	}
+
+	// The Search Box API returns features in the same shape as the geocoding API.
+	result, err := mapbox{}.parse([]byte(body))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to parse category search response", slog.Any("error", err))
+		return api.UpstreamError(req)
+	}
+
+	places := result.Places[:0]
+	for _, p := range result.Places {
+		p.Distance = math.Round(haversine(latitude, longitude, p.Lat, p.Lon, earthRadius["km"]*1000))
+		if p.Distance <= float64(radius) {
+			places = append(places, p)
+		}
+	}
+	slices.SortFunc(places, func(a, b place) int { return cmp.Compare(a.Distance, b.Distance) })
+	result.Places = places
+
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to encode nearby places")
+	}
+
+	cache.Store(ctx, responses, key, string(encoded), defaultCacheTTL)
+	return api.Respond(req, http.StatusOK, string(encoded))
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"nawa-functions/internal/geo"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultLimit = 5
+	maxLimit     = 10
+)
+
+// placeFields are the JSON field names of a place that the fields parameter can select.
+var placeFields = []string{"id", "name", "fullName", "region", "regionCode", "country", "countryCode", "lat", "lon", "relevance", "distance"}
+
+// placeTypes are the Mapbox v6 feature types a forward search can be restricted to.
+var placeTypes = []string{"country", "region", "postcode", "district", "place", "locality", "neighborhood", "street", "address"}
+
+// searchOptions are the client tunable parameters of forward and reverse searches. Every option
+// changes the upstream response, so all of them are part of the cache key.
+type searchOptions struct {
+	limit        int
+	autocomplete bool
+	fuzzyMatch   bool
+	minRelevance float64
+	// types and proximity only apply to forward searches. proximity is a "lon,lat" pair as expected by
+	// Mapbox, empty when results should not be biased towards a location.
+	types     []string
+	proximity string
+	// fields lists the place fields to return, empty for all of them.
+	fields []string
+}
+
+// defaultSearchOptions are the options of a search without any option parameter.
+var defaultSearchOptions, _ = parseSearchOptions(nil)
+
+// intParam parses the query parameter name as an integer within [lower, upper], returning fallback
+// when it is not present.
+func intParam(params map[string]string, name string, fallback, lower, upper int) (int, error) {
+	value, ok := params[name]
+	if !ok {
+		return fallback, nil
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < lower || parsed > upper {
+		return 0, fmt.Errorf("%s must be an integer between %d and %d, got %q", name, lower, upper, value)
+	}
+
+	return parsed, nil
+}
+
+// parseSearchOptions reads the search options from the query string, applying defaults for
+// parameters that are not present.
+func parseSearchOptions(params map[string]string) (searchOptions, error) {
+	opts := searchOptions{autocomplete: true, fuzzyMatch: true, types: []string{"place"}}
+
+	var err error
+	if opts.limit, err = intParam(params, "limit", defaultLimit, 1, maxLimit); err != nil {
+		return opts, err
+	}
+
+	for name, option := range map[string]*bool{"autocomplete": &opts.autocomplete, "fuzzy_match": &opts.fuzzyMatch} {
+		if value, ok := params[name]; ok {
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return opts, fmt.Errorf("%s must be true or false, got %q", name, value)
+			}
+			*option = enabled
+		}
+	}
+
+	if value, ok := params["min_relevance"]; ok {
+		minRelevance, err := strconv.ParseFloat(value, 64)
+		if err != nil || !(minRelevance >= 0 && minRelevance <= 1) {
+			return opts, fmt.Errorf("min_relevance must be a number between 0 and 1, got %q", value)
+		}
+		opts.minRelevance = minRelevance
+	}
+
+	if value, ok := params["types"]; ok {
+		types := strings.Split(value, ",")
+		for _, t := range types {
+			if !slices.Contains(placeTypes, t) {
+				return opts, fmt.Errorf("types must be a comma separated list of %v, got %q", placeTypes, value)
+			}
+		}
+		slices.Sort(types)
+		opts.types = slices.Compact(types)
+	}
+
+	if value, ok := params["proximity"]; ok {
+		lat, lon, _ := strings.Cut(value, ",")
+		latitude, longitude, err := geo.ParseCoordinates(lat, lon)
+		if err != nil {
+			return opts, fmt.Errorf("proximity must be a lat,lon pair: %w", err)
+		}
+		opts.proximity = formatCoordinate(longitude) + "," + formatCoordinate(latitude)
+	}
+
+	if value, ok := params["fields"]; ok {
+		fields := strings.Split(value, ",")
+		for _, f := range fields {
+			if !slices.Contains(placeFields, f) {
+				return opts, fmt.Errorf("fields must be a comma separated list of %v, got %q", placeFields, value)
+			}
+		}
+		slices.Sort(fields)
+		opts.fields = slices.Compact(fields)
+	}
+
+	return opts, nil
+}
+
+// filter drops the places that the options exclude but the upstream could not be asked to leave out:
+// places below min_relevance, and approximate matches when fuzzy matching is disabled. Mapbox v6
+// always matches approximately, so disabling fuzzy matching keeps only the places whose match
+// confidence is exact or high. Places without a confidence, such as reverse results, are kept.
+func (o searchOptions) filter(places []place) []place {
+	kept := places[:0]
+	for _, p := range places {
+		if p.Relevance < o.minRelevance {
+			continue
+		}
+
+		if !o.fuzzyMatch && p.confidence != "" && p.confidence != "exact" && p.confidence != "high" {
+			continue
+		}
+
+		kept = append(kept, p)
+	}
+
+	return kept
+}
+
+// encode marshals result, pruning its places down to the requested fields.
+func (o searchOptions) encode(result *searchResult) ([]byte, error) {
+	if len(o.fields) == 0 {
+		return json.Marshal(result)
+	}
+
+	places := make([]map[string]json.RawMessage, 0, len(result.Places))
+	for _, p := range result.Places {
+		encoded, err := json.Marshal(p)
+		if err != nil {
+			return nil, err
+		}
+
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(encoded, &fields); err != nil {
+			return nil, err
+		}
+
+		maps.DeleteFunc(fields, func(name string, _ json.RawMessage) bool { return !slices.Contains(o.fields, name) })
+		places = append(places, fields)
+	}
+
+	return json.Marshal(struct {
+		Provider    string                       `json:"provider"`
+		Attribution string                       `json:"attribution,omitempty"`
+		Places      []map[string]json.RawMessage `json:"places"`
+	}{result.Provider, result.Attribution, places})
+}
+
+func (o searchOptions) cacheKey() string {
+	return "limit=" + strconv.Itoa(o.limit) +
+		"&autocomplete=" + strconv.FormatBool(o.autocomplete) +
+		"&fuzzy_match=" + strconv.FormatBool(o.fuzzyMatch) +
+		"&min_relevance=" + strconv.FormatFloat(o.minRelevance, 'f', -1, 64) +
+		"&types=" + strings.Join(o.types, ",") +
+		"&proximity=" + o.proximity +
+		"&fields=" + strings.Join(o.fields, ",")
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"nawa-functions/internal/api"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// place is the provider independent shape of a single geocoding result. Relevance is a score between
+// 0 and 1 of how well the place matches the query, Distance is only set by nearby searches and is in
+// meters from the search center.
+type place struct {
+	ID          string  `json:"id"`
+	Name        string  `json:"name"`
+	FullName    string  `json:"fullName"`
+	Region      string  `json:"region,omitempty"`
+	RegionCode  string  `json:"regionCode,omitempty"`
+	Country     string  `json:"country,omitempty"`
+	CountryCode string  `json:"countryCode,omitempty"`
+	Lat         float64 `json:"lat"`
+	Lon         float64 `json:"lon"`
+	Relevance   float64 `json:"relevance"`
+	Distance    float64 `json:"distance,omitempty"`
+
+	// confidence is the upstream match confidence, only used to filter results before caching.
+	confidence string
+}
+
+// searchResult is the response body returned for forward and reverse searches.
+type searchResult struct {
+	Provider    string  `json:"provider"`
+	Attribution string  `json:"attribution,omitempty"`
+	Places      []place `json:"places"`
+}
+
+// geocoder builds upstream request URLs for a provider and converts its responses to places.
+type geocoder interface {
+	name() string
+	forwardURL(query string, opts searchOptions) string
+	reverseURL(lat, lon string, opts searchOptions) string
+	parse(body []byte) (*searchResult, error)
+}
+
+var (
+	fallbackGeocoderURL = strings.TrimSuffix(os.Getenv("fallback_geocoder_url"), "/")
+	geocoders           = newGeocoders()
+)
+
+// newGeocoders returns the providers in the order they should be tried. Mapbox is always first,
+// the Nominatim compatible fallback is only used when fallback_geocoder_url is configured.
+func newGeocoders() []geocoder {
+	providers := []geocoder{mapbox{}}
+	if fallbackGeocoderURL != "" {
+		providers = append(providers, nominatim{baseURL: fallbackGeocoderURL})
+	}
+
+	return providers
+}
+
+// shouldFallback reports whether a failed upstream call is worth retrying against another provider.
+// Transport errors, rate limiting and server errors are; other client errors would fail there too.
+func shouldFallback(err error) bool {
+	var statusErr *api.StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= http.StatusInternalServerError
+	}
+
+	return true
+}
+
+// mapboxRelevance maps the Mapbox v6 match confidence to a relevance score. Reverse results have
+// no match code and are exact by definition.
+var mapboxRelevance = map[string]float64{
+	"":       1,
+	"exact":  1,
+	"high":   0.8,
+	"medium": 0.5,
+	"low":    0.2,
+}
+
+type mapbox struct{}
+
+func (mapbox) name() string {
+	return "mapbox"
+}
+
+func (mapbox) forwardURL(query string, opts searchOptions) string {
+	params := url.Values{
+		"country":      {"us"},
+		"types":        {strings.Join(opts.types, ",")},
+		"access_token": {mapboxAccessToken},
+		"q":            {query},
+		"limit":        {strconv.Itoa(opts.limit)},
+		"autocomplete": {strconv.FormatBool(opts.autocomplete)},
+	}
+	if opts.proximity != "" {
+		params.Set("proximity", opts.proximity)
+	}
+
+	return searchURL + "/forward?" + params.Encode()
+}
+
+func (mapbox) reverseURL(lat, lon string, opts searchOptions) string {
+	params := url.Values{
+		"country":      {"us"},
+		"types":        {"place"},
+		"access_token": {mapboxAccessToken},
+		"latitude":     {lat},
+		"longitude":    {lon},
+		"limit":        {strconv.Itoa(opts.limit)},
+	}
+
+	return searchURL + "/reverse?" + params.Encode()
+}
+
+func (m mapbox) parse(body []byte) (*searchResult, error) {
+	var collection struct {
+		Attribution string `json:"attribution"`
+		Features    []struct {
+			ID         string `json:"id"`
+			Properties struct {
+				Name        string `json:"name"`
+				FullAddress string `json:"full_address"`
+				Coordinates struct {
+					Latitude  float64 `json:"latitude"`
+					Longitude float64 `json:"longitude"`
+				} `json:"coordinates"`
+				Context struct {
+					Region struct {
+						Name       string `json:"name"`
+						RegionCode string `json:"region_code"`
+					} `json:"region"`
+					Country struct {
+						Name        string `json:"name"`
+						CountryCode string `json:"country_code"`
+					} `json:"country"`
+				} `json:"context"`
+				MatchCode struct {
+					Confidence string `json:"confidence"`
+				} `json:"match_code"`
+			} `json:"properties"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(body, &collection); err != nil {
+		return nil, err
+	}
+
+	result := &searchResult{Provider: m.name(), Attribution: collection.Attribution, Places: []place{}}
+	for _, f := range collection.Features {
+		p := f.Properties
+		result.Places = append(result.Places, place{
+			ID:          f.ID,
+			Name:        p.Name,
+			FullName:    p.FullAddress,
+			Region:      p.Context.Region.Name,
+			RegionCode:  p.Context.Region.RegionCode,
+			Country:     p.Context.Country.Name,
+			CountryCode: p.Context.Country.CountryCode,
+			Lat:         p.Coordinates.Latitude,
+			Lon:         p.Coordinates.Longitude,
+			Relevance:   mapboxRelevance[p.MatchCode.Confidence],
+			confidence:  p.MatchCode.Confidence,
+		})
+	}
+
+	return result, nil
+}
+
+// nominatim talks to the OpenStreetMap Nominatim API, or any self hosted instance of it.
+type nominatim struct {
+	baseURL string
+}
+
+func (nominatim) name() string {
+	return "nominatim"
+}
+
+func (n nominatim) forwardURL(query string, opts searchOptions) string {
+	params := url.Values{
+		"format":         {"geojson"},
+		"addressdetails": {"1"},
+		"countrycodes":   {"us"},
+		"q":              {query},
+		"limit":          {strconv.Itoa(opts.limit)},
+	}
+	// Nominatim can only restrict results to a single coarse feature type.
+	if len(opts.types) == 1 && opts.types[0] == "place" {
+		params.Set("featureType", "city")
+	}
+
+	return n.baseURL + "/search?" + params.Encode()
+}
+
+// reverseURL ignores opts.limit as Nominatim only ever returns a single reverse result.
+func (n nominatim) reverseURL(lat, lon string, opts searchOptions) string {
+	params := url.Values{
+		"format":         {"geojson"},
+		"addressdetails": {"1"},
+		"zoom":           {"10"},
+		"lat":            {lat},
+		"lon":            {lon},
+	}
+
+	return n.baseURL + "/reverse?" + params.Encode()
+}
+
+func (n nominatim) parse(body []byte) (*searchResult, error) {
+	var collection struct {
+		Licence  string `json:"licence"`
+		Features []struct {
+			Geometry struct {
+				Coordinates []float64 `json:"coordinates"`
+			} `json:"geometry"`
+			Properties struct {
+				OSMType     string  `json:"osm_type"`
+				OSMID       int64   `json:"osm_id"`
+				Name        string  `json:"name"`
+				DisplayName string  `json:"display_name"`
+				Importance  float64 `json:"importance"`
+				Address     struct {
+					State       string `json:"state"`
+					StateCode   string `json:"ISO3166-2-lvl4"`
+					Country     string `json:"country"`
+					CountryCode string `json:"country_code"`
+				} `json:"address"`
+			} `json:"properties"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(body, &collection); err != nil {
+		return nil, err
+	}
+
+	result := &searchResult{Provider: n.name(), Attribution: collection.Licence, Places: []place{}}
+	for _, f := range collection.Features {
+		if len(f.Geometry.Coordinates) < 2 {
+			continue
+		}
+
+		p := f.Properties
+		_, regionCode, _ := strings.Cut(p.Address.StateCode, "-")
+		result.Places = append(result.Places, place{
+			ID:          p.OSMType + "/" + strconv.FormatInt(p.OSMID, 10),
+			Name:        p.Name,
+			FullName:    p.DisplayName,
+			Region:      p.Address.State,
+			RegionCode:  regionCode,
+			Country:     p.Address.Country,
+			CountryCode: strings.ToUpper(p.Address.CountryCode),
+			Lat:         f.Geometry.Coordinates[1],
+			Lon:         f.Geometry.Coordinates[0],
+			Relevance:   p.Importance,
+		})
+	}
+
+	return result, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/ratelimit"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+var (
+	// callerLimit is the budget of each client address across the routes of the function.
+	callerLimit = ratelimit.Limit{Name: "geocoding", Requests: envInt("rate_limit_requests", 1000), Window: time.Hour}
+	// mapboxLimit is the daily budget of Mapbox requests shared by every caller. It is only
+	// reported, so that clients can back off before the account runs out.
+	mapboxLimit = ratelimit.Limit{Name: "mapbox", Requests: envInt("mapbox_daily_budget", 3000), Window: 24 * time.Hour}
+)
+
+// mapboxCaller is the ratelimit caller that Mapbox requests are counted for, since the budget is
+// shared.
+const mapboxCaller = "function"
+
+func envInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+
+	return value
+}
+
+// limited applies callerLimit to an authorized route.
+func limited(handler api.HandlerFunc) api.HandlerFunc {
+	return ratelimit.Limited(redisClient, callerLimit, api.Authorized(handler))
+}
+
+// mapboxGet requests reqURL from Mapbox and counts the request towards mapboxLimit.
+func mapboxGet(ctx context.Context, reqURL string) (string, error) {
+	if _, err := ratelimit.Allow(ctx, redisClient, mapboxLimit, mapboxCaller); err != nil {
+		logger.WarnContext(ctx, "failed to count Mapbox request", slog.Any("error", err))
+	}
+
+	return api.Get(ctx, reqURL)
+}
+
+type budget struct {
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Reset     time.Time `json:"reset"`
+}
+
+type quotaResult struct {
+	Caller budget `json:"caller"`
+	Mapbox budget `json:"mapbox"`
+}
+
+// quota reports the remaining budget of the caller and of the Mapbox account for their current
+// windows without counting the request itself.
+func quota(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	caller, err := ratelimit.Peek(ctx, redisClient, callerLimit, ratelimit.Caller(api.ClientIP(req)))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to read caller budget", slog.Any("error", err))
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to read quota")
+	}
+
+	mapbox, err := ratelimit.Peek(ctx, redisClient, mapboxLimit, mapboxCaller)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to read Mapbox budget", slog.Any("error", err))
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to read quota")
+	}
+
+	body, _ := json.Marshal(quotaResult{
+		Caller: budget{Limit: callerLimit.Requests, Remaining: caller.Remaining, Reset: caller.Reset.UTC()},
+		Mapbox: budget{Limit: mapboxLimit.Requests, Remaining: mapbox.Remaining, Reset: mapbox.Reset.UTC()},
+	})
+	return api.Respond(req, http.StatusOK, string(body))
+}
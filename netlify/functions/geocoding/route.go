@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/cache"
+	"nawa-functions/internal/geo"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const (
+	directionsURL = "https://api.mapbox.com/directions/v5/mapbox"
+	// routeCacheTTL is short because travel times, and driving-traffic routes in particular, change
+	// throughout the day.
+	routeCacheTTL = 15 * time.Minute
+)
+
+var routeProfiles = []string{"driving", "driving-traffic", "walking", "cycling"}
+
+// route proxies the Mapbox Directions API for a route from lat1/lon1 to lat2/lon2 and returns its
+// response with the route geometry as GeoJSON.
+func route(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	params := req.QueryStringParameters
+
+	lat1, lon1, err := geo.ParseCoordinates(params["lat1"], params["lon1"])
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_coordinates", err.Error())
+	}
+
+	lat2, lon2, err := geo.ParseCoordinates(params["lat2"], params["lon2"])
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_coordinates", err.Error())
+	}
+
+	profile := params["profile"]
+	if profile == "" {
+		profile = routeProfiles[0]
+	} else if !slices.Contains(routeProfiles, profile) {
+		return api.Error(req, http.StatusBadRequest, "invalid_profile", fmt.Sprintf("profile must be one of %v, got %q", routeProfiles, profile))
+	}
+
+	waypoints := formatCoordinate(lon1) + "," + formatCoordinate(lat1) + ";" + formatCoordinate(lon2) + "," + formatCoordinate(lat2)
+	key := routeKeys.Key(profile, waypoints)
+
+	if cached := cache.Lookup(ctx, responses, key); cached != "" {
+		logger.InfoContext(ctx, "retrieved result from cache", slog.String("key", key))
+		return api.Respond(req, http.StatusOK, cached)
+	}
+
+	query := url.Values{
+		"geometries":   {"geojson"},
+		"overview":     {"simplified"},
+		"access_token": {mapboxAccessToken},
+	}
+	result, err := mapboxGet(ctx, directionsURL+"/"+profile+"/"+waypoints+"?"+query.Encode())
+	if err != nil {
+		return api.UpstreamError(req)
+	}
+
+	cache.Store(ctx, responses, key, result, routeCacheTTL)
+	return api.Respond(req, http.StatusOK, result)
+}
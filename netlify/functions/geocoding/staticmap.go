@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/cache"
+	"nawa-functions/internal/geo"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const (
+	staticMapURL     = "https://api.mapbox.com/styles/v1/mapbox"
+	defaultMapZoom   = 10
+	defaultMapWidth  = 300
+	defaultMapHeight = 200
+	// maxMapSize keeps the thumbnails small enough to be cached in Redis.
+	maxMapSize = 640
+)
+
+var staticMapStyles = []string{"streets-v12", "outdoors-v12", "light-v11", "dark-v11", "satellite-streets-v12"}
+
+type staticMapOptions struct {
+	style  string
+	zoom   int
+	width  int
+	height int
+	retina bool
+}
+
+func parseStaticMapOptions(params map[string]string) (staticMapOptions, error) {
+	opts := staticMapOptions{style: staticMapStyles[0]}
+	if style, ok := params["style"]; ok {
+		if !slices.Contains(staticMapStyles, style) {
+			return opts, fmt.Errorf("style must be one of %v, got %q", staticMapStyles, style)
+		}
+		opts.style = style
+	}
+
+	var err error
+	if opts.zoom, err = intParam(params, "zoom", defaultMapZoom, 0, 22); err != nil {
+		return opts, err
+	}
+
+	if opts.width, err = intParam(params, "width", defaultMapWidth, 1, maxMapSize); err != nil {
+		return opts, err
+	}
+
+	if opts.height, err = intParam(params, "height", defaultMapHeight, 1, maxMapSize); err != nil {
+		return opts, err
+	}
+
+	if value, ok := params["retina"]; ok {
+		if opts.retina, err = strconv.ParseBool(value); err != nil {
+			return opts, fmt.Errorf("retina must be true or false, got %q", value)
+		}
+	}
+
+	return opts, nil
+}
+
+// staticMapImageURL builds the Mapbox Static Images URL for a map centered on lat/lon with a pin on it.
+func staticMapImageURL(lat, lon string, opts staticMapOptions) string {
+	size := strconv.Itoa(opts.width) + "x" + strconv.Itoa(opts.height)
+	if opts.retina {
+		size += "@2x"
+	}
+
+	position := lon + "," + lat
+	return staticMapURL + "/" + opts.style + "/static/pin-s+e74c3c(" + position + ")/" +
+		position + "," + strconv.Itoa(opts.zoom) + "/" + size +
+		"?" + url.Values{"access_token": {mapboxAccessToken}}.Encode()
+}
+
+// staticMap proxies a Mapbox static map thumbnail so the access token never reaches the browser.
+// Images are cached base64 encoded per location, style, zoom and size.
+func staticMap(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	latitude, longitude, err := geo.ParseCoordinates(req.QueryStringParameters["lat"], req.QueryStringParameters["lon"])
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_coordinates", err.Error())
+	}
+
+	opts, err := parseStaticMapOptions(req.QueryStringParameters)
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_options", err.Error())
+	}
+
+	lat, lon := formatCoordinate(latitude), formatCoordinate(longitude)
+	key := staticMapKeys.Key(opts.style, lat+","+lon, strconv.Itoa(opts.zoom), fmt.Sprintf("%dx%d", opts.width, opts.height), strconv.FormatBool(opts.retina))
+
+	var image []byte
+	if cached := cache.Lookup(ctx, responses, key); cached != "" {
+		// DecodeString returns the bytes decoded before an error, which must not be served.
+		if decoded, err := base64.StdEncoding.DecodeString(cached); err == nil {
+			image = decoded
+			logger.InfoContext(ctx, "retrieved result from cache", slog.String("key", key))
+		}
+	}
+
+	if image == nil {
+		body, err := mapboxGet(ctx, staticMapImageURL(lat, lon, opts))
+		if err != nil {
+			return api.UpstreamError(req)
+		}
+
+		image = []byte(body)
+		cache.Store(ctx, responses, key, base64.StdEncoding.EncodeToString(image), defaultCacheTTL)
+	}
+
+	return api.Binary(req, http.DetectContentType(image), image)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/cache"
+	"nawa-functions/internal/geo"
+	"net/http"
+	"net/url"
+	"time"
+	_ "time/tzdata" // the Lambda runtime does not ship a zoneinfo database
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// timezoneURL resolves the timezone of a coordinate. Open-Meteo does this for free as part of its
+// forecast metadata when asked for timezone=auto.
+var timezoneURL = api.EnvOr("timezone_api_url", "https://api.open-meteo.com/v1/forecast")
+
+type timezoneResult struct {
+	Timezone         string `json:"timezone"`
+	Abbreviation     string `json:"abbreviation"`
+	UTCOffsetSeconds int    `json:"utcOffsetSeconds"`
+	LocalTime        string `json:"localTime"`
+}
+
+// lookupTimezone returns the IANA timezone name for lat/lon. Only the name is cached, offsets are
+// derived from it on every request so that they follow daylight saving time changes.
+func lookupTimezone(ctx context.Context, lat, lon string) (string, error) {
+	key := timezoneKeys.Key(lat + "," + lon)
+	if cached := cache.Lookup(ctx, responses, key); cached != "" {
+		logger.InfoContext(ctx, "retrieved result from cache", slog.String("key", key))
+		return cached, nil
+	}
+
+	params := url.Values{
+		"latitude":      {lat},
+		"longitude":     {lon},
+		"timezone":      {"auto"},
+		"forecast_days": {"1"},
+	}
+	body, err := api.Get(ctx, timezoneURL+"?"+params.Encode())
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		Timezone string `json:"timezone"`
+	}
+	if err := json.Unmarshal([]byte(body), &result); err != nil {
+		return "", err
+	}
+
+	if _, err := time.LoadLocation(result.Timezone); err != nil || result.Timezone == "" {
+		return "", errors.New("upstream returned unknown timezone " + result.Timezone)
+	}
+
+	cache.Store(ctx, responses, key, result.Timezone, defaultCacheTTL)
+	return result.Timezone, nil
+}
+
+func timezone(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	latitude, longitude, err := geo.ParseCoordinates(req.QueryStringParameters["lat"], req.QueryStringParameters["lon"])
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_coordinates", err.Error())
+	}
+
+	name, err := lookupTimezone(ctx, formatCoordinate(latitude), formatCoordinate(longitude))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to look up timezone", slog.Any("error", err))
+		return api.UpstreamError(req)
+	}
+
+	location, _ := time.LoadLocation(name)
+	now := time.Now().In(location)
+	abbreviation, offset := now.Zone()
+
+	body, err := json.Marshal(timezoneResult{
+		Timezone:         name,
+		Abbreviation:     abbreviation,
+		UTCOffsetSeconds: offset,
+		LocalTime:        now.Format(time.RFC3339),
+	})
+	if err != nil {
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to encode timezone")
+	}
+
+	return api.Respond(req, http.StatusOK, string(body))
+}
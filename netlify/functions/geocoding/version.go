@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"nawa-functions/internal/api"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Build metadata, set at build time with
+//
+//	go build -ldflags "-X main.version=$VERSION -X main.commit=$(git rev-parse HEAD) -X main.buildTime=$(date -u +%FT%TZ)"
+//
+// commit and buildTime fall back to the VCS information the go tool embeds when they are not set.
+var (
+	version   = "dev"
+	commit    = ""
+	buildTime = ""
+)
+
+type buildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildTime string `json:"buildTime,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+	GoVersion string `json:"goVersion,omitempty"`
+}
+
+func readBuildInfo() buildInfo {
+	info := buildInfo{Version: version, Commit: commit, BuildTime: buildTime}
+
+	embedded, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+
+	info.GoVersion = embedded.GoVersion
+	for _, setting := range embedded.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = setting.Value
+			}
+		case "vcs.time":
+			if info.BuildTime == "" {
+				info.BuildTime = setting.Value
+			}
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+
+	return info
+}
+
+func versionInfo(req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	body, err := json.Marshal(readBuildInfo())
+	if err != nil {
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to encode build info")
+	}
+
+	return api.Respond(req, http.StatusOK, string(body))
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/cache"
+	"nawa-functions/internal/geo"
+	"nawa-functions/internal/metrics"
+	"nawa-functions/internal/weather"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	redisClient = redis.NewClient(&redis.Options{
+		Addr:     os.Getenv("db_address"),
+		Username: os.Getenv("db_username"),
+		Password: os.Getenv("db_password"),
+		DB:       0,
+	})
+	// responses caches upstream responses, in memory when no Redis server is configured.
+	responses    = cache.New(redisClient)
+	logger       = api.Logger
+	pollInterval = parseInterval(os.Getenv("live_poll_interval"))
+)
+
+// defaultPollInterval matches the polling of the dashboard that live updates replace.
+const defaultPollInterval = 60 * time.Second
+
+func parseInterval(value string) time.Duration {
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval < 10*time.Second {
+		return defaultPollInterval
+	}
+
+	return interval
+}
+
+// version identifies a body of current conditions, so that reconnecting clients are only sent
+// conditions that changed.
+func version(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:8])
+}
+
+// ticketTTL bounds how long a ticket in the URL of an EventSource, and so in the access logs,
+// stays usable. Clients request a new ticket when the EventSource is rejected.
+const ticketTTL = 10 * time.Minute
+
+type ticketResult struct {
+	Ticket    string    `json:"ticket"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// ticket exchanges the client token in X-Nawa-Token for a ticket that EventSources, which cannot
+// send headers, pass in the ticket query parameter instead.
+func ticket(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	expiresAt := time.Now().Add(ticketTTL)
+	value, err := api.MintTicket(ticketTTL)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to mint ticket", slog.Any("error", err))
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to mint ticket")
+	}
+
+	body, _ := json.Marshal(ticketResult{Ticket: value, ExpiresAt: expiresAt.UTC()})
+	return api.Respond(req, http.StatusOK, string(body))
+}
+
+// live answers an EventSource with the current conditions at the lat/lon query parameters as a
+// single server-sent event, and asks it to reconnect after live_poll_interval.
+//
+// This is not response streaming: Netlify only runs Go functions with buffered responses, and
+// Lambda response streaming is only available to them through function URLs, which Netlify does
+// not expose. Every response is therefore complete, and the EventSource reconnecting keeps the
+// updates going for as long as the page is open. Reconnects carry the id of the last event, and
+// only get a comment while the conditions are unchanged. Updates share the cache entries of the
+// weather function, so every location costs one upstream request per weather.CurrentCacheTTL.
+//
+// EventSource cannot send headers, so the client authenticates with a ticket from POST /ticket
+// rather than putting its client token in the URL. Errors are sent as error events rather than
+// error statuses, which would stop the EventSource from reconnecting.
+func live(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	if res := api.AuthorizeTicket(ctx, req, req.QueryStringParameters["ticket"]); res != nil {
+		return res
+	}
+
+	latitude, longitude, err := geo.ParseCoordinates(req.QueryStringParameters["lat"], req.QueryStringParameters["lon"])
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_coordinates", err.Error())
+	}
+
+	lat := geo.FormatCoordinate(latitude, weather.CoordinatePrecision)
+	lon := geo.FormatCoordinate(longitude, weather.CoordinatePrecision)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "retry: %d\n\n", pollInterval.Milliseconds())
+
+	body, err := weather.CachedCurrent(ctx, responses, lat, lon)
+	switch {
+	case err != nil:
+		logger.ErrorContext(ctx, "failed to load current conditions", slog.Any("error", err))
+		b.WriteString("event: error\ndata: {\"code\":\"upstream_error\",\"message\":\"upstream request failed\"}\n\n")
+	case req.Headers["last-event-id"] == version(body):
+		b.WriteString(": unchanged\n\n")
+	default:
+		fmt.Fprintf(&b, "id: %s\nevent: conditions\ndata: %s\n\n", version(body), body)
+	}
+
+	return &events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Headers:    api.EventStreamHeaders(req),
+		Body:       b.String(),
+	}
+}
+
+var routes = api.NewMux(strings.Split(api.EnvOr("live_route_prefixes", "/.netlify/functions/live"), ",")...).
+	Handle(http.MethodGet, "/", live).
+	Handle(http.MethodPost, "/ticket", api.Authorized(ticket))
+
+func main() {
+	lambda.Start(metrics.Instrument(redisClient, "live", api.Handler(routes)))
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"nawa-functions/internal"
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/geo"
+	"nawa-functions/internal/metrics"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	redisClient = redis.NewClient(&redis.Options{
+		Addr:     os.Getenv("db_address"),
+		Username: os.Getenv("db_username"),
+		Password: os.Getenv("db_password"),
+		DB:       0,
+	})
+	logger                          = api.Logger
+	encryptionKey, encryptionKeyErr = internal.SecretFromEnv("locations_key")
+	// acceptUnbound keeps reading the records sealed before they were bound to their user, and
+	// binding them. Set locations_accept_unbound to false once every record is bound, then remove
+	// the flag along with the legacy decryption.
+	acceptUnbound, _ = strconv.ParseBool(api.EnvOr("locations_accept_unbound", "true"))
+)
+
+// rebind replaces the record in KEYS[1] with ARGV[2] while it is still ARGV[1], so that binding a
+// legacy record does not overwrite locations saved meanwhile. Running it as a script makes the
+// comparison and the write atomic.
+var rebind = redis.NewScript(`
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+redis.call("SET", KEYS[1], ARGV[2])
+return 1
+`)
+
+const (
+	maxLocations  = 50
+	maxNameLength = 100
+)
+
+// location is a place saved by a user.
+type location struct {
+	Name string  `json:"name"`
+	Lat  float64 `json:"lat"`
+	Lon  float64 `json:"lon"`
+}
+
+func validateLocations(locations []location) error {
+	if len(locations) > maxLocations {
+		return fmt.Errorf("at most %d locations can be saved", maxLocations)
+	}
+
+	for i, l := range locations {
+		if strings.TrimSpace(l.Name) == "" || len(l.Name) > maxNameLength {
+			return fmt.Errorf("location %d must have a name of at most %d bytes", i, maxNameLength)
+		}
+
+		if err := geo.ValidateCoordinates(l.Lat, l.Lon); err != nil {
+			return fmt.Errorf("location %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+func locationsKey(user string) string {
+	return "locations:" + user
+}
+
+// openLocations decrypts the sealed locations of user. They are bound to the user's key, so that
+// the record of one user cannot be copied over the one of another. While acceptUnbound is set,
+// records sealed before they were bound are still accepted, and bound as they are read.
+func openLocations(ctx context.Context, sealed, user string) ([]byte, error) {
+	if encryptionKeyErr != nil {
+		return nil, encryptionKeyErr
+	}
+
+	plaintext, err := internal.DecryptWithAAD(sealed, encryptionKey, []byte(locationsKey(user)))
+	if err == nil || !acceptUnbound {
+		return plaintext, err
+	}
+
+	legacy, legacyErr := internal.Decrypt(sealed, encryptionKey)
+	if legacyErr != nil {
+		return nil, err
+	}
+
+	bindLocations(ctx, sealed, user, legacy)
+	return legacy, nil
+}
+
+// bindLocations seals the legacy record of user again, bound to the user's key. Failures are only
+// logged, since the record is bound on the next read or replace instead.
+func bindLocations(ctx context.Context, sealed, user string, plaintext []byte) {
+	bound, err := internal.EncryptWithAAD(plaintext, encryptionKey, []byte(locationsKey(user)))
+	if err != nil {
+		logger.WarnContext(ctx, "failed to bind legacy locations", slog.Any("error", err))
+		return
+	}
+
+	replaced, err := rebind.Run(ctx, redisClient, []string{locationsKey(user)}, sealed, bound).Int()
+	if err != nil {
+		logger.WarnContext(ctx, "failed to bind legacy locations", slog.Any("error", err))
+		return
+	}
+
+	if replaced == 1 {
+		logger.InfoContext(ctx, "bound legacy locations")
+	}
+}
+
+// list returns the saved locations of the user, an empty array when there are none.
+func list(ctx context.Context, req *events.APIGatewayProxyRequest, user string) *events.APIGatewayProxyResponse {
+	sealed, err := redisClient.Get(ctx, locationsKey(user)).Result()
+	if errors.Is(err, redis.Nil) {
+		return api.Respond(req, http.StatusOK, "[]")
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to retrieve locations", slog.Any("error", err))
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to retrieve locations")
+	}
+
+	plaintext, err := openLocations(ctx, sealed, user)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to decrypt locations", slog.Any("error", err))
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to retrieve locations")
+	}
+
+	return api.Respond(req, http.StatusOK, string(plaintext))
+}
+
+// replace stores the JSON array of locations in the body as the user's saved locations.
+func replace(ctx context.Context, req *events.APIGatewayProxyRequest, user string) *events.APIGatewayProxyResponse {
+	body, err := api.RequestBody(req)
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_body", "invalid request body")
+	}
+
+	var locations []location
+	if err := json.Unmarshal(body, &locations); err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_body", "request body must be an array of {name, lat, lon} objects")
+	}
+
+	if err := validateLocations(locations); err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_locations", err.Error())
+	}
+
+	// Re-encoding drops unknown fields, so that only what the server validated is stored.
+	if locations == nil {
+		locations = []location{}
+	}
+	plaintext, _ := json.Marshal(locations)
+	if encryptionKeyErr != nil {
+		logger.ErrorContext(ctx, "invalid locations_key", slog.Any("error", encryptionKeyErr))
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to store locations")
+	}
+
+	sealed, err := internal.EncryptWithAAD(plaintext, encryptionKey, []byte(locationsKey(user)))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to encrypt locations", slog.Any("error", err))
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to store locations")
+	}
+
+	if err := redisClient.Set(ctx, locationsKey(user), sealed, 0).Err(); err != nil {
+		logger.ErrorContext(ctx, "failed to store locations", slog.Any("error", err))
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to store locations")
+	}
+
+	return api.Respond(req, http.StatusOK, string(plaintext))
+}
+
+// remove deletes all saved locations of the user.
+func remove(ctx context.Context, req *events.APIGatewayProxyRequest, user string) *events.APIGatewayProxyResponse {
+	if err := redisClient.Del(ctx, locationsKey(user)).Err(); err != nil {
+		logger.ErrorContext(ctx, "failed to delete locations", slog.Any("error", err))
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to delete locations")
+	}
+
+	return api.Respond(req, http.StatusNoContent, "")
+}
+
+var routes = api.NewMux(strings.Split(api.EnvOr("locations_route_prefixes", "/.netlify/functions/locations"), ",")...).
+	Handle(http.MethodGet, "/", api.WithUser(list)).
+	Handle(http.MethodPut, "/", api.WithUser(replace)).
+	Handle(http.MethodDelete, "/", api.WithUser(remove))
+
+func main() {
+	lambda.Start(metrics.Instrument(redisClient, "locations", api.Handler(routes)))
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"log/slog"
+	"math"
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/cache"
+	"nawa-functions/internal/geo"
+	"nawa-functions/internal/metrics"
+	"nawa-functions/internal/weather"
+	"net/http"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+var (
+	redisClient = redis.NewClient(&redis.Options{
+		Addr:     os.Getenv("db_address"),
+		Username: os.Getenv("db_username"),
+		Password: os.Getenv("db_password"),
+		DB:       0,
+	})
+	// responses caches upstream responses, in memory when no Redis server is configured.
+	responses = cache.New(redisClient)
+	logger    = api.Logger
+
+	titleFace   = newFace(gobold.TTF, 88)
+	summaryFace = newFace(goregular.TTF, 64)
+	footerFace  = newFace(goregular.TTF, 30)
+)
+
+const (
+	// cardWidth and cardHeight are the size Open Graph consumers recommend for share images.
+	cardWidth     = 1200
+	cardHeight    = 630
+	cardMargin    = 80
+	maxNameLength = 60
+	footer        = "nawa · " + weather.Attribution
+)
+
+// cardKeys is the keyspace of the rendered share images.
+var cardKeys = cache.Keyspace{Namespace: "og", Kind: "card", Version: 2}
+
+var (
+	dayColors   = [2]color.RGBA{{0x4a, 0x90, 0xd9, 0xff}, {0x1e, 0x5a, 0x9e, 0xff}}
+	nightColors = [2]color.RGBA{{0x1b, 0x26, 0x42, 0xff}, {0x0b, 0x10, 0x20, 0xff}}
+)
+
+func newFace(ttf []byte, size float64) font.Face {
+	f, err := opentype.Parse(ttf)
+	if err != nil {
+		panic(err)
+	}
+
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		panic(err)
+	}
+
+	return face
+}
+
+// card is the text of a share image.
+type card struct {
+	title   string
+	summary string
+	isDay   bool
+}
+
+// newCard describes the weather in current, such as "Kathmandu" and "72°F, Sunny".
+func newCard(name string, current *weather.Current) card {
+	return card{
+		title:   name,
+		summary: fmt.Sprintf("%d%s, %s", int(math.Round(current.Current.Temperature)), current.Units.Temperature, current.Current.Condition),
+		isDay:   current.Current.IsDay,
+	}
+}
+
+func (c card) colors() [2]color.RGBA {
+	if c.isDay {
+		return dayColors
+	}
+
+	return nightColors
+}
+
+// fit shortens text with an ellipsis until it is at most width wide in face.
+func fit(face font.Face, text string, width int) string {
+	limit := fixed.I(width)
+	if font.MeasureString(face, text) <= limit {
+		return text
+	}
+
+	for text != "" {
+		_, size := utf8.DecodeLastRuneInString(text)
+		text = text[:len(text)-size]
+		if font.MeasureString(face, text+"…") <= limit {
+			return text + "…"
+		}
+	}
+
+	return text
+}
+
+// renderPNG draws the card on a vertical gradient.
+func renderPNG(c card) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, cardWidth, cardHeight))
+	colors := c.colors()
+	for y := range cardHeight {
+		t := float64(y) / cardHeight
+		lerp := func(a, b uint8) uint8 { return uint8(float64(a) + (float64(b)-float64(a))*t) }
+		row := color.RGBA{lerp(colors[0].R, colors[1].R), lerp(colors[0].G, colors[1].G), lerp(colors[0].B, colors[1].B), 0xff}
+		draw.Draw(img, image.Rect(0, y, cardWidth, y+1), image.NewUniform(row), image.Point{}, draw.Src)
+	}
+
+	text := func(face font.Face, s string, y int, c color.Color) {
+		d := &font.Drawer{Dst: img, Src: image.NewUniform(c), Face: face, Dot: fixed.P(cardMargin, y)}
+		d.DrawString(fit(face, s, cardWidth-2*cardMargin))
+	}
+	text(titleFace, c.title, 260, color.White)
+	text(summaryFace, c.summary, 370, color.White)
+	text(footerFace, footer, cardHeight-cardMargin, color.NRGBA{0xff, 0xff, 0xff, 0xb0})
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// renderSVG lays the card out like renderPNG, leaving the fonts to the viewer.
+func renderSVG(c card) []byte {
+	colors := c.colors()
+	rgb := func(c color.RGBA) string { return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B) }
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %[1]d %[2]d">`, cardWidth, cardHeight)
+	fmt.Fprintf(&b, `<defs><linearGradient id="sky" x1="0" y1="0" x2="0" y2="1"><stop offset="0" stop-color="%s"/><stop offset="1" stop-color="%s"/></linearGradient></defs>`, rgb(colors[0]), rgb(colors[1]))
+	b.WriteString(`<rect width="100%" height="100%" fill="url(#sky)"/>`)
+	b.WriteString(`<g font-family="Helvetica, Arial, sans-serif" fill="#fff">`)
+	fmt.Fprintf(&b, `<text x="%d" y="260" font-size="88" font-weight="bold">%s</text>`, cardMargin, html.EscapeString(fit(titleFace, c.title, cardWidth-2*cardMargin)))
+	fmt.Fprintf(&b, `<text x="%d" y="370" font-size="64">%s</text>`, cardMargin, html.EscapeString(c.summary))
+	fmt.Fprintf(&b, `<text x="%d" y="%d" font-size="30" fill-opacity="0.7">%s</text>`, cardMargin, cardHeight-cardMargin, html.EscapeString(footer))
+	b.WriteString(`</g></svg>`)
+
+	return []byte(b.String())
+}
+
+// shareImage returns the share image of the location at lat/lon with the name and format parameters,
+// PNG by default since most Open Graph consumers do not accept SVG. Images are cached as long as the
+// conditions they show. The route is public, since the crawlers fetching share images have no
+// client token.
+func shareImage(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	latitude, longitude, err := geo.ParseCoordinates(req.QueryStringParameters["lat"], req.QueryStringParameters["lon"])
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_coordinates", err.Error())
+	}
+
+	units, err := weather.ParseUnitSystem(req.QueryStringParameters["units"])
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_options", err.Error())
+	}
+
+	format := api.EnvOr("og_default_format", "png")
+	if value, ok := req.QueryStringParameters["format"]; ok {
+		format = value
+	}
+	if format != "png" && format != "svg" {
+		return api.Error(req, http.StatusBadRequest, "invalid_options", fmt.Sprintf("format must be png or svg, got %q", format))
+	}
+
+	lat := geo.FormatCoordinate(latitude, weather.CoordinatePrecision)
+	lon := geo.FormatCoordinate(longitude, weather.CoordinatePrecision)
+	name := strings.TrimSpace(req.QueryStringParameters["name"])
+	if name == "" {
+		name = lat + ", " + lon
+	}
+	if utf8.RuneCountInString(name) > maxNameLength {
+		return api.Error(req, http.StatusBadRequest, "invalid_name", fmt.Sprintf("name must be at most %d characters long", maxNameLength))
+	}
+
+	contentType := "image/png"
+	if format == "svg" {
+		contentType = "image/svg+xml"
+	}
+
+	sum := sha256.Sum256([]byte(name))
+	key := cardKeys.Key(format, lat+","+lon, string(units), hex.EncodeToString(sum[:8]))
+	if cached := cache.Lookup(ctx, responses, key); cached != "" {
+		if data, err := base64.StdEncoding.DecodeString(cached); err == nil {
+			return withCacheControl(api.Binary(req, contentType, data))
+		}
+	}
+
+	body, err := weather.CachedCurrent(ctx, responses, lat, lon)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to load current conditions", slog.Any("error", err))
+		return api.UpstreamError(req)
+	}
+
+	var current weather.Current
+	if err := json.Unmarshal([]byte(body), &current); err != nil {
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to decode current conditions")
+	}
+	current.Convert(units)
+
+	c := newCard(name, &current)
+	var data []byte
+	if format == "svg" {
+		data = renderSVG(c)
+	} else if data, err = renderPNG(c); err != nil {
+		logger.ErrorContext(ctx, "failed to render share image", slog.Any("error", err))
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to render share image")
+	}
+
+	cache.Store(ctx, responses, key, base64.StdEncoding.EncodeToString(data), weather.CurrentCacheTTL)
+
+	return withCacheControl(api.Binary(req, contentType, data))
+}
+
+// withCacheControl lets crawlers and CDNs reuse a share image while its conditions are current.
+func withCacheControl(res *events.APIGatewayProxyResponse) *events.APIGatewayProxyResponse {
+	res.Headers["Cache-Control"] = fmt.Sprintf("public, max-age=%d", int(weather.CurrentCacheTTL.Seconds()))
+	return res
+}
+
+var routes = api.NewMux(strings.Split(api.EnvOr("og_route_prefixes", "/.netlify/functions/og"), ",")...).
+	Handle(http.MethodGet, "/", shareImage)
+
+func main() {
+	lambda.Start(metrics.Instrument(redisClient, "og", api.Handler(routes)))
+}
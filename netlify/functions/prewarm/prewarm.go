@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"nawa-functions/internal"
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/prewarm"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	redisClient = redis.NewClient(&redis.Options{
+		Addr:     os.Getenv("db_address"),
+		Username: os.Getenv("db_username"),
+		Password: os.Getenv("db_password"),
+		DB:       0,
+	})
+	logger = api.Logger
+	// baseURL is the site the functions are served from. Netlify sets URL to its primary URL.
+	baseURL             = strings.TrimSuffix(api.EnvOr("prewarm_base_url", os.Getenv("URL")), "/")
+	refreshSecret       = os.Getenv("refresh_secret")
+	nawaToken           = os.Getenv("nawa_token")
+	nawaKey, nawaKeyErr = internal.SecretFromEnv("nawa_key")
+	topN                = parseTop(os.Getenv("prewarm_top"))
+	prewarmParallel     = 5
+)
+
+const (
+	// maxTracked bounds the sorted sets, dropping the least requested entries, so that one off
+	// queries do not accumulate forever.
+	maxTracked = 1000
+	defaultTop = 20
+)
+
+// parseTop parses how many of the most requested locations are refreshed. Without at least one,
+// nothing would be.
+func parseTop(value string) int {
+	top, err := strconv.Atoi(value)
+	if err != nil || top <= 0 {
+		return defaultTop
+	}
+
+	return top
+}
+
+// refresh requests path with the refresh secret, so that the function serving it fetches the cache
+// entries that are about to expire again.
+func refresh(ctx context.Context, path string, params url.Values, token string) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+path+"?"+params.Encode(), nil)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to build refresh request", slog.String("path", path), slog.Any("error", err))
+		return
+	}
+	req.Header.Set("X-Nawa-Refresh", refreshSecret)
+	if token != "" {
+		req.Header.Set("X-Nawa-Token", token)
+	}
+
+	res, err := api.HTTPClient.Do(req)
+	if err != nil {
+		logger.ErrorContext(ctx, "refresh request failed", slog.String("path", path), slog.Any("error", err))
+		return
+	}
+	res.Body.Close()
+
+	logger.InfoContext(ctx, "refreshed", slog.String("path", path), slog.String("query", params.Encode()),
+		slog.Int("statusCode", res.StatusCode), slog.String("cache", res.Header.Get("X-Cache")))
+}
+
+// popular returns the topN most requested members of key and trims it to maxTracked members.
+func popular(ctx context.Context, key string) []string {
+	members, err := redisClient.ZRevRange(ctx, key, 0, int64(topN-1)).Result()
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to read popular requests", slog.String("key", key), slog.Any("error", err))
+		return nil
+	}
+
+	if err := redisClient.ZRemRangeByRank(ctx, key, 0, -maxTracked-1).Err(); err != nil {
+		logger.WarnContext(ctx, "failed to trim popular requests", slog.String("key", key), slog.Any("error", err))
+	}
+
+	return members
+}
+
+// handler runs on the schedule configured in netlify.toml. It refreshes the forward geocodes and
+// forecasts of the most requested locations through the functions that serve them, which only
+// fetch the entries expiring within api.RefreshWindow again.
+func handler(ctx context.Context, _ events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	if baseURL == "" || refreshSecret == "" {
+		logger.ErrorContext(ctx, "prewarming requires URL and refresh_secret to be set")
+		return &events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, nil
+	}
+
+	var token string
+	if nawaToken != "" {
+		if nawaKeyErr != nil {
+			logger.ErrorContext(ctx, "invalid nawa_key", slog.Any("error", nawaKeyErr))
+			return &events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, nil
+		}
+
+		var err error
+		if token, err = internal.Encrypt([]byte(nawaToken), nawaKey); err != nil {
+			logger.ErrorContext(ctx, "failed to encrypt client token", slog.Any("error", err))
+			return &events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, nil
+		}
+	}
+
+	sem := make(chan struct{}, prewarmParallel)
+	var wg sync.WaitGroup
+	run := func(path string, params url.Values) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			refresh(ctx, path, params, token)
+		}()
+	}
+
+	for _, query := range popular(ctx, prewarm.ForwardKey) {
+		run("/.netlify/functions/geocoding/forward", url.Values{"q": {query}})
+	}
+
+	for _, location := range popular(ctx, prewarm.ForecastKey) {
+		lat, lon, ok := strings.Cut(location, ",")
+		if !ok {
+			continue
+		}
+
+		params := url.Values{"lat": {lat}, "lon": {lon}}
+		run("/.netlify/functions/weather/forecast/daily", params)
+		run("/.netlify/functions/weather/forecast/hourly", params)
+	}
+	wg.Wait()
+
+	return &events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+}
+
+func main() {
+	lambda.Start(handler)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/metrics"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	redisClient = redis.NewClient(&redis.Options{
+		Addr:     os.Getenv("db_address"),
+		Username: os.Getenv("db_username"),
+		Password: os.Getenv("db_password"),
+		DB:       0,
+	})
+	logger    = api.Logger
+	upstreams = parseUpstreams(os.Getenv("proxy_upstreams"))
+	// client shares the connections of api.HTTPClient but does not follow redirects, which could
+	// take the injected keys to a host outside the allowlist.
+	client = &http.Client{
+		Timeout:   api.HTTPClient.Timeout,
+		Transport: api.HTTPClient.Transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+)
+
+// maxResponseSize keeps proxied responses below the 6MB payload limit of Lambda once base64 encoded.
+const maxResponseSize = 4 << 20
+
+// upstream describes how to authenticate with an allowlisted host. Query and Header map parameter
+// and header names to the environment variables holding the keys, so that the configuration itself
+// holds no secrets.
+type upstream struct {
+	Query  map[string]string `json:"query"`
+	Header map[string]string `json:"header"`
+}
+
+// parseUpstreams reads the allowlist from proxy_upstreams, a JSON object keyed by host, such as
+// {"api.openweathermap.org": {"query": {"appid": "radar_api_key"}}}.
+func parseUpstreams(value string) map[string]upstream {
+	hosts := map[string]upstream{}
+	if value == "" {
+		return hosts
+	}
+
+	if err := json.Unmarshal([]byte(value), &hosts); err != nil {
+		logger.Error("failed to parse proxy_upstreams, no upstream is allowed", slog.Any("error", err))
+		return map[string]upstream{}
+	}
+
+	return hosts
+}
+
+// upstreamRequest builds the request to the url parameter, which must be an https URL of an
+// allowlisted host, with the keys of the host added.
+func upstreamRequest(ctx context.Context, target string) (*http.Request, error) {
+	u, err := url.Parse(target)
+	if err != nil || u.Scheme != "https" || u.User != nil {
+		return nil, errors.New("url must be an absolute https URL")
+	}
+
+	config, ok := upstreams[strings.ToLower(u.Host)]
+	if !ok {
+		return nil, errors.New("url host is not allowed")
+	}
+
+	params := u.Query()
+	for name, env := range config.Query {
+		if params.Has(name) {
+			return nil, errors.New("url must not set the " + name + " parameter")
+		}
+		params.Set(name, os.Getenv(env))
+	}
+	u.RawQuery = params.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "nawa-functions (https://tshrestha.github.io/nawa)")
+	for name, env := range config.Header {
+		req.Header.Set(name, os.Getenv(env))
+	}
+
+	return req, nil
+}
+
+// forward sends a GET request to the url parameter with the server held keys of its host and
+// returns the response, so that providers which only need a key do not each need a function.
+func forward(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	upstreamReq, err := upstreamRequest(ctx, req.QueryStringParameters["url"])
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_url", err.Error())
+	}
+
+	res, err := client.Do(upstreamReq)
+	if err != nil {
+		// The error of the client repeats the URL, with the keys injected into its query.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		logger.ErrorContext(ctx, "proxied request failed", slog.String("host", upstreamReq.URL.Host), slog.Any("error", err))
+		return api.UpstreamError(req)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		logger.ErrorContext(ctx, "received unexpected status code", slog.String("host", upstreamReq.URL.Host), slog.Int("statusCode", res.StatusCode))
+		return api.UpstreamError(req)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxResponseSize+1))
+	if err != nil {
+		return api.UpstreamError(req)
+	}
+
+	if len(body) > maxResponseSize {
+		return api.Error(req, http.StatusBadGateway, "response_too_large", "upstream response is too large to proxy")
+	}
+
+	contentType := res.Header.Get("Content-Type")
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType == "application/json" {
+		return api.Respond(req, http.StatusOK, string(body))
+	}
+
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+	return api.Binary(req, contentType, body)
+}
+
+var routes = api.NewMux(strings.Split(api.EnvOr("proxy_route_prefixes", "/.netlify/functions/proxy"), ",")...).
+	Handle(http.MethodGet, "/", api.Authorized(forward))
+
+func main() {
+	lambda.Start(metrics.Instrument(redisClient, "proxy", api.Handler(routes)))
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"nawa-functions/internal/alerts"
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/push"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/SherClockHolmes/webpush-go"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	redisClient = redis.NewClient(&redis.Options{
+		Addr:     os.Getenv("db_address"),
+		Username: os.Getenv("db_username"),
+		Password: os.Getenv("db_password"),
+		DB:       0,
+	})
+	logger       = api.Logger
+	vapidOptions = webpush.Options{
+		Subscriber:      api.EnvOr("vapid_subject", "https://tshrestha.github.io/nawa"),
+		VAPIDPublicKey:  os.Getenv("vapid_public_key"),
+		VAPIDPrivateKey: os.Getenv("vapid_private_key"),
+		HTTPClient:      api.HTTPClient,
+		TTL:             int(time.Hour / time.Second),
+		Urgency:         webpush.UrgencyHigh,
+	}
+)
+
+// sentTTL keeps track of the alerts a subscription was notified of for longer than alerts usually
+// stay active, so that each alert is only pushed once.
+const sentTTL = 72 * time.Hour
+
+// notifiedSeverities are the alert severities worth interrupting the subscriber for.
+var notifiedSeverities = map[string]bool{"extreme": true, "severe": true}
+
+// notification is the payload the service worker of the app turns into a notification.
+type notification struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Body     string `json:"body"`
+	Severity string `json:"severity"`
+	Expires  string `json:"expires,omitempty"`
+}
+
+// subscriber is a stored subscription with its id.
+type subscriber struct {
+	id     string
+	record push.Record
+}
+
+// loadSubscribers returns the stored subscriptions grouped by alert point, so that the alerts of
+// each point are only requested once.
+func loadSubscribers(ctx context.Context) (map[string][]subscriber, error) {
+	sealed, err := redisClient.HGetAll(ctx, push.SubscriptionsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	byPoint := map[string][]subscriber{}
+	for id, value := range sealed {
+		record, err := push.Open(value)
+		if err != nil {
+			logger.ErrorContext(ctx, "failed to decrypt subscription", slog.String("id", id), slog.Any("error", err))
+			continue
+		}
+
+		point := alerts.Point(record.Lat, record.Lon)
+		byPoint[point] = append(byPoint[point], subscriber{id: id, record: record})
+	}
+
+	return byPoint, nil
+}
+
+// notify pushes alert to s unless it was pushed before. Subscriptions that the push service reports
+// as gone are deleted.
+func notify(ctx context.Context, s subscriber, alert alerts.Alert) {
+	sentKey := "push:sent:" + s.id
+	added, err := redisClient.SAdd(ctx, sentKey, alert.ID).Result()
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to record notification", slog.String("id", s.id), slog.Any("error", err))
+		return
+	}
+	redisClient.Expire(ctx, sentKey, sentTTL)
+	if added == 0 {
+		return
+	}
+
+	payload, _ := json.Marshal(notification{
+		ID:       alert.ID,
+		Title:    alert.Event,
+		Body:     alert.Headline,
+		Severity: alert.Severity,
+		Expires:  alert.Expires,
+	})
+
+	res, err := webpush.SendNotificationWithContext(ctx, payload, &s.record.Subscription, &vapidOptions)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to send notification", slog.String("id", s.id), slog.Any("error", err))
+		redisClient.SRem(ctx, sentKey, alert.ID)
+		return
+	}
+	res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusGone:
+		logger.InfoContext(ctx, "deleting expired subscription", slog.String("id", s.id))
+		redisClient.HDel(ctx, push.SubscriptionsKey, s.id)
+		redisClient.Del(ctx, sentKey)
+	case res.StatusCode >= http.StatusBadRequest:
+		logger.ErrorContext(ctx, "push service rejected notification", slog.String("id", s.id), slog.Int("statusCode", res.StatusCode))
+		redisClient.SRem(ctx, sentKey, alert.ID)
+	default:
+		logger.InfoContext(ctx, "sent notification", slog.String("id", s.id), slog.String("alert", alert.ID))
+	}
+}
+
+// handler runs on the schedule configured in netlify.toml. It checks the active alerts of every
+// subscribed location and pushes the severe ones that subscribers have not been notified of yet.
+func handler(ctx context.Context, _ events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	byPoint, err := loadSubscribers(ctx)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to load subscriptions", slog.Any("error", err))
+		return &events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, nil
+	}
+
+	for point, subscribers := range byPoint {
+		result, err := alerts.Fetch(ctx, url.Values{"point": {point}})
+		if err != nil {
+			logger.ErrorContext(ctx, "failed to fetch alerts", slog.String("point", point), slog.Any("error", err))
+			continue
+		}
+
+		for _, alert := range result.Alerts {
+			if !notifiedSeverities[alert.Severity] {
+				continue
+			}
+
+			for _, s := range subscribers {
+				notify(ctx, s, alert)
+			}
+		}
+	}
+
+	return &events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+}
+
+func main() {
+	lambda.Start(handler)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/geo"
+	"nawa-functions/internal/metrics"
+	"nawa-functions/internal/push"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	redisClient = redis.NewClient(&redis.Options{
+		Addr:     os.Getenv("db_address"),
+		Username: os.Getenv("db_username"),
+		Password: os.Getenv("db_password"),
+		DB:       0,
+	})
+	logger         = api.Logger
+	vapidPublicKey = os.Getenv("vapid_public_key")
+)
+
+type subscribeRequest struct {
+	Subscription struct {
+		Endpoint string `json:"endpoint"`
+		Keys     struct {
+			Auth   string `json:"auth"`
+			P256dh string `json:"p256dh"`
+		} `json:"keys"`
+	} `json:"subscription"`
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// validEndpoint reports whether endpoint is an absolute https URL, as push services require.
+func validEndpoint(endpoint string) bool {
+	u, err := url.Parse(endpoint)
+	return err == nil && u.Scheme == "https" && u.Host != ""
+}
+
+// subscribe stores the Web Push subscription in the body, as returned by PushManager.subscribe in the
+// browser, together with the location to watch for alerts. Subscribing again with the same endpoint
+// replaces the location.
+func subscribe(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	body, err := api.RequestBody(req)
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_body", "invalid request body")
+	}
+
+	var subscription subscribeRequest
+	if err := json.Unmarshal(body, &subscription); err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_body", "request body must be a JSON object")
+	}
+
+	s := subscription.Subscription
+	if !validEndpoint(s.Endpoint) || s.Keys.Auth == "" || s.Keys.P256dh == "" {
+		return api.Error(req, http.StatusBadRequest, "invalid_subscription", "subscription must have an https endpoint and keys")
+	}
+
+	if err := geo.ValidateCoordinates(subscription.Lat, subscription.Lon); err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_coordinates", err.Error())
+	}
+
+	record := push.Record{Lat: subscription.Lat, Lon: subscription.Lon}
+	record.Subscription.Endpoint = s.Endpoint
+	record.Subscription.Keys.Auth = s.Keys.Auth
+	record.Subscription.Keys.P256dh = s.Keys.P256dh
+
+	sealed, err := push.Seal(record)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to encrypt subscription", slog.Any("error", err))
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to store subscription")
+	}
+
+	id := push.ID(s.Endpoint)
+	if err := redisClient.HSet(ctx, push.SubscriptionsKey, id, sealed).Err(); err != nil {
+		logger.ErrorContext(ctx, "failed to store subscription", slog.Any("error", err))
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to store subscription")
+	}
+
+	logger.InfoContext(ctx, "stored push subscription", slog.String("id", id))
+	return api.Respond(req, http.StatusCreated, `{"id":"`+id+`"}`)
+}
+
+// unsubscribe deletes the subscription with the endpoint given in the body.
+func unsubscribe(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	body, err := api.RequestBody(req)
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_body", "invalid request body")
+	}
+
+	var subscription struct {
+		Endpoint string `json:"endpoint"`
+	}
+	if err := json.Unmarshal(body, &subscription); err != nil || subscription.Endpoint == "" {
+		return api.Error(req, http.StatusBadRequest, "invalid_body", "request body must be a JSON object with an endpoint")
+	}
+
+	if err := redisClient.HDel(ctx, push.SubscriptionsKey, push.ID(subscription.Endpoint)).Err(); err != nil {
+		logger.ErrorContext(ctx, "failed to delete subscription", slog.Any("error", err))
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to delete subscription")
+	}
+
+	return api.Respond(req, http.StatusNoContent, "")
+}
+
+// publicKey returns the VAPID public key that browsers need to subscribe, and the key that they can
+// seal subscriptions to when sealed request bodies are accepted.
+func publicKey(_ context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	keys := map[string]string{"publicKey": vapidPublicKey}
+	if key := api.SealingPublicKey(); key != "" {
+		keys["sealingKey"] = key
+	}
+
+	body, _ := json.Marshal(keys)
+	return api.Respond(req, http.StatusOK, string(body))
+}
+
+var routes = api.NewMux(strings.Split(api.EnvOr("push_route_prefixes", "/.netlify/functions/push"), ",")...).
+	Handle(http.MethodGet, "/key", publicKey).
+	Handle(http.MethodPost, "/subscriptions", api.Authorized(subscribe)).
+	Handle(http.MethodDelete, "/subscriptions", api.Authorized(unsubscribe))
+
+func main() {
+	lambda.Start(metrics.Instrument(redisClient, "push", api.Handler(routes)))
+}
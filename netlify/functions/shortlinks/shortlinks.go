@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"nawa-functions/internal"
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/geo"
+	"nawa-functions/internal/metrics"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	redisClient = redis.NewClient(&redis.Options{
+		Addr:     os.Getenv("db_address"),
+		Username: os.Getenv("db_username"),
+		Password: os.Getenv("db_password"),
+		DB:       0,
+	})
+	logger = api.Logger
+	// appURL is the frontend page shortlinks redirect to.
+	appURL = api.EnvOr("shortlink_app_url", "https://tshrestha.github.io/nawa/")
+	// linkURL is the public base URL of the GET route, used to build the returned short URL.
+	linkURL = strings.TrimSuffix(api.EnvOr("shortlink_base_url", os.Getenv("URL")+"/s"), "/")
+)
+
+const (
+	// idBytes random bytes encode to 8 URL safe characters, which is plenty for links that are
+	// created by hand.
+	idBytes       = 6
+	shortlinkTTL  = 365 * 24 * time.Hour
+	maxNameLength = 100
+	maxAttempts   = 3
+)
+
+var (
+	idPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{8}$`)
+	views     = []string{"current", "hourly", "daily", "radar"}
+)
+
+// link is the location and view state a shortlink points to.
+type link struct {
+	Lat  float64 `json:"lat"`
+	Lon  float64 `json:"lon"`
+	Name string  `json:"name,omitempty"`
+	View string  `json:"view,omitempty"`
+}
+
+func (l link) validate() error {
+	if err := geo.ValidateCoordinates(l.Lat, l.Lon); err != nil {
+		return err
+	}
+
+	if len(l.Name) > maxNameLength {
+		return fmt.Errorf("name must be at most %d bytes long", maxNameLength)
+	}
+
+	if l.View != "" && !slices.Contains(views, l.View) {
+		return errors.New("view must be one of " + strings.Join(views, ", "))
+	}
+
+	return nil
+}
+
+// target returns the frontend URL that shows the link.
+func (l link) target() string {
+	params := url.Values{
+		"lat": {strconv.FormatFloat(l.Lat, 'f', -1, 64)},
+		"lon": {strconv.FormatFloat(l.Lon, 'f', -1, 64)},
+	}
+	if l.Name != "" {
+		params.Set("name", l.Name)
+	}
+	if l.View != "" {
+		params.Set("view", l.View)
+	}
+
+	return appURL + "?" + params.Encode()
+}
+
+func shortlinkKey(id string) string {
+	return "shortlink:" + id
+}
+
+// create stores the link in the body under a new random id and returns the id and short URL.
+func create(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	body, err := api.RequestBody(req)
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_body", "invalid request body")
+	}
+
+	var l link
+	if err := json.Unmarshal(body, &l); err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_body", "request body must be a JSON object")
+	}
+
+	if err := l.validate(); err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_link", err.Error())
+	}
+
+	encoded, _ := json.Marshal(l)
+	for range maxAttempts {
+		id := internal.NewToken(idBytes)
+		created, err := redisClient.SetNX(ctx, shortlinkKey(id), encoded, shortlinkTTL).Result()
+		if err != nil {
+			logger.ErrorContext(ctx, "failed to store shortlink", slog.Any("error", err))
+			return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to create shortlink")
+		}
+
+		if created {
+			result, _ := json.Marshal(map[string]string{"id": id, "url": linkURL + "/" + id})
+			return api.Respond(req, http.StatusCreated, string(result))
+		}
+	}
+
+	logger.ErrorContext(ctx, "failed to find an unused shortlink id")
+	return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to create shortlink")
+}
+
+// resolve redirects to the frontend view of the link with the given id. It is public, since the
+// people following a shared link have no client token.
+func resolve(ctx context.Context, req *events.APIGatewayProxyRequest, id string) *events.APIGatewayProxyResponse {
+	if !idPattern.MatchString(id) {
+		return api.Error(req, http.StatusNotFound, "not_found", "shortlink not found")
+	}
+
+	stored, err := redisClient.Get(ctx, shortlinkKey(id)).Result()
+	if errors.Is(err, redis.Nil) {
+		return api.Error(req, http.StatusNotFound, "not_found", "shortlink not found")
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to retrieve shortlink", slog.String("id", id), slog.Any("error", err))
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to resolve shortlink")
+	}
+
+	var l link
+	if err := json.Unmarshal([]byte(stored), &l); err != nil {
+		logger.ErrorContext(ctx, "failed to decode shortlink", slog.String("id", id), slog.Any("error", err))
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to resolve shortlink")
+	}
+
+	return api.Redirect(req, l.target())
+}
+
+var routes = api.NewMux(strings.Split(api.EnvOr("shortlinks_route_prefixes", "/.netlify/functions/shortlinks,/s"), ",")...).
+	Handle(http.MethodPost, "/", api.Authorized(create)).
+	Handle(http.MethodGet, "/{id}", func(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+		return resolve(ctx, req, req.PathParameters["id"])
+	})
+
+func main() {
+	lambda.Start(metrics.Instrument(redisClient, "shortlinks", api.Handler(routes)))
+}
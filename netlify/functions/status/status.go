@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"math"
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/cache"
+	"nawa-functions/internal/metrics"
+	"nawa-functions/internal/weather"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	redisClient = redis.NewClient(&redis.Options{
+		Addr:     os.Getenv("db_address"),
+		Username: os.Getenv("db_username"),
+		Password: os.Getenv("db_password"),
+		DB:       0,
+	})
+	// responses caches upstream responses, in memory when no Redis server is configured.
+	responses = cache.New(redisClient)
+	logger    = api.Logger
+)
+
+const (
+	probeTimeout = 3 * time.Second
+	// statusTTL is how long the status is cached, so that a widget shown to every visitor does not
+	// probe the dependencies on every page view.
+	statusTTL = time.Minute
+	// A dependency that answers probes is still degraded when more than degradedErrorRate of at
+	// least minRecentRequests recent requests to it failed.
+	degradedErrorRate = 0.1
+	minRecentRequests = 10
+	mapboxURL         = "https://api.mapbox.com/"
+)
+
+var statusKey = cache.Keyspace{Namespace: "status", Kind: "snapshot", Version: 2}.Key()
+
+// dependency is an upstream the functions rely on. host is the host whose recent error rate is
+// reported, empty for dependencies that are not requested over HTTP.
+type dependency struct {
+	name  string
+	host  string
+	probe func(ctx context.Context) error
+}
+
+var dependencies = []dependency{
+	{name: "mapbox", host: hostOf(mapboxURL), probe: probeMapbox},
+	{name: "weather", host: hostOf(weather.ForecastURL), probe: probeWeather},
+	{name: "redis", probe: func(ctx context.Context) error { return redisClient.Ping(ctx).Err() }},
+}
+
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+
+	return u.Host
+}
+
+// probeMapbox requests the API root without an access token, which proves reachability without
+// consuming quota. Any non 5xx status counts as reachable.
+func probeMapbox(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, mapboxURL, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := api.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	if res.StatusCode >= http.StatusInternalServerError {
+		return &api.StatusError{StatusCode: res.StatusCode}
+	}
+
+	return nil
+}
+
+// probeWeather requests the smallest possible forecast.
+func probeWeather(ctx context.Context) error {
+	params := url.Values{"latitude": {"0"}, "longitude": {"0"}, "current": {"temperature_2m"}}
+	_, err := api.Get(ctx, weather.ForecastURL+"?"+params.Encode())
+	return err
+}
+
+// dependencyStatus is the health of a dependency. ErrorRate is the share of the requests made to it
+// in the last one to two hours that failed, omitted when there were none. Probe errors are not
+// exposed, since the status is public.
+type dependencyStatus struct {
+	Status         string   `json:"status"`
+	LatencyMs      int64    `json:"latencyMs"`
+	RecentRequests *int64   `json:"recentRequests,omitempty"`
+	ErrorRate      *float64 `json:"errorRate,omitempty"`
+}
+
+type statusResult struct {
+	Status       string                      `json:"status"`
+	CheckedAt    time.Time                   `json:"checkedAt"`
+	Dependencies map[string]dependencyStatus `json:"dependencies"`
+}
+
+// severity orders the statuses from the best to the worst.
+var severity = map[string]int{"operational": 0, "degraded": 1, "down": 2}
+
+func check(ctx context.Context, d dependency) dependencyStatus {
+	probeCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := d.probe(probeCtx)
+	status := dependencyStatus{Status: "operational", LatencyMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		logger.WarnContext(ctx, "status probe failed", slog.String("dependency", d.name), slog.Any("error", err))
+		status.Status = "down"
+	}
+
+	if d.host == "" {
+		return status
+	}
+
+	requests, failures, err := metrics.RecentUpstream(ctx, redisClient, d.host)
+	if err != nil || requests == 0 {
+		return status
+	}
+
+	rate := math.Round(float64(failures)/float64(requests)*1000) / 1000
+	status.RecentRequests, status.ErrorRate = &requests, &rate
+	if status.Status == "operational" && requests >= minRecentRequests && rate > degradedErrorRate {
+		status.Status = "degraded"
+	}
+
+	return status
+}
+
+// probeAll checks every dependency concurrently.
+func probeAll(ctx context.Context) statusResult {
+	result := statusResult{Status: "operational", CheckedAt: time.Now().UTC(), Dependencies: map[string]dependencyStatus{}}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, d := range dependencies {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status := check(ctx, d)
+
+			mu.Lock()
+			defer mu.Unlock()
+			result.Dependencies[d.name] = status
+			if severity[status.Status] > severity[result.Status] {
+				result.Status = status.Status
+			}
+		}()
+	}
+	wg.Wait()
+
+	return result
+}
+
+// status reports the health of the dependencies for the status widget of the app. It is public and
+// serves a snapshot of at most statusTTL.
+func status(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	if cached := cache.Lookup(ctx, responses, statusKey); cached != "" {
+		return api.Respond(req, http.StatusOK, cached)
+	}
+
+	body, err := json.Marshal(probeAll(ctx))
+	if err != nil {
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to encode status")
+	}
+
+	cache.Store(ctx, responses, statusKey, string(body), statusTTL)
+
+	return api.Respond(req, http.StatusOK, string(body))
+}
+
+var routes = api.NewMux(strings.Split(api.EnvOr("status_route_prefixes", "/.netlify/functions/status"), ",")...).
+	Handle(http.MethodGet, "/", status)
+
+func main() {
+	lambda.Start(metrics.Instrument(redisClient, "status", api.Handler(routes)))
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/metrics"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	redisClient = redis.NewClient(&redis.Options{
+		Addr:     os.Getenv("db_address"),
+		Username: os.Getenv("db_username"),
+		Password: os.Getenv("db_password"),
+		DB:       0,
+	})
+	logger = api.Logger
+)
+
+// maxDataLength bounds the stored state, which is meant for settings rather than files.
+const maxDataLength = 64 << 10
+
+// state is the client state of a user. Data is encrypted by the client and never read by the
+// server. Version starts at 0 when nothing is stored and increases with every update.
+type state struct {
+	Version int64  `json:"version"`
+	Data    string `json:"data"`
+}
+
+// update stores the new data when the stored version still equals the version the client based its
+// change on, and returns the stored version and data otherwise. Running it as a script makes the
+// comparison and the write atomic.
+var update = redis.NewScript(`
+local version = tonumber(redis.call("HGET", KEYS[1], "version") or "0")
+if version ~= tonumber(ARGV[1]) then
+	return {0, version, redis.call("HGET", KEYS[1], "data") or ""}
+end
+redis.call("HSET", KEYS[1], "version", version + 1, "data", ARGV[2])
+return {1, version + 1, ARGV[2]}
+`)
+
+func syncKey(user string) string {
+	return "sync:" + user
+}
+
+func encode(s state) string {
+	body, _ := json.Marshal(s)
+	return string(body)
+}
+
+// get returns the stored state of the user, version 0 with no data when nothing is stored yet.
+func get(ctx context.Context, req *events.APIGatewayProxyRequest, user string) *events.APIGatewayProxyResponse {
+	values, err := redisClient.HMGet(ctx, syncKey(user), "version", "data").Result()
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to retrieve sync state", slog.Any("error", err))
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to retrieve state")
+	}
+
+	var s state
+	if version, ok := values[0].(string); ok {
+		s.Version, _ = strconv.ParseInt(version, 10, 64)
+	}
+	if data, ok := values[1].(string); ok {
+		s.Data = data
+	}
+
+	return api.Respond(req, http.StatusOK, encode(s))
+}
+
+// put replaces the state of the user. The version in the body is the version the client last read.
+// When another device updated the state since, nothing is stored and 409 Conflict returns the
+// current state for the client to merge and retry.
+func put(ctx context.Context, req *events.APIGatewayProxyRequest, user string) *events.APIGatewayProxyResponse {
+	body, err := api.RequestBody(req)
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_body", "invalid request body")
+	}
+
+	var s state
+	if err := json.Unmarshal(body, &s); err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_body", "request body must be a JSON object with version and data")
+	}
+
+	if s.Version < 0 {
+		return api.Error(req, http.StatusBadRequest, "invalid_version", "version must not be negative")
+	}
+
+	if len(s.Data) > maxDataLength {
+		return api.Error(req, http.StatusRequestEntityTooLarge, "data_too_large", "data must be at most 64 KiB")
+	}
+
+	result, err := update.Run(ctx, redisClient, []string{syncKey(user)}, s.Version, s.Data).Slice()
+	if err == nil && len(result) != 3 {
+		err = errors.New("unexpected script result")
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to update sync state", slog.Any("error", err))
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to store state")
+	}
+
+	stored, _ := result[0].(int64)
+	current := state{}
+	current.Version, _ = result[1].(int64)
+	current.Data, _ = result[2].(string)
+	if stored == 0 {
+		logger.InfoContext(ctx, "rejected outdated sync state", slog.Int64("version", s.Version), slog.Int64("current", current.Version))
+		return api.Respond(req, http.StatusConflict, encode(current))
+	}
+
+	return api.Respond(req, http.StatusOK, encode(current))
+}
+
+var routes = api.NewMux(strings.Split(api.EnvOr("sync_route_prefixes", "/.netlify/functions/sync"), ",")...).
+	Handle(http.MethodGet, "/", api.WithUser(get)).
+	Handle(http.MethodPut, "/", api.WithUser(put))
+
+func main() {
+	lambda.Start(metrics.Instrument(redisClient, "sync", api.Handler(routes)))
+}
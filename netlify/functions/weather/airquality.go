@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"maps"
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/cache"
+	"nawa-functions/internal/geo"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+var airQualityURL = api.EnvOr("air_quality_api_url", "https://air-quality-api.open-meteo.com/v1/air-quality")
+
+// pollutantVariables maps the Open-Meteo air quality variables to the pollutant names of the response.
+var pollutantVariables = map[string]string{
+	"pm2_5":            "pm2_5",
+	"pm10":             "pm10",
+	"ozone":            "o3",
+	"nitrogen_dioxide": "no2",
+	"sulphur_dioxide":  "so2",
+	"carbon_monoxide":  "co",
+}
+
+// aqiCategories are the upper bounds of the US EPA AQI categories.
+var aqiCategories = []struct {
+	max  float64
+	name string
+}{
+	{50, "good"},
+	{100, "moderate"},
+	{150, "unhealthy_for_sensitive_groups"},
+	{200, "unhealthy"},
+	{300, "very_unhealthy"},
+}
+
+type pollutant struct {
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit"`
+}
+
+type airQualityResult struct {
+	Provider    string               `json:"provider"`
+	Attribution string               `json:"attribution"`
+	Lat         float64              `json:"lat"`
+	Lon         float64              `json:"lon"`
+	Time        string               `json:"time"`
+	AQI         float64              `json:"aqi"`
+	Category    string               `json:"category"`
+	Pollutants  map[string]pollutant `json:"pollutants"`
+}
+
+// aqiCategory returns the US EPA category of an AQI value.
+func aqiCategory(aqi float64) string {
+	for _, category := range aqiCategories {
+		if aqi <= category.max {
+			return category.name
+		}
+	}
+
+	return "hazardous"
+}
+
+// fetchAirQuality requests the current US AQI and pollutant concentrations at lat/lon from Open-Meteo.
+func fetchAirQuality(ctx context.Context, lat, lon string) (*airQualityResult, error) {
+	variables := append([]string{"us_aqi"}, slices.Sorted(maps.Keys(pollutantVariables))...)
+
+	params := url.Values{
+		"latitude":  {lat},
+		"longitude": {lon},
+		"current":   {strings.Join(variables, ",")},
+		"timezone":  {"auto"},
+	}
+	body, err := api.Get(ctx, airQualityURL+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	var upstream struct {
+		Latitude     float64           `json:"latitude"`
+		Longitude    float64           `json:"longitude"`
+		CurrentUnits map[string]string `json:"current_units"`
+		Current      map[string]any    `json:"current"`
+	}
+	if err := json.Unmarshal([]byte(body), &upstream); err != nil {
+		return nil, err
+	}
+
+	aqi, _ := upstream.Current["us_aqi"].(float64)
+	timestamp, _ := upstream.Current["time"].(string)
+	result := &airQualityResult{
+		Provider:    "open-meteo",
+		Attribution: "Air quality data by Open-Meteo.com, CAMS",
+		Lat:         upstream.Latitude,
+		Lon:         upstream.Longitude,
+		Time:        timestamp,
+		AQI:         aqi,
+		Category:    aqiCategory(aqi),
+		Pollutants:  map[string]pollutant{},
+	}
+	for variable, name := range pollutantVariables {
+		if value, ok := upstream.Current[variable].(float64); ok {
+			result.Pollutants[name] = pollutant{Value: value, Unit: upstream.CurrentUnits[variable]}
+		}
+	}
+
+	return result, nil
+}
+
+// airQuality returns the current air quality at lat/lon, cached for airQualityCacheTTL per rounded
+// location.
+func airQuality(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	latitude, longitude, err := geo.ParseCoordinates(req.QueryStringParameters["lat"], req.QueryStringParameters["lon"])
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_coordinates", err.Error())
+	}
+
+	lat, lon := geo.FormatCoordinate(latitude, coordinatePrecision), geo.FormatCoordinate(longitude, coordinatePrecision)
+	key := airQualityKeys.Key(lat + "," + lon)
+	if cached := cache.Lookup(ctx, responses, key); cached != "" {
+		logger.InfoContext(ctx, "retrieved result from cache", slog.String("key", key))
+		return api.Respond(req, http.StatusOK, cached)
+	}
+
+	result, err := fetchAirQuality(ctx, lat, lon)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to fetch air quality", slog.Any("error", err))
+		return api.UpstreamError(req)
+	}
+
+	body, err := json.Marshal(result)
+	if err != nil {
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to encode air quality")
+	}
+
+	cache.Store(ctx, responses, key, string(body), airQualityCacheTTL)
+	return api.Respond(req, http.StatusOK, string(body))
+}
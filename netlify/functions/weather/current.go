@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/cache"
+	"nawa-functions/internal/geo"
+	"nawa-functions/internal/weather"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// currentKey is the cache key of the current conditions at lat/lon in units.
+func currentKey(lat, lon string, units weather.UnitSystem) string {
+	return weather.CurrentKey(lat, lon) + units.KeySuffix()
+}
+
+// loadCurrent returns the current conditions at a rounded location in units as JSON, cached per
+// location and unit system and refreshed in the background once older than
+// weather.CurrentCacheTTL.
+func loadCurrent(ctx context.Context, lat, lon string, units weather.UnitSystem) (string, error) {
+	return cache.Revalidate(ctx, responses, currentKey(lat, lon, units), weather.CurrentCacheTTL, func(ctx context.Context) (string, error) {
+		result, err := weather.FetchCurrent(ctx, lat, lon)
+		if err != nil {
+			return "", err
+		}
+		result.Convert(units)
+
+		body, err := json.Marshal(result)
+		return string(body), err
+	})
+}
+
+// current returns the current conditions at lat/lon in the units parameter.
+func current(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	latitude, longitude, err := geo.ParseCoordinates(req.QueryStringParameters["lat"], req.QueryStringParameters["lon"])
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_coordinates", err.Error())
+	}
+
+	units, err := weather.ParseUnitSystem(req.QueryStringParameters["units"])
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_options", err.Error())
+	}
+
+	lat, lon := geo.FormatCoordinate(latitude, coordinatePrecision), geo.FormatCoordinate(longitude, coordinatePrecision)
+	body, err := loadCurrent(ctx, lat, lon, units)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to fetch current conditions", slog.Any("error", err))
+		return api.UpstreamError(req)
+	}
+
+	return api.Respond(req, http.StatusOK, body)
+}
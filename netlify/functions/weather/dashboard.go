@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/cache"
+	"nawa-functions/internal/geo"
+	"nawa-functions/internal/weather"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	maxDashboardLocations = 20
+	dashboardConcurrency  = 5
+	maxLocationIDLength   = 64
+)
+
+type dashboardLocation struct {
+	ID  string  `json:"id,omitempty"`
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// dashboardEntry is a single location of the /dashboard response. Error is set instead of Current
+// and Today when the weather of the location could not be loaded.
+type dashboardEntry struct {
+	dashboardLocation
+	Current json.RawMessage `json:"current,omitempty"`
+	Today   *day            `json:"today,omitempty"`
+	Error   *api.ErrorBody  `json:"error,omitempty"`
+}
+
+type dashboardResult struct {
+	Provider    string           `json:"provider"`
+	Attribution string           `json:"attribution"`
+	Locations   []dashboardEntry `json:"locations"`
+}
+
+// loadDashboardEntry loads the current conditions and today's forecast of a location concurrently.
+// Both are read from the cache entries of the current and daily routes.
+func loadDashboardEntry(ctx context.Context, location dashboardLocation, units weather.UnitSystem) (dashboardEntry, error) {
+	entry := dashboardEntry{dashboardLocation: location}
+	lat, lon := geo.FormatCoordinate(location.Lat, coordinatePrecision), geo.FormatCoordinate(location.Lon, coordinatePrecision)
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		body, err := loadCurrent(ctx, lat, lon, units)
+		entry.Current = json.RawMessage(body)
+		return err
+	})
+	g.Go(func() error {
+		body, err := loadDaily(ctx, lat, lon, units)
+		if err != nil {
+			return err
+		}
+
+		var daily dailyResult
+		if err := json.Unmarshal([]byte(body), &daily); err != nil {
+			return err
+		}
+
+		if len(daily.Days) == 0 {
+			return errors.New("upstream returned no forecast days")
+		}
+
+		entry.Today = &daily.Days[0]
+		return nil
+	})
+
+	return entry, g.Wait()
+}
+
+// dashboard returns the current conditions and today's forecast for every location in the JSON
+// array body, in the same order, so that the dashboard of the app needs one request for all saved
+// locations, and the cached entries of all of them are read in one round trip. A location that fails
+// carries an error without failing the others.
+func dashboard(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	body, err := api.RequestBody(req)
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_body", "invalid request body")
+	}
+
+	var locations []dashboardLocation
+	if err := json.Unmarshal(body, &locations); err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_body", "request body must be an array of {id, lat, lon} objects")
+	}
+
+	if len(locations) == 0 || len(locations) > maxDashboardLocations {
+		message := fmt.Sprintf("dashboard must contain between 1 and %d locations", maxDashboardLocations)
+		return api.Error(req, http.StatusBadRequest, "invalid_batch_size", message)
+	}
+
+	units, err := weather.ParseUnitSystem(req.QueryStringParameters["units"])
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_options", err.Error())
+	}
+
+	keys := make([]string, 0, 2*len(locations))
+	for _, location := range locations {
+		if geo.ValidateCoordinates(location.Lat, location.Lon) == nil {
+			lat, lon := geo.FormatCoordinate(location.Lat, coordinatePrecision), geo.FormatCoordinate(location.Lon, coordinatePrecision)
+			keys = append(keys, currentKey(lat, lon, units), dailyKey(lat, lon, units))
+		}
+	}
+	ctx = cache.Prefetch(ctx, responses, keys)
+
+	result := dashboardResult{Provider: "open-meteo", Attribution: attribution, Locations: make([]dashboardEntry, len(locations))}
+	var g errgroup.Group
+	g.SetLimit(dashboardConcurrency)
+	for i, location := range locations {
+		g.Go(func() error {
+			if len(location.ID) > maxLocationIDLength {
+				message := fmt.Sprintf("id must be at most %d bytes long", maxLocationIDLength)
+				result.Locations[i] = dashboardEntry{Error: &api.ErrorBody{Code: "invalid_id", Message: message}}
+				return nil
+			}
+
+			if err := geo.ValidateCoordinates(location.Lat, location.Lon); err != nil {
+				result.Locations[i] = dashboardEntry{dashboardLocation: location, Error: &api.ErrorBody{Code: "invalid_coordinates", Message: err.Error()}}
+				return nil
+			}
+
+			entry, err := loadDashboardEntry(ctx, location, units)
+			if err != nil {
+				logger.ErrorContext(ctx, "failed to load dashboard location", slog.Any("error", err))
+				entry = dashboardEntry{dashboardLocation: location, Error: &api.ErrorBody{Code: "upstream_error", Message: "upstream request failed"}}
+			}
+
+			result.Locations[i] = entry
+			return nil
+		})
+	}
+	g.Wait()
+
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to encode dashboard")
+	}
+
+	return api.Respond(req, http.StatusOK, string(encoded))
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/cache"
+	"nawa-functions/internal/geo"
+	"nawa-functions/internal/prewarm"
+	"nawa-functions/internal/weather"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const forecastDays = 7
+
+var dailyVariables = []string{
+	"weather_code", "temperature_2m_max", "temperature_2m_min", "precipitation_probability_max",
+	"precipitation_sum", "wind_speed_10m_max",
+}
+
+// day is the normalized forecast of a single local day.
+type day struct {
+	Date                     string  `json:"date"`
+	High                     float64 `json:"high"`
+	Low                      float64 `json:"low"`
+	PrecipitationProbability float64 `json:"precipitationProbability"`
+	Precipitation            float64 `json:"precipitation"`
+	WindSpeed                float64 `json:"windSpeed"`
+	WeatherCode              int     `json:"weatherCode"`
+	Condition                string  `json:"condition"`
+}
+
+type dailyResult struct {
+	Provider    string        `json:"provider"`
+	Attribution string        `json:"attribution"`
+	Lat         float64       `json:"lat"`
+	Lon         float64       `json:"lon"`
+	Timezone    string        `json:"timezone"`
+	Units       weather.Units `json:"units"`
+	Days        []day         `json:"days"`
+}
+
+// convert converts the forecast from metric to units.
+func (r *dailyResult) convert(units weather.UnitSystem) {
+	r.Units = units.Units(r.Units)
+	for i := range r.Days {
+		d := &r.Days[i]
+		d.High, d.Low = units.Temperature(d.High), units.Temperature(d.Low)
+		d.Precipitation = units.Precipitation(d.Precipitation)
+		d.WindSpeed = units.WindSpeed(d.WindSpeed)
+	}
+}
+
+// openMeteoDaily is the subset of the Open-Meteo forecast response holding the daily forecast, one
+// array per variable with an entry per day.
+type openMeteoDaily struct {
+	Latitude   float64 `json:"latitude"`
+	Longitude  float64 `json:"longitude"`
+	Timezone   string  `json:"timezone"`
+	DailyUnits struct {
+		Temperature   string `json:"temperature_2m_max"`
+		Precipitation string `json:"precipitation_sum"`
+		WindSpeed     string `json:"wind_speed_10m_max"`
+	} `json:"daily_units"`
+	Daily struct {
+		Time                     []string  `json:"time"`
+		WeatherCode              []int     `json:"weather_code"`
+		High                     []float64 `json:"temperature_2m_max"`
+		Low                      []float64 `json:"temperature_2m_min"`
+		PrecipitationProbability []float64 `json:"precipitation_probability_max"`
+		Precipitation            []float64 `json:"precipitation_sum"`
+		WindSpeed                []float64 `json:"wind_speed_10m_max"`
+	} `json:"daily"`
+}
+
+// fetchDaily requests the daily forecast at lat/lon from Open-Meteo and normalizes it.
+func fetchDaily(ctx context.Context, lat, lon string) (*dailyResult, error) {
+	params := url.Values{
+		"latitude":      {lat},
+		"longitude":     {lon},
+		"daily":         {strings.Join(dailyVariables, ",")},
+		"forecast_days": {strconv.Itoa(forecastDays)},
+		"timezone":      {"auto"},
+	}
+	body, err := api.Get(ctx, forecastURL+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	var upstream openMeteoDaily
+	if err := json.Unmarshal([]byte(body), &upstream); err != nil {
+		return nil, err
+	}
+
+	d := upstream.Daily
+	n := len(d.Time)
+	if len(d.WeatherCode) != n || len(d.High) != n || len(d.Low) != n || len(d.PrecipitationProbability) != n ||
+		len(d.Precipitation) != n || len(d.WindSpeed) != n {
+		return nil, errors.New("upstream returned daily variables of different lengths")
+	}
+
+	result := &dailyResult{
+		Provider:    "open-meteo",
+		Attribution: attribution,
+		Lat:         upstream.Latitude,
+		Lon:         upstream.Longitude,
+		Timezone:    upstream.Timezone,
+		Units: weather.Units{
+			Temperature:   upstream.DailyUnits.Temperature,
+			Precipitation: upstream.DailyUnits.Precipitation,
+			WindSpeed:     upstream.DailyUnits.WindSpeed,
+		},
+		Days: make([]day, 0, n),
+	}
+	for i := range min(n, forecastDays) {
+		result.Days = append(result.Days, day{
+			Date:                     d.Time[i],
+			High:                     d.High[i],
+			Low:                      d.Low[i],
+			PrecipitationProbability: d.PrecipitationProbability[i],
+			Precipitation:            d.Precipitation[i],
+			WindSpeed:                d.WindSpeed[i],
+			WeatherCode:              d.WeatherCode[i],
+			Condition:                weather.Condition(d.WeatherCode[i]),
+		})
+	}
+
+	return result, nil
+}
+
+// dailyKey is the cache key of the daily forecast at lat/lon in units.
+func dailyKey(lat, lon string, units weather.UnitSystem) string {
+	return dailyKeys.Key(lat+","+lon) + units.KeySuffix()
+}
+
+// loadDaily returns the 7 day forecast at a rounded location in units as JSON, cached per location
+// and unit system and refreshed in the background once older than dailyCacheTTL.
+func loadDaily(ctx context.Context, lat, lon string, units weather.UnitSystem) (string, error) {
+	return cache.Revalidate(ctx, responses, dailyKey(lat, lon, units), dailyCacheTTL, func(ctx context.Context) (string, error) {
+		result, err := fetchDaily(ctx, lat, lon)
+		if err != nil {
+			return "", err
+		}
+		result.convert(units)
+
+		body, err := json.Marshal(result)
+		return string(body), err
+	})
+}
+
+// dailyForecast returns the 7 day forecast at lat/lon in the units parameter.
+func dailyForecast(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	latitude, longitude, err := geo.ParseCoordinates(req.QueryStringParameters["lat"], req.QueryStringParameters["lon"])
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_coordinates", err.Error())
+	}
+
+	units, err := weather.ParseUnitSystem(req.QueryStringParameters["units"])
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_options", err.Error())
+	}
+
+	lat, lon := geo.FormatCoordinate(latitude, coordinatePrecision), geo.FormatCoordinate(longitude, coordinatePrecision)
+	prewarm.Track(ctx, redisClient, prewarm.ForecastKey, lat+","+lon)
+	body, err := loadDaily(ctx, lat, lon, units)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to fetch daily forecast", slog.Any("error", err))
+		return api.UpstreamError(req)
+	}
+
+	return api.Respond(req, http.StatusOK, body)
+}
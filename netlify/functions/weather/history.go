@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/cache"
+	"nawa-functions/internal/geo"
+	"nawa-functions/internal/weather"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// maxHistoryDays bounds the range of a history request, which is enough for month over month
+// comparisons and keeps the cached payloads small.
+const maxHistoryDays = 31
+
+var archiveURL = api.EnvOr("archive_api_url", "https://archive-api.open-meteo.com/v1/archive")
+
+var historyVariables = []string{
+	"weather_code", "temperature_2m_max", "temperature_2m_min", "precipitation_sum", "wind_speed_10m_max",
+}
+
+// observedDay holds the observed conditions of a past day. Values are nil for recent days that the
+// archive has not processed yet.
+type observedDay struct {
+	Date          string   `json:"date"`
+	High          *float64 `json:"high"`
+	Low           *float64 `json:"low"`
+	Precipitation *float64 `json:"precipitation"`
+	WindSpeed     *float64 `json:"windSpeed"`
+	WeatherCode   *int     `json:"weatherCode"`
+	Condition     string   `json:"condition,omitempty"`
+}
+
+type historyResult struct {
+	Provider    string        `json:"provider"`
+	Attribution string        `json:"attribution"`
+	Lat         float64       `json:"lat"`
+	Lon         float64       `json:"lon"`
+	Timezone    string        `json:"timezone"`
+	Units       weather.Units `json:"units"`
+	Days        []observedDay `json:"days"`
+}
+
+// complete reports whether the archive had data for every day of the result.
+func (r *historyResult) complete() bool {
+	for _, d := range r.Days {
+		if d.High == nil || d.Low == nil || d.Precipitation == nil || d.WeatherCode == nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// convert converts the observed conditions from metric to units. Missing values stay missing.
+func (r *historyResult) convert(units weather.UnitSystem) {
+	r.Units = units.Units(r.Units)
+	convert := func(value *float64, to func(float64) float64) *float64 {
+		if value == nil {
+			return nil
+		}
+
+		converted := to(*value)
+		return &converted
+	}
+	for i := range r.Days {
+		d := &r.Days[i]
+		d.High, d.Low = convert(d.High, units.Temperature), convert(d.Low, units.Temperature)
+		d.Precipitation = convert(d.Precipitation, units.Precipitation)
+		d.WindSpeed = convert(d.WindSpeed, units.WindSpeed)
+	}
+}
+
+// parseHistoryRange reads the start and end dates of a history request. end defaults to start, and
+// the range must be in the past and span at most maxHistoryDays days.
+func parseHistoryRange(params map[string]string) (string, string, error) {
+	start, err := time.Parse(time.DateOnly, params["start"])
+	if err != nil {
+		return "", "", fmt.Errorf("start must be formatted as YYYY-MM-DD, got %q", params["start"])
+	}
+
+	end := start
+	if value, ok := params["end"]; ok {
+		if end, err = time.Parse(time.DateOnly, value); err != nil {
+			return "", "", fmt.Errorf("end must be formatted as YYYY-MM-DD, got %q", value)
+		}
+	}
+
+	switch {
+	case end.Before(start):
+		return "", "", errors.New("end must not be before start")
+	case !end.Before(time.Now().UTC().Truncate(24 * time.Hour)):
+		return "", "", errors.New("end must be in the past")
+	case end.Sub(start) >= maxHistoryDays*24*time.Hour:
+		return "", "", fmt.Errorf("the range must span at most %d days", maxHistoryDays)
+	}
+
+	return start.Format(time.DateOnly), end.Format(time.DateOnly), nil
+}
+
+// fetchHistory requests the observed daily conditions at lat/lon between start and end from the
+// Open-Meteo archive.
+func fetchHistory(ctx context.Context, lat, lon, start, end string) (*historyResult, error) {
+	params := url.Values{
+		"latitude":   {lat},
+		"longitude":  {lon},
+		"start_date": {start},
+		"end_date":   {end},
+		"daily":      {strings.Join(historyVariables, ",")},
+		"timezone":   {"auto"},
+	}
+	body, err := api.Get(ctx, archiveURL+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	var upstream struct {
+		Latitude   float64 `json:"latitude"`
+		Longitude  float64 `json:"longitude"`
+		Timezone   string  `json:"timezone"`
+		DailyUnits struct {
+			Temperature   string `json:"temperature_2m_max"`
+			Precipitation string `json:"precipitation_sum"`
+			WindSpeed     string `json:"wind_speed_10m_max"`
+		} `json:"daily_units"`
+		Daily struct {
+			Time          []string   `json:"time"`
+			WeatherCode   []*int     `json:"weather_code"`
+			High          []*float64 `json:"temperature_2m_max"`
+			Low           []*float64 `json:"temperature_2m_min"`
+			Precipitation []*float64 `json:"precipitation_sum"`
+			WindSpeed     []*float64 `json:"wind_speed_10m_max"`
+		} `json:"daily"`
+	}
+	if err := json.Unmarshal([]byte(body), &upstream); err != nil {
+		return nil, err
+	}
+
+	d := upstream.Daily
+	n := len(d.Time)
+	if len(d.WeatherCode) != n || len(d.High) != n || len(d.Low) != n || len(d.Precipitation) != n || len(d.WindSpeed) != n {
+		return nil, errors.New("upstream returned daily variables of different lengths")
+	}
+
+	result := &historyResult{
+		Provider:    "open-meteo",
+		Attribution: attribution,
+		Lat:         upstream.Latitude,
+		Lon:         upstream.Longitude,
+		Timezone:    upstream.Timezone,
+		Units: weather.Units{
+			Temperature:   upstream.DailyUnits.Temperature,
+			Precipitation: upstream.DailyUnits.Precipitation,
+			WindSpeed:     upstream.DailyUnits.WindSpeed,
+		},
+		Days: make([]observedDay, 0, n),
+	}
+	for i := range n {
+		observed := observedDay{
+			Date:          d.Time[i],
+			High:          d.High[i],
+			Low:           d.Low[i],
+			Precipitation: d.Precipitation[i],
+			WindSpeed:     d.WindSpeed[i],
+			WeatherCode:   d.WeatherCode[i],
+		}
+		if observed.WeatherCode != nil {
+			observed.Condition = weather.Condition(*observed.WeatherCode)
+		}
+		result.Days = append(result.Days, observed)
+	}
+
+	return result, nil
+}
+
+// history returns the observed conditions at lat/lon between the start and end dates, in the units
+// parameter. Past weather does not change, so complete results are cached for historyCacheTTL.
+// Results that include days the archive has not processed yet are only cached as long as a forecast.
+func history(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	latitude, longitude, err := geo.ParseCoordinates(req.QueryStringParameters["lat"], req.QueryStringParameters["lon"])
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_coordinates", err.Error())
+	}
+
+	start, end, err := parseHistoryRange(req.QueryStringParameters)
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_date", err.Error())
+	}
+
+	units, err := weather.ParseUnitSystem(req.QueryStringParameters["units"])
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_options", err.Error())
+	}
+
+	lat, lon := geo.FormatCoordinate(latitude, coordinatePrecision), geo.FormatCoordinate(longitude, coordinatePrecision)
+	key := historyKeys.Key(lat+","+lon, start, end) + units.KeySuffix()
+	if cached := cache.Lookup(ctx, responses, key); cached != "" {
+		logger.InfoContext(ctx, "retrieved result from cache", slog.String("key", key))
+		return api.Respond(req, http.StatusOK, cached)
+	}
+
+	result, err := fetchHistory(ctx, lat, lon, start, end)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to fetch weather history", slog.Any("error", err))
+		return api.UpstreamError(req)
+	}
+	result.convert(units)
+
+	body, err := json.Marshal(result)
+	if err != nil {
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to encode weather history")
+	}
+
+	ttl := historyCacheTTL
+	if !result.complete() {
+		ttl = dailyCacheTTL
+	}
+	cache.Store(ctx, responses, key, string(body), ttl)
+	return api.Respond(req, http.StatusOK, string(body))
+}
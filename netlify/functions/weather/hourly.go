@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/cache"
+	"nawa-functions/internal/geo"
+	"nawa-functions/internal/prewarm"
+	"nawa-functions/internal/weather"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const forecastHours = 48
+
+var hourlyVariables = []string{
+	"temperature_2m", "apparent_temperature", "relative_humidity_2m", "precipitation_probability",
+	"precipitation", "weather_code", "wind_speed_10m", "is_day",
+}
+
+// hour is the normalized forecast of a single hour.
+type hour struct {
+	Time                     string  `json:"time"`
+	Temperature              float64 `json:"temperature"`
+	ApparentTemperature      float64 `json:"apparentTemperature"`
+	Humidity                 float64 `json:"humidity"`
+	PrecipitationProbability float64 `json:"precipitationProbability"`
+	Precipitation            float64 `json:"precipitation"`
+	WindSpeed                float64 `json:"windSpeed"`
+	WeatherCode              int     `json:"weatherCode"`
+	Condition                string  `json:"condition"`
+	IsDay                    bool    `json:"isDay"`
+}
+
+type hourlyResult struct {
+	Provider    string        `json:"provider"`
+	Attribution string        `json:"attribution"`
+	Lat         float64       `json:"lat"`
+	Lon         float64       `json:"lon"`
+	Timezone    string        `json:"timezone"`
+	Units       weather.Units `json:"units"`
+	Hours       []hour        `json:"hours"`
+}
+
+// convert converts the forecast from metric to units.
+func (r *hourlyResult) convert(units weather.UnitSystem) {
+	r.Units = units.Units(r.Units)
+	for i := range r.Hours {
+		h := &r.Hours[i]
+		h.Temperature, h.ApparentTemperature = units.Temperature(h.Temperature), units.Temperature(h.ApparentTemperature)
+		h.Precipitation = units.Precipitation(h.Precipitation)
+		h.WindSpeed = units.WindSpeed(h.WindSpeed)
+	}
+}
+
+// openMeteoHourly is the subset of the Open-Meteo forecast response holding the hourly forecast, one
+// array per variable with an entry per hour.
+type openMeteoHourly struct {
+	Latitude    float64 `json:"latitude"`
+	Longitude   float64 `json:"longitude"`
+	Timezone    string  `json:"timezone"`
+	HourlyUnits struct {
+		Temperature   string `json:"temperature_2m"`
+		Precipitation string `json:"precipitation"`
+		WindSpeed     string `json:"wind_speed_10m"`
+	} `json:"hourly_units"`
+	Hourly struct {
+		Time                     []string  `json:"time"`
+		Temperature              []float64 `json:"temperature_2m"`
+		ApparentTemperature      []float64 `json:"apparent_temperature"`
+		Humidity                 []float64 `json:"relative_humidity_2m"`
+		PrecipitationProbability []float64 `json:"precipitation_probability"`
+		Precipitation            []float64 `json:"precipitation"`
+		WeatherCode              []int     `json:"weather_code"`
+		WindSpeed                []float64 `json:"wind_speed_10m"`
+		IsDay                    []int     `json:"is_day"`
+	} `json:"hourly"`
+}
+
+// fetchHourly requests the forecast of the next forecastHours hours at lat/lon from Open-Meteo and
+// normalizes it.
+func fetchHourly(ctx context.Context, lat, lon string) (*hourlyResult, error) {
+	params := url.Values{
+		"latitude":       {lat},
+		"longitude":      {lon},
+		"hourly":         {strings.Join(hourlyVariables, ",")},
+		"forecast_hours": {strconv.Itoa(forecastHours)},
+		"timezone":       {"auto"},
+	}
+	body, err := api.Get(ctx, forecastURL+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	var upstream openMeteoHourly
+	if err := json.Unmarshal([]byte(body), &upstream); err != nil {
+		return nil, err
+	}
+
+	h := upstream.Hourly
+	n := len(h.Time)
+	if len(h.Temperature) != n || len(h.ApparentTemperature) != n || len(h.Humidity) != n ||
+		len(h.PrecipitationProbability) != n || len(h.Precipitation) != n || len(h.WeatherCode) != n ||
+		len(h.WindSpeed) != n || len(h.IsDay) != n {
+		return nil, errors.New("upstream returned hourly variables of different lengths")
+	}
+
+	result := &hourlyResult{
+		Provider:    "open-meteo",
+		Attribution: attribution,
+		Lat:         upstream.Latitude,
+		Lon:         upstream.Longitude,
+		Timezone:    upstream.Timezone,
+		Units: weather.Units{
+			Temperature:   upstream.HourlyUnits.Temperature,
+			Precipitation: upstream.HourlyUnits.Precipitation,
+			WindSpeed:     upstream.HourlyUnits.WindSpeed,
+		},
+		Hours: make([]hour, 0, n),
+	}
+	for i := range min(n, forecastHours) {
+		result.Hours = append(result.Hours, hour{
+			Time:                     h.Time[i],
+			Temperature:              h.Temperature[i],
+			ApparentTemperature:      h.ApparentTemperature[i],
+			Humidity:                 h.Humidity[i],
+			PrecipitationProbability: h.PrecipitationProbability[i],
+			Precipitation:            h.Precipitation[i],
+			WindSpeed:                h.WindSpeed[i],
+			WeatherCode:              h.WeatherCode[i],
+			Condition:                weather.Condition(h.WeatherCode[i]),
+			IsDay:                    h.IsDay[i] == 1,
+		})
+	}
+
+	return result, nil
+}
+
+// hourlyForecast returns the forecast of the next hours at lat/lon, 48 unless limited by the hours
+// parameter, in the units parameter. The full 48 hours are cached per rounded location and unit system
+// and trimmed on every request, so that every limit shares one cache entry.
+func hourlyForecast(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	latitude, longitude, err := geo.ParseCoordinates(req.QueryStringParameters["lat"], req.QueryStringParameters["lon"])
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_coordinates", err.Error())
+	}
+
+	units, err := weather.ParseUnitSystem(req.QueryStringParameters["units"])
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_options", err.Error())
+	}
+
+	hours := forecastHours
+	if value, ok := req.QueryStringParameters["hours"]; ok {
+		hours, err = strconv.Atoi(value)
+		if err != nil || hours < 1 || hours > forecastHours {
+			message := fmt.Sprintf("hours must be an integer between 1 and %d, got %q", forecastHours, value)
+			return api.Error(req, http.StatusBadRequest, "invalid_options", message)
+		}
+	}
+
+	lat, lon := geo.FormatCoordinate(latitude, coordinatePrecision), geo.FormatCoordinate(longitude, coordinatePrecision)
+	key := hourlyKeys.Key(lat+","+lon) + units.KeySuffix()
+	prewarm.Track(ctx, redisClient, prewarm.ForecastKey, lat+","+lon)
+
+	var result *hourlyResult
+	if cached := cache.Lookup(ctx, responses, key); cached != "" {
+		if err := json.Unmarshal([]byte(cached), &result); err == nil {
+			logger.InfoContext(ctx, "retrieved result from cache", slog.String("key", key))
+		}
+	}
+
+	if result == nil {
+		result, err = fetchHourly(ctx, lat, lon)
+		if err != nil {
+			logger.ErrorContext(ctx, "failed to fetch hourly forecast", slog.Any("error", err))
+			return api.UpstreamError(req)
+		}
+		result.convert(units)
+
+		if body, err := json.Marshal(result); err == nil {
+			cache.Store(ctx, responses, key, string(body), hourlyCacheTTL)
+		}
+	}
+
+	result.Hours = result.Hours[:min(hours, len(result.Hours))]
+	body, err := json.Marshal(result)
+	if err != nil {
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to encode hourly forecast")
+	}
+
+	return api.Respond(req, http.StatusOK, string(body))
+}
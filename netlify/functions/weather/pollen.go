@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/cache"
+	"nawa-functions/internal/geo"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+var (
+	// pollenURL is the Google Pollen API. Open-Meteo only forecasts pollen for Europe, so it cannot
+	// serve the US locations of the nawa app.
+	pollenURL    = api.EnvOr("pollen_api_url", "https://pollen.googleapis.com/v1/forecast:lookup")
+	pollenAPIKey = os.Getenv("pollen_api_key")
+)
+
+// pollenLevel is the index of a pollen type on the 0 to 5 Universal Pollen Index, with its category.
+type pollenLevel struct {
+	Type     string `json:"type"`
+	Index    int    `json:"index"`
+	Category string `json:"category"`
+	InSeason bool   `json:"inSeason"`
+}
+
+type pollenResult struct {
+	Provider    string        `json:"provider"`
+	Attribution string        `json:"attribution"`
+	Lat         float64       `json:"lat"`
+	Lon         float64       `json:"lon"`
+	Date        string        `json:"date"`
+	Index       int           `json:"index"`
+	Category    string        `json:"category"`
+	Types       []pollenLevel `json:"types"`
+}
+
+// pollenCategory folds the six Universal Pollen Index levels into low, moderate and high.
+func pollenCategory(index int) string {
+	switch {
+	case index >= 4:
+		return "high"
+	case index == 3:
+		return "moderate"
+	}
+
+	return "low"
+}
+
+// fetchPollen requests today's pollen forecast at lat/lon from the Google Pollen API.
+func fetchPollen(ctx context.Context, lat, lon string) (*pollenResult, error) {
+	params := url.Values{
+		"key":                {pollenAPIKey},
+		"location.latitude":  {lat},
+		"location.longitude": {lon},
+		"days":               {"1"},
+		"plantsDescription":  {"false"},
+	}
+	body, err := api.Get(ctx, pollenURL+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	var upstream struct {
+		DailyInfo []struct {
+			Date struct {
+				Year  int `json:"year"`
+				Month int `json:"month"`
+				Day   int `json:"day"`
+			} `json:"date"`
+			PollenTypeInfo []struct {
+				Code      string `json:"code"`
+				InSeason  bool   `json:"inSeason"`
+				IndexInfo *struct {
+					Value int `json:"value"`
+				} `json:"indexInfo"`
+			} `json:"pollenTypeInfo"`
+		} `json:"dailyInfo"`
+	}
+	if err := json.Unmarshal([]byte(body), &upstream); err != nil {
+		return nil, err
+	}
+
+	if len(upstream.DailyInfo) == 0 {
+		return nil, errors.New("upstream returned no pollen forecast")
+	}
+
+	today := upstream.DailyInfo[0]
+	result := &pollenResult{
+		Provider:    "google",
+		Attribution: "Pollen data by Google",
+		Date:        fmt.Sprintf("%04d-%02d-%02d", today.Date.Year, today.Date.Month, today.Date.Day),
+		Types:       []pollenLevel{},
+	}
+	for _, info := range today.PollenTypeInfo {
+		level := pollenLevel{Type: strings.ToLower(info.Code), InSeason: info.InSeason}
+		// Types out of season have no index.
+		if info.IndexInfo != nil {
+			level.Index = info.IndexInfo.Value
+		}
+		level.Category = pollenCategory(level.Index)
+		result.Index = max(result.Index, level.Index)
+		result.Types = append(result.Types, level)
+	}
+	result.Category = pollenCategory(result.Index)
+
+	return result, nil
+}
+
+// pollen returns today's pollen index at lat/lon. Entries are cached per rounded location and UTC day,
+// since the upstream only updates its forecast daily.
+func pollen(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	latitude, longitude, err := geo.ParseCoordinates(req.QueryStringParameters["lat"], req.QueryStringParameters["lon"])
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_coordinates", err.Error())
+	}
+
+	lat, lon := geo.FormatCoordinate(latitude, coordinatePrecision), geo.FormatCoordinate(longitude, coordinatePrecision)
+	key := pollenKeys.Key(lat+","+lon, time.Now().UTC().Format(time.DateOnly))
+	if cached := cache.Lookup(ctx, responses, key); cached != "" {
+		logger.InfoContext(ctx, "retrieved result from cache", slog.String("key", key))
+		return api.Respond(req, http.StatusOK, cached)
+	}
+
+	result, err := fetchPollen(ctx, lat, lon)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to fetch pollen forecast", slog.Any("error", err))
+		return api.UpstreamError(req)
+	}
+	result.Lat, _ = strconv.ParseFloat(lat, 64)
+	result.Lon, _ = strconv.ParseFloat(lon, 64)
+
+	body, err := json.Marshal(result)
+	if err != nil {
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to encode pollen forecast")
+	}
+
+	cache.Store(ctx, responses, key, string(body), pollenCacheTTL)
+	return api.Respond(req, http.StatusOK, string(body))
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/cache"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const maxRadarZoom = 12
+
+var (
+	// radarURL is the OpenWeatherMap precipitation tile layer. Its API key must not reach the browser,
+	// which is why tiles are proxied.
+	radarURL    = api.EnvOr("radar_tile_url", "https://tile.openweathermap.org/map/precipitation_new")
+	radarAPIKey = os.Getenv("radar_api_key")
+)
+
+// parseTile validates the z/x/y path parameters of a slippy map tile. y may carry a .png suffix so
+// that the route can be used directly as a tile URL template.
+func parseTile(params map[string]string) (z, x, y int, err error) {
+	z, err = strconv.Atoi(params["z"])
+	if err != nil || z < 0 || z > maxRadarZoom {
+		return 0, 0, 0, fmt.Errorf("z must be an integer between 0 and %d, got %q", maxRadarZoom, params["z"])
+	}
+
+	tiles := 1 << z
+	x, err = strconv.Atoi(params["x"])
+	if err != nil || x < 0 || x >= tiles {
+		return 0, 0, 0, fmt.Errorf("x must be an integer between 0 and %d, got %q", tiles-1, params["x"])
+	}
+
+	y, err = strconv.Atoi(strings.TrimSuffix(params["y"], ".png"))
+	if err != nil || y < 0 || y >= tiles {
+		return 0, 0, 0, fmt.Errorf("y must be an integer between 0 and %d, got %q", tiles-1, params["y"])
+	}
+
+	return z, x, y, nil
+}
+
+// radarTile proxies a precipitation radar tile. Tiles are cached base64 encoded for radarCacheTTL,
+// about as often as the upstream refreshes them.
+func radarTile(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	z, x, y, err := parseTile(req.PathParameters)
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_tile", err.Error())
+	}
+
+	tile := fmt.Sprintf("%d/%d/%d", z, x, y)
+	key := radarKeys.Key(tile)
+
+	var image []byte
+	if cached := cache.Lookup(ctx, responses, key); cached != "" {
+		// DecodeString returns the bytes decoded before an error, which must not be served.
+		if decoded, err := base64.StdEncoding.DecodeString(cached); err == nil {
+			image = decoded
+			logger.InfoContext(ctx, "retrieved result from cache", slog.String("key", key))
+		}
+	}
+
+	if image == nil {
+		body, err := api.Get(ctx, radarURL+"/"+tile+".png?"+url.Values{"appid": {radarAPIKey}}.Encode())
+		if err != nil {
+			return api.UpstreamError(req)
+		}
+
+		image = []byte(body)
+		cache.Store(ctx, responses, key, base64.StdEncoding.EncodeToString(image), radarCacheTTL)
+	}
+
+	return api.Binary(req, http.DetectContentType(image), image)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/cache"
+	"nawa-functions/internal/geo"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// uvLevel is a UV index value with its WHO exposure category.
+type uvLevel struct {
+	Index    float64 `json:"index"`
+	Category string  `json:"category"`
+}
+
+type uvDay struct {
+	Date string  `json:"date"`
+	Max  uvLevel `json:"max"`
+}
+
+type uvResult struct {
+	Provider    string  `json:"provider"`
+	Attribution string  `json:"attribution"`
+	Lat         float64 `json:"lat"`
+	Lon         float64 `json:"lon"`
+	Timezone    string  `json:"timezone"`
+	Time        string  `json:"time"`
+	Current     uvLevel `json:"current"`
+	Days        []uvDay `json:"days"`
+}
+
+// uvCategory returns the WHO exposure category of a UV index.
+func uvCategory(index float64) string {
+	switch {
+	case index >= 11:
+		return "extreme"
+	case index >= 8:
+		return "very_high"
+	case index >= 6:
+		return "high"
+	case index >= 3:
+		return "moderate"
+	}
+
+	return "low"
+}
+
+func newUVLevel(index float64) uvLevel {
+	return uvLevel{Index: index, Category: uvCategory(index)}
+}
+
+// fetchUV requests the current UV index and the daily maximum of the coming days at lat/lon from
+// Open-Meteo.
+func fetchUV(ctx context.Context, lat, lon string) (*uvResult, error) {
+	params := url.Values{
+		"latitude":      {lat},
+		"longitude":     {lon},
+		"current":       {"uv_index"},
+		"daily":         {"uv_index_max"},
+		"forecast_days": {strconv.Itoa(forecastDays)},
+		"timezone":      {"auto"},
+	}
+	body, err := api.Get(ctx, forecastURL+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	var upstream struct {
+		Latitude  float64 `json:"latitude"`
+		Longitude float64 `json:"longitude"`
+		Timezone  string  `json:"timezone"`
+		Current   struct {
+			Time    string  `json:"time"`
+			UVIndex float64 `json:"uv_index"`
+		} `json:"current"`
+		Daily struct {
+			Time       []string  `json:"time"`
+			UVIndexMax []float64 `json:"uv_index_max"`
+		} `json:"daily"`
+	}
+	if err := json.Unmarshal([]byte(body), &upstream); err != nil {
+		return nil, err
+	}
+
+	if len(upstream.Daily.Time) != len(upstream.Daily.UVIndexMax) {
+		return nil, errors.New("upstream returned daily variables of different lengths")
+	}
+
+	result := &uvResult{
+		Provider:    "open-meteo",
+		Attribution: attribution,
+		Lat:         upstream.Latitude,
+		Lon:         upstream.Longitude,
+		Timezone:    upstream.Timezone,
+		Time:        upstream.Current.Time,
+		Current:     newUVLevel(upstream.Current.UVIndex),
+		Days:        make([]uvDay, 0, len(upstream.Daily.Time)),
+	}
+	for i, date := range upstream.Daily.Time {
+		result.Days = append(result.Days, uvDay{Date: date, Max: newUVLevel(upstream.Daily.UVIndexMax[i])})
+	}
+
+	return result, nil
+}
+
+// uv returns the current UV index at lat/lon and the daily maximum of the coming days, cached for
+// uvCacheTTL per rounded location.
+func uv(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	latitude, longitude, err := geo.ParseCoordinates(req.QueryStringParameters["lat"], req.QueryStringParameters["lon"])
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_coordinates", err.Error())
+	}
+
+	lat, lon := geo.FormatCoordinate(latitude, coordinatePrecision), geo.FormatCoordinate(longitude, coordinatePrecision)
+	key := uvKeys.Key(lat + "," + lon)
+	if cached := cache.Lookup(ctx, responses, key); cached != "" {
+		logger.InfoContext(ctx, "retrieved result from cache", slog.String("key", key))
+		return api.Respond(req, http.StatusOK, cached)
+	}
+
+	result, err := fetchUV(ctx, lat, lon)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to fetch UV index", slog.Any("error", err))
+		return api.UpstreamError(req)
+	}
+
+	body, err := json.Marshal(result)
+	if err != nil {
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to encode UV index")
+	}
+
+	cache.Store(ctx, responses, key, string(body), uvCacheTTL)
+	return api.Respond(req, http.StatusOK, string(body))
+}
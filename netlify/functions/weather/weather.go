@@ -0,0 +1,73 @@
+package main
+
+import (
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/cache"
+	"nawa-functions/internal/metrics"
+	"nawa-functions/internal/weather"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	redisClient = redis.NewClient(&redis.Options{
+		Addr:     os.Getenv("db_address"),
+		Username: os.Getenv("db_username"),
+		Password: os.Getenv("db_password"),
+		DB:       0,
+	})
+	// responses caches upstream responses, in memory when no Redis server is configured.
+	responses   = cache.New(redisClient)
+	logger      = api.Logger
+	forecastURL = weather.ForecastURL
+)
+
+const (
+	coordinatePrecision = weather.CoordinatePrecision
+	attribution         = weather.Attribution
+)
+
+// Cache TTLs follow how often the models update. They can be set with the cache_ttl_<class>
+// environment variables, see cache.TTLFor.
+var (
+	dailyCacheTTL      = cache.TTLFor("weather_daily", time.Hour)
+	hourlyCacheTTL     = cache.TTLFor("weather_hourly", 30*time.Minute)
+	airQualityCacheTTL = cache.TTLFor("weather_airquality", 30*time.Minute)
+	pollenCacheTTL     = cache.TTLFor("weather_pollen", 24*time.Hour)
+	historyCacheTTL    = cache.TTLFor("weather_history", 30*24*time.Hour)
+	uvCacheTTL         = cache.TTLFor("weather_uv", time.Hour)
+	radarCacheTTL      = cache.TTLFor("weather_radar", 10*time.Minute)
+)
+
+// The keyspaces of the entries cached by the weather function, besides weather.CurrentKeys.
+var (
+	dailyKeys      = cache.Keyspace{Namespace: "wx", Kind: "daily", Version: 2}
+	hourlyKeys     = cache.Keyspace{Namespace: "wx", Kind: "hourly", Version: 2}
+	airQualityKeys = cache.Keyspace{Namespace: "wx", Kind: "airquality", Version: 2}
+	pollenKeys     = cache.Keyspace{Namespace: "wx", Kind: "pollen", Version: 2}
+	historyKeys    = cache.Keyspace{Namespace: "wx", Kind: "history", Version: 2}
+	uvKeys         = cache.Keyspace{Namespace: "wx", Kind: "uv", Version: 2}
+	radarKeys      = cache.Keyspace{Namespace: "wx", Kind: "radar", Version: 2}
+)
+
+// routes is the route table of the function. The prefixes are configured separately from the
+// geocoding function because Netlify environment variables are shared by every function of a site.
+var routes = api.NewMux(strings.Split(api.EnvOr("weather_route_prefixes", "/.netlify/functions/weather"), ",")...).
+	Handle(http.MethodGet, "/current", api.Authorized(current)).
+	Handle(http.MethodGet, "/forecast/daily", api.Authorized(dailyForecast)).
+	Handle(http.MethodGet, "/forecast/hourly", api.Authorized(hourlyForecast)).
+	Handle(http.MethodPost, "/dashboard", api.Authorized(dashboard)).
+	Handle(http.MethodGet, "/airquality", api.Authorized(airQuality)).
+	Handle(http.MethodGet, "/pollen", api.Authorized(pollen)).
+	Handle(http.MethodGet, "/history", api.Authorized(history)).
+	Handle(http.MethodGet, "/uv", api.Authorized(uv)).
+	Handle(http.MethodGet, "/radar/{z}/{x}/{y}", api.Authorized(radarTile))
+
+func main() {
+	lambda.Start(metrics.Instrument(redisClient, "weather", api.Handler(routes)))
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"nawa-functions/internal"
+	"nawa-functions/internal/alerts"
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/webhooks"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	redisClient = redis.NewClient(&redis.Options{
+		Addr:     os.Getenv("db_address"),
+		Username: os.Getenv("db_username"),
+		Password: os.Getenv("db_password"),
+		DB:       0,
+	})
+	logger = api.Logger
+)
+
+// sentTTL keeps track of the alerts a webhook received for longer than alerts usually stay active,
+// so that each alert is only delivered once.
+const sentTTL = 72 * time.Hour
+
+// delivery is the body posted to a webhook.
+type delivery struct {
+	Webhook string       `json:"webhook"`
+	Lat     float64      `json:"lat"`
+	Lon     float64      `json:"lon"`
+	Alert   alerts.Alert `json:"alert"`
+}
+
+// webhook is a stored webhook with its id.
+type webhook struct {
+	id     string
+	record webhooks.Record
+}
+
+// loadWebhooks returns the stored webhooks grouped by alert point, so that the alerts of each point
+// are only requested once.
+func loadWebhooks(ctx context.Context) (map[string][]webhook, error) {
+	sealed, err := redisClient.HGetAll(ctx, webhooks.Key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	byPoint := map[string][]webhook{}
+	for id, value := range sealed {
+		record, err := webhooks.Open(value)
+		if err != nil {
+			logger.ErrorContext(ctx, "failed to decrypt webhook", slog.String("id", id), slog.Any("error", err))
+			continue
+		}
+
+		point := alerts.Point(record.Lat, record.Lon)
+		byPoint[point] = append(byPoint[point], webhook{id: id, record: record})
+	}
+
+	return byPoint, nil
+}
+
+// post delivers payload to w. The signature covers the timestamp and the body, so that receivers
+// can reject replayed deliveries. Redirects are reported as rejections rather than followed.
+func post(ctx context.Context, w webhook, payload []byte) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	signature := internal.Sign([]byte(timestamp+"."+string(payload)), []byte(w.record.Secret))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.record.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "nawa-functions (https://tshrestha.github.io/nawa)")
+	req.Header.Set("X-Nawa-Timestamp", timestamp)
+	req.Header.Set("X-Nawa-Signature", "sha256="+signature)
+
+	res, err := webhooks.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	res.Body.Close()
+
+	return res.StatusCode, nil
+}
+
+// dispatch delivers alert to w unless it was delivered before. Failed deliveries are retried on the
+// next run, and webhooks whose URL reports 410 Gone are deleted.
+func dispatch(ctx context.Context, w webhook, alert alerts.Alert) {
+	sentKey := webhooks.SentKey(w.id)
+	added, err := redisClient.SAdd(ctx, sentKey, alert.ID).Result()
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to record delivery", slog.String("id", w.id), slog.Any("error", err))
+		return
+	}
+	redisClient.Expire(ctx, sentKey, sentTTL)
+	if added == 0 {
+		return
+	}
+
+	payload, _ := json.Marshal(delivery{Webhook: w.id, Lat: w.record.Lat, Lon: w.record.Lon, Alert: alert})
+	statusCode, err := post(ctx, w, payload)
+	switch {
+	case err != nil:
+		logger.ErrorContext(ctx, "failed to deliver webhook", slog.String("id", w.id), slog.Any("error", err))
+		redisClient.SRem(ctx, sentKey, alert.ID)
+	case statusCode == http.StatusGone:
+		logger.InfoContext(ctx, "deleting gone webhook", slog.String("id", w.id))
+		redisClient.HDel(ctx, webhooks.Key, w.id)
+		redisClient.Del(ctx, sentKey)
+	case statusCode >= http.StatusMultipleChoices:
+		logger.ErrorContext(ctx, "webhook rejected delivery", slog.String("id", w.id), slog.Int("statusCode", statusCode))
+		redisClient.SRem(ctx, sentKey, alert.ID)
+	default:
+		logger.InfoContext(ctx, "delivered webhook", slog.String("id", w.id), slog.String("alert", alert.ID))
+	}
+}
+
+// handler runs on the schedule configured in netlify.toml. It checks the active alerts of every
+// webhook location and delivers those that are severe enough and new to the webhook.
+func handler(ctx context.Context, _ events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	byPoint, err := loadWebhooks(ctx)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to load webhooks", slog.Any("error", err))
+		return &events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, nil
+	}
+
+	for point, hooks := range byPoint {
+		result, err := alerts.Fetch(ctx, url.Values{"point": {point}})
+		if err != nil {
+			logger.ErrorContext(ctx, "failed to fetch alerts", slog.String("point", point), slog.Any("error", err))
+			continue
+		}
+
+		for _, alert := range result.Alerts {
+			for _, w := range hooks {
+				if alerts.AtLeast(alert.Severity, w.record.MinSeverity) {
+					dispatch(ctx, w, alert)
+				}
+			}
+		}
+	}
+
+	return &events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+}
+
+func main() {
+	lambda.Start(handler)
+}
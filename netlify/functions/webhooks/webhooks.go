@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"nawa-functions/internal"
+	"nawa-functions/internal/alerts"
+	"nawa-functions/internal/api"
+	"nawa-functions/internal/geo"
+	"nawa-functions/internal/metrics"
+	"nawa-functions/internal/webhooks"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	redisClient = redis.NewClient(&redis.Options{
+		Addr:     os.Getenv("db_address"),
+		Username: os.Getenv("db_username"),
+		Password: os.Getenv("db_password"),
+		DB:       0,
+	})
+	logger = api.Logger
+)
+
+const (
+	defaultMinSeverity = "severe"
+	maxURLLength       = 2048
+)
+
+type registerRequest struct {
+	URL         string  `json:"url"`
+	Lat         float64 `json:"lat"`
+	Lon         float64 `json:"lon"`
+	MinSeverity string  `json:"minSeverity"`
+}
+
+// validateURL accepts absolute https URLs, except for those naming local or private addresses
+// directly, which the dispatcher should never be made to call. Hosts resolving to such addresses are
+// rejected by webhooks.Client when delivering.
+func validateURL(webhookURL string) error {
+	u, err := url.Parse(webhookURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" || len(webhookURL) > maxURLLength {
+		return errors.New("url must be an absolute https URL")
+	}
+
+	host := u.Hostname()
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errors.New("url must not point to a local address")
+	}
+
+	if addr, err := netip.ParseAddr(host); err == nil && !webhooks.Public(addr) {
+		return errors.New("url must not point to a local or private address")
+	}
+
+	return nil
+}
+
+// register stores the webhook in the body and returns its id and the secret its deliveries are
+// signed with. The secret is only returned here.
+func register(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	body, err := api.RequestBody(req)
+	if err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_body", "invalid request body")
+	}
+
+	var r registerRequest
+	if err := json.Unmarshal(body, &r); err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_body", "request body must be a JSON object")
+	}
+
+	if err := validateURL(r.URL); err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_url", err.Error())
+	}
+
+	if err := geo.ValidateCoordinates(r.Lat, r.Lon); err != nil {
+		return api.Error(req, http.StatusBadRequest, "invalid_coordinates", err.Error())
+	}
+
+	if r.MinSeverity == "" {
+		r.MinSeverity = defaultMinSeverity
+	}
+	if !alerts.KnownSeverity(r.MinSeverity) {
+		return api.Error(req, http.StatusBadRequest, "invalid_severity", "minSeverity must be extreme, severe, moderate or minor")
+	}
+
+	record := webhooks.Record{URL: r.URL, Lat: r.Lat, Lon: r.Lon, MinSeverity: r.MinSeverity, Secret: internal.NewPrefixedToken("whsec", 32)}
+	sealed, err := webhooks.Seal(record)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to encrypt webhook", slog.Any("error", err))
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to store webhook")
+	}
+
+	id := internal.NewToken(16)
+	if err := redisClient.HSet(ctx, webhooks.Key, id, sealed).Err(); err != nil {
+		logger.ErrorContext(ctx, "failed to store webhook", slog.Any("error", err))
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to store webhook")
+	}
+
+	logger.InfoContext(ctx, "registered webhook", slog.String("id", id))
+	result, _ := json.Marshal(map[string]string{"id": id, "secret": record.Secret})
+	return api.Respond(req, http.StatusCreated, string(result))
+}
+
+// unregister deletes the webhook with the given id.
+func unregister(ctx context.Context, req *events.APIGatewayProxyRequest, id string) *events.APIGatewayProxyResponse {
+	deleted, err := redisClient.HDel(ctx, webhooks.Key, id).Result()
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to delete webhook", slog.Any("error", err))
+		return api.Error(req, http.StatusInternalServerError, "internal_error", "failed to delete webhook")
+	}
+
+	if deleted == 0 {
+		return api.Error(req, http.StatusNotFound, "not_found", "webhook not found")
+	}
+
+	redisClient.Del(ctx, webhooks.SentKey(id))
+	return api.Respond(req, http.StatusNoContent, "")
+}
+
+var routes = api.NewMux(strings.Split(api.EnvOr("webhooks_route_prefixes", "/.netlify/functions/webhooks"), ",")...).
+	Handle(http.MethodPost, "/", api.Authorized(register)).
+	Handle(http.MethodDelete, "/{id}", api.Authorized(func(ctx context.Context, req *events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+		return unregister(ctx, req, req.PathParameters["id"])
+	}))
+
+func main() {
+	lambda.Start(metrics.Instrument(redisClient, "webhooks", api.Handler(routes)))
+}